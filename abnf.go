@@ -0,0 +1,756 @@
+package srgs
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type abnfTokenType int
+
+const (
+	abnfEOF abnfTokenType = iota
+	abnfWord
+	abnfQuoted
+	abnfRuleRef
+	abnfTag
+	abnfAngle
+	abnfWeight
+	abnfLanguage
+	abnfPunct
+)
+
+// A lexical token of an ABNF grammar document
+type abnfToken struct {
+	typ  abnfTokenType
+	text string
+
+	line   int
+	column int
+}
+
+// Characters which end a bare token in addition to white space (see https://www.w3.org/TR/speech-grammar/#S2.1)
+const abnfDelimiters = ";|/()[]<>{}$=!\""
+
+type abnfLexer struct {
+	src string
+	pos int
+
+	line   int
+	column int
+
+	peeked *abnfToken
+
+	// once the source cannot be tokenized, every following token fails with the same error
+	err error
+}
+
+func newAbnfLexer(src string) *abnfLexer {
+	return &abnfLexer{src: strings.TrimPrefix(src, "\uFEFF"), line: 1, column: 1}
+}
+
+func (l *abnfLexer) peek() (abnfToken, error) {
+	if l.err != nil {
+		return abnfToken{}, l.err
+	}
+
+	if l.peeked == nil {
+		tok, err := l.lex()
+
+		if err != nil {
+			l.err = err
+			return tok, err
+		}
+
+		l.peeked = &tok
+	}
+
+	return *l.peeked, nil
+}
+
+func (l *abnfLexer) next() (abnfToken, error) {
+	tok, err := l.peek()
+	l.peeked = nil
+
+	return tok, err
+}
+
+func (l *abnfLexer) advance() rune {
+	r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+	l.pos += size
+
+	if r == '\n' {
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
+	}
+
+	return r
+}
+
+func (l *abnfLexer) errorf(line, column int, format string, args ...interface{}) error {
+	return &GrammarError{Line: line, Column: column, Err: fmt.Errorf(format, args...)}
+}
+
+// Skips white space and comments
+func (l *abnfLexer) skip() error {
+	for l.pos < len(l.src) {
+		r, _ := utf8.DecodeRuneInString(l.src[l.pos:])
+
+		if unicode.IsSpace(r) {
+			l.advance()
+		} else if strings.HasPrefix(l.src[l.pos:], "//") {
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.advance()
+			}
+		} else if strings.HasPrefix(l.src[l.pos:], "/*") {
+			line, column := l.line, l.column
+			end := strings.Index(l.src[l.pos+2:], "*/")
+
+			if end == -1 {
+				return l.errorf(line, column, "unterminated comment")
+			}
+
+			for stop := l.pos + end + 4; l.pos < stop; {
+				l.advance()
+			}
+		} else {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// Reads up to (and consumes) the closing string, returning everything before it
+func (l *abnfLexer) readUntil(closing string, tok abnfToken, what string) (string, error) {
+	end := strings.Index(l.src[l.pos:], closing)
+
+	if end == -1 {
+		return "", l.errorf(tok.line, tok.column, "unterminated %s", what)
+	}
+
+	start := l.pos
+	for stop := l.pos + end + len(closing); l.pos < stop; {
+		l.advance()
+	}
+
+	return l.src[start : start+end], nil
+}
+
+func (l *abnfLexer) lex() (abnfToken, error) {
+	if err := l.skip(); err != nil {
+		return abnfToken{}, err
+	}
+
+	tok := abnfToken{line: l.line, column: l.column}
+
+	if l.pos >= len(l.src) {
+		tok.typ = abnfEOF
+		return tok, nil
+	}
+
+	var err error
+
+	switch r := l.advance(); r {
+	case ';', '|', '(', ')', '[', ']', '=':
+		tok.typ = abnfPunct
+		tok.text = string(r)
+	case '{':
+		tok.typ = abnfTag
+
+		if strings.HasPrefix(l.src[l.pos:], "!{") {
+			l.advance()
+			l.advance()
+			tok.text, err = l.readUntil("}!}", tok, "tag")
+		} else {
+			tok.text, err = l.readUntil("}", tok, "tag")
+		}
+	case '<':
+		tok.typ = abnfAngle
+		tok.text, err = l.readUntil(">", tok, "angle bracket")
+	case '/':
+		tok.typ = abnfWeight
+		tok.text, err = l.readUntil("/", tok, "weight")
+	case '"':
+		tok.typ = abnfQuoted
+		tok.text, err = l.readQuoted(tok)
+	case '$':
+		tok.typ = abnfRuleRef
+
+		if strings.HasPrefix(l.src[l.pos:], "<") {
+			l.advance()
+			var uri string
+			uri, err = l.readUntil(">", tok, "rule reference")
+			tok.text = "<" + uri + ">"
+		} else {
+			tok.text = l.readWord()
+		}
+
+		if err == nil && tok.text == "" {
+			err = l.errorf(tok.line, tok.column, "rule reference must have a name")
+		}
+	case '!':
+		tok.typ = abnfLanguage
+		tok.text = l.readWord()
+	default:
+		tok.typ = abnfWord
+		tok.text = string(r) + l.readWord()
+	}
+
+	return tok, err
+}
+
+func (l *abnfLexer) readWord() string {
+	start := l.pos
+
+	for l.pos < len(l.src) {
+		r, _ := utf8.DecodeRuneInString(l.src[l.pos:])
+
+		if unicode.IsSpace(r) || strings.ContainsRune(abnfDelimiters, r) {
+			break
+		}
+
+		l.advance()
+	}
+
+	return l.src[start:l.pos]
+}
+
+func (l *abnfLexer) readQuoted(tok abnfToken) (string, error) {
+	var out strings.Builder
+
+	for l.pos < len(l.src) {
+		switch r := l.advance(); r {
+		case '"':
+			return out.String(), nil
+		case '\\':
+			if l.pos < len(l.src) {
+				out.WriteRune(l.advance())
+			}
+		default:
+			out.WriteRune(r)
+		}
+	}
+
+	return "", l.errorf(tok.line, tok.column, "unterminated quoted token")
+}
+
+type abnfParser struct {
+	lex      *abnfLexer
+	g        *Grammar
+	problems *loadProblems
+
+	// the root rule declaration, and the id of the rule being parsed
+	root abnfToken
+	rule string
+}
+
+// Loads an SRGS ABNF document (see https://www.w3.org/TR/speech-grammar/#S1.6) into a grammar
+func (g *Grammar) LoadABNF(abnf string) error {
+	g.uri = ""
+	g.imports = newImports(g)
+	defer g.clearImports()
+
+	return g.loadABNF(abnf)
+}
+
+func (g *Grammar) loadABNF(abnf string) error {
+	g.Abnf = abnf
+
+	p := &abnfParser{lex: newAbnfLexer(abnf), g: g, problems: &loadProblems{file: g.uri}}
+
+	g.resetRules()
+	g.base = g.uri
+
+	rootId, err := p.parseHeader()
+
+	if err != nil {
+		p.report(err)
+
+		return p.problems.err()
+	}
+
+	for {
+		p.rule = ""
+		tok, err := p.lex.peek()
+
+		if err != nil {
+			p.report(err)
+			break
+		}
+
+		if tok.typ == abnfEOF {
+			break
+		}
+
+		id, scope, exp, err := p.parseRule()
+
+		if err != nil {
+			p.report(err)
+
+			// define the rule anyway, so that references to it are not reported as well
+			if _, ok := g.rules[p.rule]; p.rule != "" && !ok {
+				g.addRule(p.rule, ScopePrivate, new(Void))
+			}
+
+			if !p.skipRule() {
+				break
+			}
+
+			continue
+		}
+
+		if _, ok := g.rules[id]; ok {
+			p.report(p.errorf(tok, "%w", DuplicateRule))
+			continue
+		}
+
+		g.addRule(id, scope, exp)
+	}
+
+	p.rule = ""
+
+	if rootId == "" && !g.imported() {
+		p.report(NoRoot)
+	}
+
+	p.problems.checkRefs()
+
+	// unresolved rule references have already been reported where they were made
+	g.ruleRefs = make(RuleRefs)
+
+	if err := g.link(rootId); err != nil && rootId != "" {
+		p.report(p.errorf(p.root, "%w: %s", err, rootId))
+	}
+
+	return p.problems.err()
+}
+
+// Reports a problem in the rule being parsed
+func (p *abnfParser) report(err error) {
+	at := GrammarError{Rule: p.rule}
+
+	if e, ok := err.(*GrammarError); ok {
+		at.Line, at.Column = e.Line, e.Column
+		err = e.Err
+	}
+
+	p.problems.add(at, err)
+}
+
+// Skips the rest of a rule which could not be parsed, up to and including the ; which ends it, so that the following
+// rules can still be checked. Returns false if the rest of the document cannot be tokenized.
+func (p *abnfParser) skipRule() bool {
+	for p.lex.err == nil {
+		tok, err := p.lex.next()
+
+		if err != nil {
+			p.report(err)
+			return false
+		}
+
+		if tok.typ == abnfEOF || (tok.typ == abnfPunct && tok.text == ";") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *abnfParser) errorf(tok abnfToken, format string, args ...interface{}) error {
+	return p.lex.errorf(tok.line, tok.column, format, args...)
+}
+
+func (p *abnfParser) describe(tok abnfToken) string {
+	switch tok.typ {
+	case abnfEOF:
+		return "end of grammar"
+	case abnfRuleRef:
+		return "$" + tok.text
+	case abnfTag:
+		return "tag {" + tok.text + "}"
+	case abnfAngle:
+		return "<" + tok.text + ">"
+	case abnfWeight:
+		return "/" + tok.text + "/"
+	case abnfLanguage:
+		return "!" + tok.text
+	}
+
+	return `"` + tok.text + `"`
+}
+
+func (p *abnfParser) expect(typ abnfTokenType, text string) (abnfToken, error) {
+	tok, err := p.lex.next()
+
+	if err != nil {
+		return tok, err
+	}
+
+	if tok.typ != typ || (text != "" && tok.text != text) {
+		return tok, p.errorf(tok, "unexpected %s", p.describe(tok))
+	}
+
+	return tok, nil
+}
+
+// Parses the self-identifying header and declarations, and returns the root rule id if one is declared
+func (p *abnfParser) parseHeader() (string, error) {
+	tok, err := p.lex.next()
+
+	if err != nil {
+		return "", err
+	}
+
+	if tok.typ != abnfWord || tok.text != "#ABNF" {
+		return "", InvalidGrammar
+	}
+
+	version, err := p.expect(abnfWord, "")
+
+	if err != nil {
+		return "", err
+	}
+
+	if version.text != "1.0" {
+		return "", p.errorf(version, "unsupported ABNF version %s", version.text)
+	}
+
+	// optional character encoding
+	if tok, err = p.lex.peek(); err == nil && tok.typ == abnfWord {
+		p.lex.next()
+	}
+
+	if _, err = p.expect(abnfPunct, ";"); err != nil {
+		return "", err
+	}
+
+	rootId := ""
+
+	for {
+		tok, err := p.lex.peek()
+
+		if err != nil {
+			return "", err
+		}
+
+		if tok.typ == abnfTag {
+			// grammar-level tags are not evaluated
+			p.lex.next()
+		} else if tok.typ != abnfWord || tok.text == "public" || tok.text == "private" {
+			return rootId, nil
+		} else {
+			p.lex.next()
+
+			switch tok.text {
+			case "language", "mode":
+				var value abnfToken
+				if value, err = p.expect(abnfWord, ""); err == nil && tok.text == "language" {
+					p.g.lang = value.text
+				} else if err == nil {
+					p.g.mode = value.text
+				}
+			case "root":
+				if p.root, err = p.expect(abnfRuleRef, ""); err == nil {
+					rootId = p.root.text
+				}
+			case "base":
+				var uri abnfToken
+				if uri, err = p.expect(abnfAngle, ""); err == nil {
+					p.g.base = uri.text
+				}
+			case "tag-format":
+				var format abnfToken
+				if format, err = p.expect(abnfAngle, ""); err == nil {
+					p.g.tagFormat = format.text
+				}
+			case "lexicon":
+				_, err = p.expect(abnfAngle, "")
+			case "meta", "http-equiv":
+				if _, err = p.expect(abnfQuoted, ""); err == nil {
+					if _, err = p.expect(abnfWord, "is"); err == nil {
+						_, err = p.expect(abnfQuoted, "")
+					}
+				}
+			default:
+				return "", p.errorf(tok, "unknown declaration %s", tok.text)
+			}
+
+			if err != nil {
+				return "", err
+			}
+		}
+
+		if _, err := p.expect(abnfPunct, ";"); err != nil {
+			return "", err
+		}
+	}
+}
+
+// Parses a rule definition such as "public $rule = expansion;"
+func (p *abnfParser) parseRule() (string, RuleScope, Expansion, error) {
+	tok, err := p.lex.next()
+
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	scope := ScopePrivate
+
+	if tok.typ == abnfWord && (tok.text == "public" || tok.text == "private") {
+		scope = RuleScope(tok.text)
+
+		if tok, err = p.lex.next(); err != nil {
+			return "", "", nil, err
+		}
+	}
+
+	if tok.typ != abnfRuleRef || tok.text[0] == '<' {
+		return "", "", nil, p.errorf(tok, "expected a rule definition but found %s", p.describe(tok))
+	}
+
+	id := tok.text
+	p.rule = id
+
+	if _, err = p.expect(abnfPunct, "="); err != nil {
+		return "", "", nil, err
+	}
+
+	exp, err := p.parseAlternatives()
+
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	if _, err = p.expect(abnfPunct, ";"); err != nil {
+		return "", "", nil, err
+	}
+
+	return id, scope, exp, nil
+}
+
+// Parses a sequence, or a list of weighted alternative sequences separated by |
+func (p *abnfParser) parseAlternatives() (Expansion, error) {
+	var items []*Item
+
+	for {
+		tok, err := p.lex.peek()
+
+		if err != nil {
+			return nil, err
+		}
+
+		weight := 1.0
+
+		if tok.typ == abnfWeight {
+			p.lex.next()
+
+			if weight, err = parseWeight(tok.text); err != nil {
+				return nil, p.errorf(tok, "%w", err)
+			}
+		}
+
+		seq, err := p.parseSequence()
+
+		if err != nil {
+			return nil, err
+		}
+
+		item := NewItem(seq, RepeatModeNormal, 1, 1)
+		item.weight = weight
+		items = append(items, item)
+
+		if tok, err = p.lex.peek(); err != nil {
+			return nil, err
+		}
+
+		if tok.typ != abnfPunct || tok.text != "|" {
+			break
+		}
+
+		p.lex.next()
+	}
+
+	if len(items) == 1 {
+		return items[0].child, nil
+	}
+
+	alt := new(Alternative)
+	for _, item := range items {
+		alt.items = append(alt.items, item)
+	}
+
+	return alt, nil
+}
+
+// Parses a sequence of units, each of which may be followed by a repeat operator and language attachment
+func (p *abnfParser) parseSequence() (Expansion, error) {
+	out := new(Sequence)
+
+	for {
+		tok, err := p.lex.peek()
+
+		if err != nil {
+			return nil, err
+		}
+
+		var exp Expansion
+
+		switch tok.typ {
+		case abnfWord:
+			p.lex.next()
+			exp = NewToken(p.g.Normalize(tok.text))
+		case abnfQuoted:
+			p.lex.next()
+			exp = NewToken(p.g.Normalize(tok.text))
+		case abnfRuleRef:
+			p.lex.next()
+			if exp, err = p.ruleRef(tok); err != nil {
+				return nil, err
+			}
+		case abnfTag:
+			p.lex.next()
+			out.exps = append(out.exps, p.g.newTag(tok.text))
+			continue
+		case abnfPunct:
+			if tok.text == "(" || tok.text == "[" {
+				p.lex.next()
+				if exp, err = p.parseGroup(tok); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		if exp == nil {
+			break
+		}
+
+		if exp, err = p.parseRepeat(exp); err != nil {
+			return nil, err
+		}
+
+		out.exps = append(out.exps, exp)
+	}
+
+	if len(out.exps) == 0 {
+		tok, _ := p.lex.peek()
+		return nil, p.errorf(tok, "expected an expansion but found %s", p.describe(tok))
+	}
+
+	return out, nil
+}
+
+// Parses the contents of a (group) or [optional group], after the opening bracket
+func (p *abnfParser) parseGroup(open abnfToken) (Expansion, error) {
+	exp, err := p.parseAlternatives()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if open.text == "(" {
+		_, err = p.expect(abnfPunct, ")")
+		return exp, err
+	}
+
+	if _, err = p.expect(abnfPunct, "]"); err != nil {
+		return nil, err
+	}
+
+	return NewItem(exp, RepeatModeNormal, 0, 1), nil
+}
+
+func (p *abnfParser) ruleRef(tok abnfToken) (Expansion, error) {
+	switch tok.text {
+	case "NULL":
+		return new(Null), nil
+	case "VOID":
+		return new(Void), nil
+	case "GARBAGE":
+		return new(Garbage), nil
+	}
+
+	if tok.text[0] != '<' {
+		return p.localRuleRef(tok, tok.text), nil
+	}
+
+	uri := tok.text[1 : len(tok.text)-1]
+
+	if uri == "" {
+		return nil, p.errorf(tok, "%w", EmptyRuleRefUri)
+	}
+
+	if uri[0] != '#' {
+		ref, err := p.g.externalRuleRef(uri)
+
+		if err != nil {
+			return nil, p.errorf(tok, "%w", err)
+		}
+
+		return ref, nil
+	}
+
+	return p.localRuleRef(tok, uri[1:]), nil
+}
+
+func (p *abnfParser) localRuleRef(tok abnfToken, id string) *RuleRef {
+	ref := p.g.newRuleRef(id)
+	p.problems.addRef(ref, GrammarError{Line: tok.line, Column: tok.column, Rule: p.rule})
+
+	return ref
+}
+
+// Parses any repeat operator (e.g. <0-3 /0.5/>) and language attachment following a unit
+func (p *abnfParser) parseRepeat(exp Expansion) (Expansion, error) {
+	tok, err := p.lex.peek()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if tok.typ == abnfAngle {
+		p.lex.next()
+
+		repeat := strings.TrimSpace(tok.text)
+		repeatProb := -1.0
+
+		// the repeat probability is only allowed on the repeat operator itself
+		if ind := strings.Index(repeat, "/"); ind != -1 {
+			prob := strings.TrimSpace(repeat[ind:])
+
+			if len(prob) < 2 || prob[len(prob)-1] != '/' {
+				return nil, p.errorf(tok, "invalid repeat %s", p.describe(tok))
+			}
+
+			if repeatProb, err = parseRepeatProb(prob[1 : len(prob)-1]); err != nil {
+				return nil, p.errorf(tok, "%w", err)
+			}
+
+			repeat = strings.TrimSpace(repeat[:ind])
+		}
+
+		min, max, err := parseRepeat(strings.Replace(repeat, " ", "", -1))
+
+		if err != nil {
+			return nil, p.errorf(tok, "%w", err)
+		}
+
+		item := NewItem(exp, RepeatModeNormal, min, max)
+		item.repeatProb = repeatProb
+		exp = item
+
+		if tok, err = p.lex.peek(); err != nil {
+			return nil, err
+		}
+	}
+
+	if tok.typ == abnfLanguage {
+		// language attachments do not affect matching
+		p.lex.next()
+	}
+
+	return exp, nil
+}
@@ -0,0 +1,96 @@
+package srgs
+
+var digitsAbnf = `#ABNF 1.0 UTF-8;
+language en-US;
+mode voice;
+root $combined;
+tag-format <swi-semantics/1.0>;
+
+public $combined = ($quintet {out = rules.quintet.out;});
+
+$quintet = $digit $quartet {out = rules.digit.out + rules.quartet.out}
+	| $quartet {out = rules.quartet.out} $digit {out = out + rules.digit.out}
+	| $triplet {out = rules.triplet.out} $doublet {out = out + rules.doublet.out}
+	| $doublet {out = rules.doublet.out} $triplet {out = out + rules.triplet.out}
+	| four $digit {out = out + rule.digit.out + rule.digit.out + rule.digit.out + rule.digit.out;};
+
+$quartet = $digit {out = rules.digit.out;} thousand {out = rules.digit.out + "000";}
+	// 1 + 3
+	| $digit {out = rules.digit.out} $triplet {out = out + rules.triplet.out}
+	| $triplet {out = rules.triplet.out} $digit {out = out + rules.digit.out}
+	/* 2 + 2 */
+	| ($doublet {out = out ? out + rules.doublet.out : rules.doublet.out}) <2>
+	// quadruple 1
+	| $four $digit {out = "" + rules.digit.out + rules.digit.out + rules.digit.out + rules.digit.out};
+
+public $four = quad | quadruple;
+
+$triplet = $doublet {out = rules.doublet.out} $digit {out = out + rules.digit.out}
+	| $digit {out = rules.digit.out} $doublet {out = out + rules.doublet.out}
+	| triple $digit {out = rules.digit.out + rules.digit.out + rules.digit.out};
+
+$doublet = $digit {out = rules.digit.out} $digit {out = out + rules.digit.out}
+	| double $digit {out = rules.digit.out + rules.digit.out}
+	| (
+		ten {out = "10";}
+		| eleven {out = "11";}
+		| twelve {out = "12";}
+		| thirteen {out = "13";}
+		| fourteen {out = "14";}
+		| fifteen {out = "15";}
+		| sixteen {out = "16";}
+		| seventeen {out = "17";}
+		| eighteen {out = "18";}
+		| nineteen {out = "19";}
+	)
+	| $tens {out = rules.tens.tens;} $ones {out = out + rules.ones.ones;};
+
+$tens = twenty {out.tens = "2"; out="20";}
+	| thirty {out.tens = "3"; out="30";}
+	| forty {out.tens = "4"; out="40";}
+	| fifty {out.tens = "5"; out="50";}
+	| sixty {out.tens = "6"; out="60";}
+	| seventy {out.tens = "7"; out="70";}
+	| eighty {out.tens = "8"; out="80";}
+	| ninety {out.tens = "9"; out="90";};
+
+$ones = one {out.ones = "1";}
+	| two {out.ones = "2";}
+	| three {out.ones = "3";}
+	| four {out.ones = "4";}
+	| five {out.ones = "5";}
+	| six {out.ones = "6";}
+	| seven {out.ones = "7";}
+	| eight {out.ones = "8";}
+	| nine {out.ones = "9";};
+
+$digit = /0.1/ oh {out='0';}
+	| zero {out='0';}
+	| one {out='1';}
+	| two {out='2';}
+	| three {out='3';}
+	| four {out='4';}
+	| five {out='5';}
+	| six {out='6';}
+	| seven {out='7';}
+	| eight {out='8';}
+	| nine {out='9';};
+`
+
+var animalAbnf = `#ABNF 1.0 UTF-8;
+language en-US;
+root $example;
+tag-format <swi-semantics/1.0>;
+
+public $example = i am an $animal;
+
+$animal = antler | aardvark;
+`
+
+var nameAbnf = `#ABNF 1.0 UTF-8;
+language en-US;
+root $example;
+tag-format <swi-semantics/1.0>;
+
+$example = ("my name is" | my name) (is rob | ram | kaustav);
+`
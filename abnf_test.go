@@ -0,0 +1,197 @@
+package srgs
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLoadABNF_MatchesXml(t *testing.T) {
+	tests := []struct {
+		name   string
+		xml    string
+		abnf   string
+		inputs []string
+	}{
+		{
+			name: "animal",
+			xml:  animalXml,
+			abnf: animalAbnf,
+			inputs: []string{
+				"i am an antler", "i am an aardvark", "i am", "i am an", "i am an an", "i am an antler eater",
+				"i am an ape", "i an",
+			},
+		},
+		{
+			name:   "name",
+			xml:    nameXml,
+			abnf:   nameAbnf,
+			inputs: []string{"my name is rob", "my name is ram", "my name is kaustav", "my name", "my name rob"},
+		},
+		{
+			name: "digits",
+			xml:  digitsXml,
+			abnf: digitsAbnf,
+			inputs: []string{
+				"one two", "one two three four ", "two", "three five four one", "two three four five",
+				"six five four three two two", "on", "fix", "one two three four five", "triple three four five",
+				"quad five", "one thousand", "twenty one thirty two five", "double one fifteen nine",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			x := NewGrammar()
+			if !assert.Nil(x.LoadXml(test.xml)) {
+				return
+			}
+
+			a := NewGrammar()
+			if !assert.Nil(a.LoadABNF(test.abnf)) {
+				return
+			}
+
+			for _, input := range test.inputs {
+				assert.Equal(x.HasPrefix(input), a.HasPrefix(input), "HasPrefix(%q)", input)
+				assert.Equal(x.HasMatch(input), a.HasMatch(input), "HasMatch(%q)", input)
+
+				xp, ap := new(SISRProcessor), new(SISRProcessor)
+				xErr, aErr := x.GetMatch(input, xp), a.GetMatch(input, ap)
+				assert.Equal(xErr, aErr, "GetMatch(%q)", input)
+
+				if xErr != nil {
+					continue
+				}
+
+				assert.Equal(xp.GetInterpretation(), ap.GetInterpretation(), "GetMatch(%q)", input)
+
+				xOut, xErr := xp.GetInstance()
+				aOut, aErr := ap.GetInstance()
+				assert.Equal(xOut, aOut, "GetInstance(%q)", input)
+				if xErr != nil && assert.NotNil(aErr, "GetInstance(%q)", input) {
+					assert.Equal(xErr.Error(), aErr.Error(), "GetInstance(%q)", input)
+				} else {
+					assert.Nil(aErr, "GetInstance(%q)", input)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadABNF_Syntax(t *testing.T) {
+	assert := assert.New(t)
+
+	abnf := `#ABNF 1.0;
+// header declarations
+language en-US;
+mode voice;
+root $order;
+tag-format <semantics/1.0>;
+base <http://example.com/grammars/>;
+lexicon <lexicon.pls>;
+meta "author" is "someone";
+http-equiv "Expires" is "0";
+{!{ var drinks = 0; }!};
+
+/* the rule the grammar is matched against */
+public $order = [please] i would like $NULL $drink <1-3 /0.5/> [$GARBAGE] {!{ out = {drink: rules.drink.out}; }!};
+
+private $drink = /10/ coffee {out = "coffee";}
+	| /2.5/ "iced   tea" !en-US {out = "tea";}
+	| water $VOID;
+`
+
+	g := NewGrammar()
+	if !assert.Nil(g.LoadABNF(abnf)) {
+		return
+	}
+
+	assert.Equal(abnf, g.Abnf)
+
+	assert.True(g.HasMatch("i would like coffee"))
+	assert.True(g.HasMatch("please i would like iced tea"))
+	assert.True(g.HasMatch("i would like coffee coffee coffee"))
+	assert.True(g.HasMatch("i would like coffee right now"))
+	assert.True(g.HasPrefix("please i would like ic"))
+	assert.False(g.HasMatch("i would like water"))
+	assert.False(g.HasMatch("i would like"))
+
+	p := new(SISRProcessor)
+	if !assert.Nil(g.GetMatch("please i would like iced tea", p)) {
+		return
+	}
+
+	assert.Equal("please i would like iced tea", p.GetInterpretation())
+}
+
+func TestLoadABNF_OpenRepeats(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGrammar()
+	if !assert.Nil(g.LoadABNF("#ABNF 1.0;\nroot $number;\n$number = number (one | two) <1-> point <0- /0.5/>;")) {
+		return
+	}
+
+	assert.True(g.HasMatch("number one point"))
+	assert.True(g.HasMatch("number one two one two point point point"))
+	assert.False(g.HasMatch("number point"))
+	assert.True(g.HasPrefix("number one two two one po"))
+}
+
+func TestLoadABNF_Errors(t *testing.T) {
+	assert := assert.New(t)
+
+	load := func(abnf string) error {
+		return NewGrammar().LoadABNF(abnf)
+	}
+
+	assert.True(errors.Is(load(`<grammar root="example"></grammar>`), InvalidGrammar))
+	assert.True(errors.Is(load("#ABNF 1.0;\n$example = hello;"), NoRoot))
+	assert.True(errors.Is(load("#ABNF 1.0;\nroot $missing;\n$example = hello;"), RootNotFound))
+	assert.EqualError(load("#ABNF 1.0;\nroot $missing;\n$example = hello;"),
+		"line 2, column 6: unable to find root rule: missing")
+	assert.EqualError(load("#ABNF 1.0;\nroot $example;\n$example = hello $world;"),
+		"line 3, column 18, rule example: unable to find rule: world")
+	assert.EqualError(load("#ABNF 1.0;\nroot $example;\n$example = hello <2-1>;"),
+		`line 3, column 18, rule example: invalid repeat 2-1`)
+	assert.EqualError(load("#ABNF 1.0;\nroot $example;\n$example = hello {out = 1;"),
+		"line 3, column 18, rule example: unterminated tag")
+	assert.EqualError(load("#ABNF 1.0;\nroot $example;\n$example = hello;\n$example = world;"),
+		"line 4, column 1, rule example: rule is defined more than once")
+	assert.EqualError(load("#ABNF 1.0;\nroot $example;\n$example = ;"),
+		`line 3, column 12, rule example: expected an expansion but found ";"`)
+	assert.EqualError(load("#ABNF 1.0;\nroot $example;\n$example = $<common.gram#digit>;"),
+		"line 3, column 12, rule example: cannot understand ruleref uri common.gram#digit because it is not local")
+	assert.EqualError(load("#ABNF 2.0;"), "line 1, column 7: unsupported ABNF version 2.0")
+
+	// every problem in the document is reported, not just the first
+	err := load(`#ABNF 1.0;
+root $example;
+$example = hello $world | $other;
+$other = <1-> | /-1/ goodbye;
+public $fine = ok;
+$fine = again;
+`)
+
+	var problems GrammarErrors
+	if !assert.True(errors.As(err, &problems)) {
+		return
+	}
+
+	assert.Len(problems, 3)
+	assert.EqualError(err, `line 3, column 18, rule example: unable to find rule: world
+line 4, column 10, rule other: expected an expansion but found <1->
+line 6, column 1, rule fine: rule is defined more than once`)
+	assert.True(errors.Is(err, RuleNotFound))
+	assert.True(errors.Is(err, DuplicateRule))
+
+	var first *GrammarError
+	if assert.True(errors.As(err, &first)) {
+		assert.Equal(3, first.Line)
+		assert.Equal(18, first.Column)
+		assert.Equal("example", first.Rule)
+	}
+}
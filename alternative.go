@@ -2,27 +2,58 @@ package srgs
 
 type Alternative struct {
 	items []Expansion
+}
 
-	str        string
-	currentInd int
+func NewAlternative(items ...Expansion) *Alternative {
+	return &Alternative{items: items}
 }
 
-func (a *Alternative) Copy(r RuleRefs) Expansion {
-	out := new(Alternative)
-	out.items = make([]Expansion, len(a.items))
+// Implements Expansion NewMatcher method
+func (a *Alternative) NewMatcher() Matcher {
+	out := new(alternativeMatcher)
+	out.items = make([]Matcher, len(a.items))
+	out.weights = a.probabilities()
 
 	for ind, e := range a.items {
-		out.items[ind] = e.Copy(r)
+		out.items[ind] = e.NewMatcher()
 	}
 
 	return out
 }
 
-func NewAlternative(items ...Expansion) *Alternative {
-	return &Alternative{items: items}
+// Returns the probability of each alternative, which is its weight normalized over the weights of all of them
+func (a *Alternative) probabilities() []float64 {
+	weights := make([]float64, len(a.items))
+
+	total := 0.0
+	for ind, e := range a.items {
+		weights[ind] = 1
+
+		if item, ok := e.(*Item); ok {
+			weights[ind] = item.weight
+		}
+
+		total += weights[ind]
+	}
+
+	for ind := range weights {
+		if total > 0 {
+			weights[ind] /= total
+		}
+	}
+
+	return weights
+}
+
+type alternativeMatcher struct {
+	items   []Matcher
+	weights []float64
+
+	str        string
+	currentInd int
 }
 
-func (a *Alternative) Match(str string, mode MatchMode) {
+func (a *alternativeMatcher) Match(str string, mode MatchMode) {
 	a.str = str
 	a.currentInd = 0
 
@@ -30,7 +61,7 @@ func (a *Alternative) Match(str string, mode MatchMode) {
 		i.Match(str, mode)
 	}
 }
-func (a *Alternative) Next() (string, error) {
+func (a *alternativeMatcher) Next() (string, error) {
 	outErr := NoMatch
 	for i := a.currentInd; i < len(a.items); i++ {
 		var str string
@@ -49,6 +80,10 @@ func (a *Alternative) Next() (string, error) {
 	return "", outErr
 }
 
-func (a *Alternative) Scan(p Processor) {
+func (a *alternativeMatcher) Score() float64 {
+	return a.weights[a.currentInd] * a.items[a.currentInd].Score()
+}
+
+func (a *alternativeMatcher) Scan(p Processor) {
 	a.items[a.currentInd].Scan(p)
 }
@@ -9,13 +9,13 @@ func TestAlternative_Match(t *testing.T) {
 	assert := assert.New(t)
 
 	alt := NewAlternative(
-		NewItem(NewToken("rob"), 1, 1, make(RuleRefs)),
-		NewItem(NewToken("rob"), 1, 1, make(RuleRefs)),
-		NewItem(NewToken("ram"), 1, 1, make(RuleRefs)),
-		NewItem(NewToken("ram malav"), 1, 1, make(RuleRefs)),
-		NewItem(NewToken("kaustav"), 1, 1, make(RuleRefs)),
-		NewItem(NewToken("kaustav datta"), 1, 1, make(RuleRefs)),
-	)
+		NewItem(NewToken("rob"), RepeatModeNormal, 1, 1),
+		NewItem(NewToken("rob"), RepeatModeNormal, 1, 1),
+		NewItem(NewToken("ram"), RepeatModeNormal, 1, 1),
+		NewItem(NewToken("ram malav"), RepeatModeNormal, 1, 1),
+		NewItem(NewToken("kaustav"), RepeatModeNormal, 1, 1),
+		NewItem(NewToken("kaustav datta"), RepeatModeNormal, 1, 1),
+	).NewMatcher()
 
 	alt.Match("kaustav", ModeExact)
 	_, err := alt.Next()
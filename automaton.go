@@ -0,0 +1,534 @@
+package srgs
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	SelfEmbedding = errors.New("self-embedding rules cannot be compiled into an automaton")
+	TooManyStates = errors.New("automaton has too many states")
+)
+
+// The most states a compiled automaton may have
+const maxAutomatonStates = 1 << 16
+
+// An Automaton is a deterministic finite-state automaton over the words of a grammar, which matches a string in time
+// linear in its number of words
+type Automaton struct {
+	states []dfaState
+}
+
+type dfaState struct {
+	// the state reached by each word
+	next map[string]int
+
+	// the state reached by any word not in next (because of GARBAGE), or -1. A word in next which leads to -1 is a
+	// stop word, which the GARBAGE cannot consume.
+	other int
+
+	final bool
+}
+
+// Compiles the grammar into an Automaton, which HasPrefix and HasMatch then use instead of matching the expansion tree.
+// Left and right recursive rules are compiled into loops, but rules which are self-embedding (such as
+// $rule = a $rule b) cannot be represented by an automaton and return a SelfEmbedding error. Compile must be called
+// before the grammar is shared between goroutines.
+func (g *Grammar) Compile() error {
+	a, err := compileAutomaton(g.Root)
+
+	if err != nil {
+		return err
+	}
+
+	g.automaton = a
+
+	return nil
+}
+
+// Returns whether a specific string, in the normal form of the grammar, is a prefix of the automaton's grammar. See
+// Grammar.HasPrefix
+func (a *Automaton) HasPrefix(str string) bool {
+	words := strings.Split(str, " ")
+	partial := words[len(words)-1]
+
+	s := a.run(words[:len(words)-1])
+
+	if s == -1 {
+		return false
+	}
+
+	if partial == "" || a.states[s].other != -1 {
+		return true
+	}
+
+	for word, next := range a.states[s].next {
+		if next != -1 && strings.HasPrefix(word, partial) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns whether a specific string, in the normal form of the grammar, is an exact match for the automaton's grammar.
+// See Grammar.HasMatch
+func (a *Automaton) HasMatch(str string) bool {
+	words := strings.Split(str, " ")
+
+	// a single trailing space is consumed along with the last word
+	if len(words) > 1 && words[len(words)-1] == "" {
+		words = words[:len(words)-1]
+	}
+
+	s := a.run(words)
+
+	return s != -1 && a.states[s].final
+}
+
+// Returns the number of states of the automaton
+func (a *Automaton) NumStates() int {
+	return len(a.states)
+}
+
+// Returns the state reached from the start state by a list of words, or -1 if there is none
+func (a *Automaton) run(words []string) int {
+	if len(a.states) == 0 {
+		return -1
+	}
+
+	s := 0
+
+	for _, word := range words {
+		if word == "" && len(words) == 1 {
+			break
+		}
+
+		next, ok := a.states[s].next[word]
+
+		if !ok {
+			next = a.states[s].other
+		}
+
+		if next == -1 {
+			return -1
+		}
+
+		s = next
+	}
+
+	return s
+}
+
+type nfaEdge struct {
+	// the word consumed by the edge. Epsilon edges consume nothing, and wildcard edges consume any word except the
+	// words in except.
+	word     string
+	epsilon  bool
+	wildcard bool
+	except   map[string]bool
+
+	to int
+}
+
+// A rule being compiled, with the states its compiled expansion starts and ends at
+type nfaFrame struct {
+	ruleId string
+	rule   Expansion
+
+	entry int
+	exit  int
+}
+
+type nfaBuilder struct {
+	edges [][]nfaEdge
+	stack []nfaFrame
+}
+
+func (b *nfaBuilder) newState() int {
+	b.edges = append(b.edges, nil)
+	return len(b.edges) - 1
+}
+
+func (b *nfaBuilder) addEdge(from int, e nfaEdge) {
+	b.edges[from] = append(b.edges[from], e)
+}
+
+// Adds the states for an expansion starting at from, and returns the state it ends at. head and tail are the index of
+// the outermost rule on the stack for which the expansion is at the very start or end of the rule (len(b.stack) if
+// none), which is where a recursive reference to that rule can be compiled into a loop.
+func (b *nfaBuilder) build(exp Expansion, from, head, tail int) (int, error) {
+	switch e := exp.(type) {
+	case *Token:
+		for _, word := range strings.Split(e.token, " ") {
+			to := b.newState()
+			b.addEdge(from, nfaEdge{word: word, to: to})
+			from = to
+		}
+
+		return from, nil
+	case *Sequence:
+		var err error
+
+		for i, exp := range e.exps {
+			h, t := head, tail
+
+			for _, prev := range e.exps[:i] {
+				if consumes(prev) {
+					h = len(b.stack)
+				}
+			}
+
+			for _, next := range e.exps[i+1:] {
+				if consumes(next) {
+					t = len(b.stack)
+				}
+			}
+
+			if from, err = b.build(exp, from, h, t); err != nil {
+				return 0, err
+			}
+		}
+
+		return from, nil
+	case *Alternative:
+		to := b.newState()
+
+		for _, item := range e.items {
+			end, err := b.build(item, from, head, tail)
+
+			if err != nil {
+				return 0, err
+			}
+
+			b.addEdge(end, nfaEdge{epsilon: true, to: to})
+		}
+
+		return to, nil
+	case *Item:
+		to := b.newState()
+		repeats := e.repeatMax
+
+		// an unbounded item is built as its minimum repeats followed by a loop
+		if repeats == RepeatUnbounded {
+			repeats = e.repeatMin
+		}
+
+		for i := 0; i < repeats; i++ {
+			if i >= e.repeatMin {
+				b.addEdge(from, nfaEdge{epsilon: true, to: to})
+			}
+
+			h, t := head, tail
+
+			if i > 0 {
+				h = len(b.stack)
+			}
+
+			if e.repeatMax != 1 {
+				t = len(b.stack)
+			}
+
+			var err error
+			if from, err = b.build(e.child, from, h, t); err != nil {
+				return 0, err
+			}
+		}
+
+		if e.repeatMax == RepeatUnbounded {
+			loop := b.newState()
+			b.addEdge(from, nfaEdge{epsilon: true, to: loop})
+
+			end, err := b.build(e.child, loop, len(b.stack), len(b.stack))
+
+			if err != nil {
+				return 0, err
+			}
+
+			b.addEdge(end, nfaEdge{epsilon: true, to: loop})
+			from = loop
+		}
+
+		b.addEdge(from, nfaEdge{epsilon: true, to: to})
+
+		return to, nil
+	case *RuleRef:
+		return b.ruleRef(e, from, head, tail)
+	case *Garbage:
+		for i := 0; i < e.minWords; i++ {
+			to := b.newState()
+			b.addEdge(from, nfaEdge{wildcard: true, except: e.stopWords, to: to})
+			from = to
+		}
+
+		if e.maxWords <= 0 {
+			loop := b.newState()
+			b.addEdge(from, nfaEdge{epsilon: true, to: loop})
+			b.addEdge(loop, nfaEdge{wildcard: true, except: e.stopWords, to: loop})
+
+			return loop, nil
+		}
+
+		end := b.newState()
+		b.addEdge(from, nfaEdge{epsilon: true, to: end})
+
+		for i := e.minWords; i < e.maxWords; i++ {
+			to := b.newState()
+			b.addEdge(from, nfaEdge{wildcard: true, except: e.stopWords, to: to})
+			b.addEdge(to, nfaEdge{epsilon: true, to: end})
+			from = to
+		}
+
+		return end, nil
+	case *Void:
+		// nothing can follow a VOID, so the state it ends at is never reached
+		return b.newState(), nil
+	}
+
+	// tags and NULL consume nothing
+	return from, nil
+}
+
+func (b *nfaBuilder) ruleRef(r *RuleRef, from, head, tail int) (int, error) {
+	for i, frame := range b.stack {
+		if frame.rule != r.rule {
+			continue
+		}
+
+		// A left recursive reference matches whatever the rule itself matches, so it continues from the rule's exit
+		if head <= i {
+			to := b.newState()
+			b.addEdge(frame.exit, nfaEdge{epsilon: true, to: to})
+
+			return to, nil
+		}
+
+		// A right recursive reference starts the rule over, and ends wherever the rule ends
+		if tail <= i {
+			b.addEdge(from, nfaEdge{epsilon: true, to: frame.entry})
+
+			return b.newState(), nil
+		}
+
+		return 0, fmt.Errorf("%w: %s", SelfEmbedding, r.ruleId)
+	}
+
+	// Each reference gets its own entry and exit states, so that a recursive reference back to the rule only loops
+	// within this reference
+	frame := nfaFrame{ruleId: r.ruleId, rule: r.rule, entry: b.newState(), exit: b.newState()}
+	b.addEdge(from, nfaEdge{epsilon: true, to: frame.entry})
+
+	b.stack = append(b.stack, frame)
+	end, err := b.build(r.rule, frame.entry, head, tail)
+	b.stack = b.stack[:len(b.stack)-1]
+
+	if err != nil {
+		return 0, err
+	}
+
+	b.addEdge(end, nfaEdge{epsilon: true, to: frame.exit})
+
+	return frame.exit, nil
+}
+
+// Returns whether an expansion consumes any words, ignoring the expansions which never do
+func consumes(exp Expansion) bool {
+	switch exp.(type) {
+	case *Tag, *Null:
+		return false
+	}
+
+	return true
+}
+
+func compileAutomaton(root *RuleRef) (*Automaton, error) {
+	b := new(nfaBuilder)
+
+	start := b.newState()
+	end, err := b.build(root, start, 0, 0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	live := b.live(end)
+
+	d := &dfaBuilder{nfa: b, live: live, ids: make(map[string]int), end: end}
+
+	if _, err := d.state(d.closure([]int{start})); err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < len(d.sets); i++ {
+		if err := d.expand(i); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Automaton{states: d.states}, nil
+}
+
+// Returns the states from which the end state can be reached
+func (b *nfaBuilder) live(end int) []bool {
+	reverse := make([][]int, len(b.edges))
+
+	for from, edges := range b.edges {
+		for _, e := range edges {
+			reverse[e.to] = append(reverse[e.to], from)
+		}
+	}
+
+	live := make([]bool, len(b.edges))
+	live[end] = true
+	queue := []int{end}
+
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+
+		for _, from := range reverse[s] {
+			if !live[from] {
+				live[from] = true
+				queue = append(queue, from)
+			}
+		}
+	}
+
+	return live
+}
+
+// Determinizes the NFA with the subset construction
+type dfaBuilder struct {
+	nfa  *nfaBuilder
+	live []bool
+	end  int
+
+	sets   [][]int
+	ids    map[string]int
+	states []dfaState
+}
+
+// Returns the sorted epsilon closure of a set of live NFA states
+func (d *dfaBuilder) closure(set []int) []int {
+	seen := make(map[int]bool)
+	var out []int
+
+	for len(set) > 0 {
+		s := set[len(set)-1]
+		set = set[:len(set)-1]
+
+		if seen[s] || !d.live[s] {
+			continue
+		}
+
+		seen[s] = true
+		out = append(out, s)
+
+		for _, e := range d.nfa.edges[s] {
+			if e.epsilon {
+				set = append(set, e.to)
+			}
+		}
+	}
+
+	sort.Ints(out)
+
+	return out
+}
+
+// Returns the id of the DFA state for a closed set of NFA states, adding it if it is new
+func (d *dfaBuilder) state(set []int) (int, error) {
+	if len(set) == 0 {
+		return -1, nil
+	}
+
+	var key strings.Builder
+	for _, s := range set {
+		key.WriteString(strconv.Itoa(s))
+		key.WriteByte(',')
+	}
+
+	if id, ok := d.ids[key.String()]; ok {
+		return id, nil
+	}
+
+	if len(d.states) >= maxAutomatonStates {
+		return -1, TooManyStates
+	}
+
+	id := len(d.states)
+	d.ids[key.String()] = id
+	d.sets = append(d.sets, set)
+
+	state := dfaState{next: make(map[string]int), other: -1}
+	for _, s := range set {
+		if s == d.end {
+			state.final = true
+		}
+	}
+
+	d.states = append(d.states, state)
+
+	return id, nil
+}
+
+// Adds the transitions of a DFA state
+func (d *dfaBuilder) expand(id int) error {
+	moves := make(map[string][]int)
+	var wildcards []nfaEdge
+
+	for _, s := range d.sets[id] {
+		for _, e := range d.nfa.edges[s] {
+			if e.wildcard {
+				wildcards = append(wildcards, e)
+			} else if !e.epsilon {
+				moves[e.word] = append(moves[e.word], e.to)
+			}
+		}
+	}
+
+	// a word which a wildcard cannot consume needs its own transition, even if it only leads to -1
+	for _, e := range wildcards {
+		for word := range e.except {
+			if _, ok := moves[word]; !ok {
+				moves[word] = nil
+			}
+		}
+	}
+
+	var err error
+
+	for word, to := range moves {
+		// the word can also be consumed by any wildcard which does not except it
+		for _, e := range wildcards {
+			if !e.except[word] {
+				to = append(to, e.to)
+			}
+		}
+
+		if d.states[id].next[word], err = d.state(d.closure(to)); err != nil {
+			return err
+		}
+
+		if d.states[id].next[word] == -1 && len(wildcards) == 0 {
+			delete(d.states[id].next, word)
+		}
+	}
+
+	if len(wildcards) > 0 {
+		var to []int
+
+		for _, e := range wildcards {
+			to = append(to, e.to)
+		}
+
+		if d.states[id].other, err = d.state(d.closure(to)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
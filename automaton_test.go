@@ -0,0 +1,218 @@
+package srgs
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var garbageXml = `<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" xml:lang="en-US" root="example">
+	<rule id="example">
+		<ruleref special="GARBAGE" />
+		<one-of>
+			<item>ten</item>
+			<item>fifteen</item>
+		</one-of>
+		years old
+	</rule>
+</grammar>
+`
+
+func TestAutomaton_AgreesWithMatcher(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		xml    string
+		inputs []string
+	}{
+		{animalXml, []string{
+			"", "i", "i am an", "i am an an", "i am an antler", "i am an aardvark", "i am an antler ",
+			"i am an antler eater", "i am an ape", "i an",
+		}},
+		{nameXml, []string{
+			"my name", "my name is", "my name is rob", "my name is ram", "my name is kaustav", "my name ram",
+			"my name is is", "my name rob",
+		}},
+		{digitsXml, []string{
+			"", "on", "one two", "one two three four ", "three five four one", "one two three four five",
+			"one two three four", "six five four three two two", "fix", "one two three four fix",
+			"double four", "quadruple", "twenty one", "nineteen eighty four",
+		}},
+		{garbageXml, []string{
+			"", "ten years old", "i am ten years old", "i am ten", "i am nine", "i am nine years old",
+			"i am ten years", "fifteen years old", "ten years old ", "ten years young",
+		}},
+	}
+
+	for _, test := range tests {
+		g := NewGrammar()
+		if !assert.Nil(g.LoadXml(test.xml)) {
+			continue
+		}
+
+		compiled := NewGrammar()
+		compiled.LoadXml(test.xml)
+		if !assert.Nil(compiled.Compile()) {
+			continue
+		}
+
+		for _, input := range test.inputs {
+			assert.Equal(g.HasPrefix(input), compiled.HasPrefix(input), "HasPrefix(%q)", input)
+			assert.Equal(g.HasMatch(input), compiled.HasMatch(input), "HasMatch(%q)", input)
+		}
+	}
+}
+
+func TestAutomaton_Recursion(t *testing.T) {
+	assert := assert.New(t)
+
+	abnf := `#ABNF 1.0 UTF-8;
+root $start;
+
+public $start = $left please | go $right | $a;
+$left = $left and $noun | $noun;
+$noun = apples | pears;
+$right = on $right | stop;
+$a = x $b | done;
+$b = y $a;
+`
+
+	g := NewGrammar()
+	if !assert.Nil(g.LoadABNF(abnf)) {
+		return
+	}
+
+	if !assert.Nil(g.Compile()) {
+		return
+	}
+
+	assert.True(g.HasMatch("apples please"))
+	assert.True(g.HasMatch("apples and pears and apples please"))
+	assert.True(g.HasPrefix("apples and pe"))
+	assert.False(g.HasMatch("apples and please"))
+	assert.False(g.HasMatch("and apples please"))
+
+	assert.True(g.HasMatch("go stop"))
+	assert.True(g.HasMatch("go on on on stop"))
+	assert.False(g.HasMatch("go on on"))
+	assert.True(g.HasPrefix("go on on"))
+
+	assert.True(g.HasMatch("x y x y done"))
+	assert.False(g.HasMatch("x y x done"))
+}
+
+func TestAutomaton_SelfEmbedding(t *testing.T) {
+	assert := assert.New(t)
+
+	abnf := `#ABNF 1.0 UTF-8;
+root $brackets;
+
+public $brackets = open $brackets close | nothing;
+`
+
+	g := NewGrammar()
+	if !assert.Nil(g.LoadABNF(abnf)) {
+		return
+	}
+
+	err := g.Compile()
+	assert.True(errors.Is(err, SelfEmbedding))
+	assert.Contains(err.Error(), "brackets")
+
+	// the grammar can still be matched without an automaton
+	assert.True(g.HasMatch("open open nothing close close"))
+}
+
+func TestAutomaton_Reload(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGrammar()
+	g.LoadXml(animalXml)
+	assert.Nil(g.Compile())
+	assert.True(g.HasMatch("i am an antler"))
+
+	// loading another grammar drops the automaton
+	g.LoadXml(nameXml)
+	assert.False(g.HasMatch("i am an antler"))
+	assert.True(g.HasMatch("my name is rob"))
+}
+
+func newCompiledGrammar(b *testing.B, xml string) *Grammar {
+	g := NewGrammar()
+	g.LoadXml(xml)
+
+	if err := g.Compile(); err != nil {
+		b.Fatal(err)
+	}
+
+	return g
+}
+
+func benchmarkAutomaton(b *testing.B, g *Grammar, str string, mode MatchMode) {
+	b.ResetTimer()
+
+	var out bool
+	for i := 0; i < b.N; i++ {
+		if mode == ModePrefix {
+			out = g.HasPrefix(str)
+		} else {
+			out = g.HasMatch(str)
+		}
+	}
+
+	match = out
+}
+
+func BenchmarkCompile(b *testing.B) {
+	g := NewGrammar()
+	g.LoadXml(digitsXml)
+
+	for i := 0; i < b.N; i++ {
+		if err := g.Compile(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+func BenchmarkAutomatonDigitsMatchOneTwo(b *testing.B) {
+	benchmarkAutomaton(b, newCompiledGrammar(b, digitsXml), "one two", ModeExact)
+}
+func BenchmarkAutomatonDigitsPrefixOneTwo(b *testing.B) {
+	benchmarkAutomaton(b, newCompiledGrammar(b, digitsXml), "one two", ModePrefix)
+}
+func BenchmarkAutomatonDigitsMatchFoo(b *testing.B) {
+	benchmarkAutomaton(b, newCompiledGrammar(b, digitsXml), "foo", ModeExact)
+}
+func BenchmarkAutomatonDigitsPrefixFoo(b *testing.B) {
+	benchmarkAutomaton(b, newCompiledGrammar(b, digitsXml), "foo", ModePrefix)
+}
+func BenchmarkAutomatonDigitsMatchOneTwoThreeFourFix(b *testing.B) {
+	benchmarkAutomaton(b, newCompiledGrammar(b, digitsXml), "one two three four fix", ModeExact)
+}
+func BenchmarkAutomatonDigitsPrefixOneTwoThreeFourFix(b *testing.B) {
+	benchmarkAutomaton(b, newCompiledGrammar(b, digitsXml), "one two three four fix", ModePrefix)
+}
+func BenchmarkAutomatonDigitsMatchOneTwoThreeFourFive(b *testing.B) {
+	benchmarkAutomaton(b, newCompiledGrammar(b, digitsXml), "one two three four five", ModeExact)
+}
+func BenchmarkAutomatonDigitsPrefixOneTwoThreeFourFive(b *testing.B) {
+	benchmarkAutomaton(b, newCompiledGrammar(b, digitsXml), "one two three four five", ModePrefix)
+}
+func BenchmarkMatcherAnimalMatch(b *testing.B) {
+	g := NewGrammar()
+	g.LoadXml(animalXml)
+
+	benchmarkAutomaton(b, g, "i am an aardvark", ModeExact)
+}
+func BenchmarkAutomatonAnimalMatch(b *testing.B) {
+	benchmarkAutomaton(b, newCompiledGrammar(b, animalXml), "i am an aardvark", ModeExact)
+}
+func BenchmarkMatcherNameMatch(b *testing.B) {
+	g := NewGrammar()
+	g.LoadXml(nameXml)
+
+	benchmarkAutomaton(b, g, "my name is kaustav", ModeExact)
+}
+func BenchmarkAutomatonNameMatch(b *testing.B) {
+	benchmarkAutomaton(b, newCompiledGrammar(b, nameXml), "my name is kaustav", ModeExact)
+}
@@ -0,0 +1,251 @@
+package srgs
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// A Builder assembles a grammar in Go, such as one generated from a list of names, instead of loading it from a
+// document. Rules may reference rules which are added after them. For example:
+//
+//	b := NewBuilder()
+//	g, err := b.
+//		Rule("order", ScopePublic, b.Token("i want"), b.Ref("fruit"), b.Tag("out = rules.fruit.out;")).
+//		Rule("fruit", ScopePrivate, b.OneOf(b.Token("apples"), b.Weight(2, b.Token("pears")))).
+//		Build()
+//
+// Problems are only reported by Build, which reports all of them at once.
+type Builder struct {
+	// Puts the tokens into the form the strings matched against the grammar are put into. It must be set before any
+	// tokens are added, and is the Normalizer of the grammar which is built.
+	Normalizer Normalizer
+
+	root  string
+	rules []builderRule
+}
+
+type builderRule struct {
+	id    string
+	scope RuleScope
+	exp   Expansion
+}
+
+// Creates a new builder for a grammar
+func NewBuilder() *Builder {
+	return new(Builder)
+}
+
+// Sets the root rule of the grammar. Defaults to the first rule added.
+func (b *Builder) Root(id string) *Builder {
+	b.root = id
+	return b
+}
+
+// Adds a rule which matches a sequence of expansions
+func (b *Builder) Rule(id string, scope RuleScope, exps ...Expansion) *Builder {
+	b.rules = append(b.rules, builderRule{id, scope, b.Seq(exps...)})
+	return b
+}
+
+// Returns a token which matches one or more words, such as "new york". Tokens are matched in the normal form of the
+// builder's Normalizer, which is lower case by default.
+func (b *Builder) Token(words string) Expansion {
+	return NewToken(b.normalize(words))
+}
+
+// Returns words in the normal form of the builder's Normalizer. A builder has no language, so case is folded by the
+// language of the Normalizer.
+func (b *Builder) normalize(words string) string {
+	return b.Normalizer.normalize(words, "")
+}
+
+// Returns an expansion which matches a sequence of expansions, one after another
+func (b *Builder) Seq(exps ...Expansion) Expansion {
+	if len(exps) == 1 {
+		return exps[0]
+	}
+
+	return &Sequence{exps: exps}
+}
+
+// Returns an expansion which matches any one of its alternatives. An alternative has a weight of 1 unless it is
+// given one with Weight.
+func (b *Builder) OneOf(alternatives ...Expansion) Expansion {
+	items := make([]Expansion, len(alternatives))
+
+	for i, alternative := range alternatives {
+		if it, ok := alternative.(*weightedItem); ok {
+			items[i] = it.Item
+		} else {
+			items[i] = NewItem(alternative, RepeatModeNormal, 1, 1)
+		}
+	}
+
+	return NewAlternative(items...)
+}
+
+// Returns an alternative of a OneOf with a weight, which makes it more or less likely than the other alternatives
+func (b *Builder) Weight(weight float64, exps ...Expansion) Expansion {
+	it := NewItem(b.Seq(exps...), RepeatModeNormal, 1, 1)
+	it.weight = weight
+
+	return &weightedItem{it}
+}
+
+// An item with a weight, which is only meaningful as an alternative of a OneOf
+type weightedItem struct {
+	*Item
+}
+
+// Returns an expansion which matches a sequence of expansions between min and max times. A max of RepeatUnbounded
+// has no upper bound.
+func (b *Builder) Repeat(min, max int, exps ...Expansion) Expansion {
+	return NewItem(b.Seq(exps...), RepeatModeNormal, min, max)
+}
+
+// Returns an expansion which matches a sequence of expansions once, or not at all
+func (b *Builder) Optional(exps ...Expansion) Expansion {
+	return b.Repeat(0, 1, exps...)
+}
+
+// Returns a reference to a rule of the grammar
+func (b *Builder) Ref(id string) Expansion {
+	return &RuleRef{ruleId: id}
+}
+
+// Returns a tag, which is evaluated by the processor when a match is scanned into it
+func (b *Builder) Tag(text string) Expansion {
+	return NewTag(text)
+}
+
+// Returns the special rule GARBAGE, which matches any number of words
+func (b *Builder) Garbage() Expansion {
+	return new(Garbage)
+}
+
+// Returns the special rule GARBAGE, which matches words within the bounds of opts. Bounds which are negative, or a
+// MaxWords below MinWords, are reported by Build.
+func (b *Builder) GarbageWith(opts GarbageOptions) Expansion {
+	return newGarbage(opts, b.normalize)
+}
+
+// Returns a grammar with the rules that have been added, ready to be matched. If there are any problems with the
+// rules, such as a reference to a rule which was never added, they are all returned as GrammarErrors. The grammar has
+// its own copy of the expansions, so they can be used again by the builder or by another one.
+func (b *Builder) Build() (*Grammar, error) {
+	g := NewGrammar()
+	g.Normalizer = b.Normalizer
+	g.resetRules()
+
+	problems := new(loadProblems)
+	ids := make(map[string]bool)
+	var rules []builderRule
+
+	for _, rule := range b.rules {
+		at := GrammarError{Rule: rule.id}
+
+		if rule.id == "" {
+			problems.add(at, UnidentifiableRule)
+			continue
+		}
+
+		if ids[rule.id] {
+			problems.add(at, DuplicateRule)
+			continue
+		}
+
+		if rule.scope != ScopePublic && rule.scope != ScopePrivate {
+			problems.add(at, errors.New("invalid scope "+string(rule.scope)))
+		}
+
+		ids[rule.id] = true
+		rules = append(rules, rule)
+	}
+
+	for _, rule := range rules {
+		g.addRule(rule.id, rule.scope, b.build(g, ids, rule.exp, problems, GrammarError{Rule: rule.id}))
+	}
+
+	root := b.root
+
+	if root == "" && len(b.rules) > 0 {
+		root = b.rules[0].id
+	}
+
+	if root == "" {
+		problems.add(GrammarError{}, NoRoot)
+	} else if err := g.link(root); err != nil {
+		problems.add(GrammarError{}, fmt.Errorf("%w: %s", err, root))
+	}
+
+	if err := problems.err(); err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// Checks the expansions of a rule, and returns a copy of them whose rule references are resolved in the grammar
+func (b *Builder) build(g *Grammar, ids map[string]bool, exp Expansion, problems *loadProblems,
+	at GrammarError) Expansion {
+	switch e := exp.(type) {
+	case nil:
+		problems.add(at, errors.New("expansion is nil"))
+	case *Token:
+		if e.token == "" {
+			problems.add(at, errors.New("token has no words"))
+		}
+	case *Sequence:
+		out := &Sequence{exps: make([]Expansion, len(e.exps))}
+
+		for i, child := range e.exps {
+			out.exps[i] = b.build(g, ids, child, problems, at)
+		}
+
+		return out
+	case *Alternative:
+		out := &Alternative{items: make([]Expansion, len(e.items))}
+
+		for i, child := range e.items {
+			out.items[i] = b.build(g, ids, child, problems, at)
+		}
+
+		return out
+	case *Item:
+		if e.repeatMin < 0 || e.repeatMax < e.repeatMin && e.repeatMax != RepeatUnbounded {
+			problems.add(at, errors.New("invalid repeat "+formatRepeat(e.repeatMin, e.repeatMax)))
+		}
+
+		if e.weight < 0 || math.IsInf(e.weight, 0) || math.IsNaN(e.weight) {
+			problems.add(at, fmt.Errorf("invalid weight %g", e.weight))
+		}
+
+		out := *e
+		out.child = b.build(g, ids, e.child, problems, at)
+
+		return &out
+	case *weightedItem:
+		problems.add(at, errors.New("weighted expansions can only be alternatives of a OneOf"))
+	case *Garbage:
+		if e.minWords < 0 || e.maxWords < 0 || e.maxWords > 0 && e.maxWords < e.minWords {
+			max := e.maxWords
+
+			if max == 0 {
+				max = RepeatUnbounded
+			}
+
+			problems.add(at, errors.New("invalid GARBAGE repeat "+formatRepeat(e.minWords, max)))
+		}
+	case *RuleRef:
+		// a reference to a rule which has not been added yet is resolved by the grammar once it is
+		if ids[e.ruleId] {
+			return g.newRuleRef(e.ruleId)
+		}
+
+		problems.add(at, fmt.Errorf("%w: %s", RuleNotFound, e.ruleId))
+	}
+
+	// tokens, tags and special rules never change, so they can be shared
+	return exp
+}
@@ -0,0 +1,149 @@
+package srgs
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	assert := assert.New(t)
+
+	contacts := map[string]string{"Rob Capo": "1", "Kaustav": "2", "Ram": "3"}
+	names := []string{"Rob Capo", "Kaustav", "Ram"}
+
+	b := NewBuilder()
+
+	var alternatives []Expansion
+	for _, name := range names {
+		alternatives = append(alternatives, b.Seq(b.Token(name), b.Tag(`out = "`+contacts[name]+`";`)))
+	}
+
+	g, err := b.
+		Rule("call", ScopePublic,
+			b.Optional(b.Token("please")),
+			b.Token("call"),
+			b.Ref("contact"),
+			b.Tag("out = rules.contact.out;"),
+			b.Repeat(0, 2, b.Token("now")),
+			b.Garbage(),
+		).
+		Rule("contact", ScopePrivate, b.OneOf(alternatives...)).
+		Rule("any", ScopePublic, b.OneOf(b.Ref("contact"), b.Weight(2, b.Token("nobody")))).
+		Build()
+
+	if !assert.Nil(err) {
+		return
+	}
+
+	assert.Equal([]string{"any", "call"}, g.PublicRules())
+	assert.True(g.HasMatch("please call rob capo"))
+	assert.True(g.HasMatch("call Kaustav now now"))
+	assert.True(g.HasMatch("call ram at home"))
+	assert.True(g.HasPrefix("call ro"))
+	assert.False(g.HasMatch("call"))
+	assert.True(g.HasMatchRule("any", "nobody"))
+
+	p := new(SISRProcessor)
+	if assert.Nil(g.GetMatch("call rob capo", p)) {
+		out, err := p.GetInstance()
+		assert.Nil(err)
+		assert.Equal("1", out)
+	}
+
+	// a built grammar is the same as one loaded from the document it writes
+	assertRoundTrips(assert, g)
+	assert.Contains(g.WriteXml(), `<item weight="2">nobody</item>`)
+	assert.Empty(Lint(g))
+}
+
+func TestBuilder_Root(t *testing.T) {
+	assert := assert.New(t)
+
+	b := NewBuilder()
+	g, err := b.Rule("a", ScopePrivate, b.Token("a")).Rule("b", ScopePublic, b.Token("b")).Root("b").Build()
+
+	if assert.Nil(err) {
+		assert.True(g.HasMatch("b"))
+		assert.False(g.HasMatch("a"))
+	}
+}
+
+func TestBuilder_Errors(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := NewBuilder().Build()
+	assert.True(errors.Is(err, NoRoot))
+
+	b := NewBuilder()
+	_, err = b.
+		Rule("a", ScopePublic, b.Ref("missing"), b.Repeat(3, 2, b.Token("x")), b.Weight(-1, b.Token("y"))).
+		Rule("a", ScopePublic, b.Token("again")).
+		Rule("c", "protected", b.OneOf(b.Weight(-1, b.Token("z")), b.Token(" "))).
+		Rule("e", ScopePrivate, b.GarbageWith(GarbageOptions{MinWords: 3, MaxWords: 2}),
+			b.GarbageWith(GarbageOptions{MinWords: -1}), b.GarbageWith(GarbageOptions{MaxWords: 2})).
+		Root("d").
+		Build()
+
+	var problems GrammarErrors
+	if !assert.True(errors.As(err, &problems)) {
+		return
+	}
+
+	assert.EqualError(err, `rule a: rule is defined more than once
+rule c: invalid scope protected
+rule a: unable to find rule: missing
+rule a: invalid repeat 3-2
+rule a: weighted expansions can only be alternatives of a OneOf
+rule c: invalid weight -1
+rule c: token has no words
+rule e: invalid GARBAGE repeat 3-2
+rule e: invalid GARBAGE repeat -1-
+unable to find root rule: d`)
+	assert.True(errors.Is(err, RuleNotFound))
+	assert.True(errors.Is(err, DuplicateRule))
+	assert.True(errors.Is(err, RootNotFound))
+}
+
+// The expansions of a builder can be used again once it has built a grammar, by the same builder or another
+func TestBuilder_Reuse(t *testing.T) {
+	assert := assert.New(t)
+
+	b := NewBuilder()
+	order := b.Seq(b.Token("i want"), b.Ref("fruit"), b.Tag("out = rules.fruit.out;"))
+
+	first, err := b.
+		Rule("order", ScopePublic, order).
+		Rule("fruit", ScopePrivate, b.OneOf(b.Seq(b.Token("apples"), b.Tag(`out = "apple";`)))).
+		Build()
+	if !assert.Nil(err) {
+		return
+	}
+
+	b2 := NewBuilder()
+	second, err := b2.
+		Rule("order", ScopePublic, order).
+		Rule("fruit", ScopePrivate, b2.OneOf(b2.Seq(b2.Token("pears"), b2.Tag(`out = "pear";`)))).
+		Build()
+	if !assert.Nil(err) {
+		return
+	}
+
+	assert.True(first.HasMatch("i want apples"))
+	assert.False(first.HasMatch("i want pears"))
+	assert.True(second.HasMatch("i want pears"))
+	assert.False(second.HasMatch("i want apples"))
+
+	for g, want := range map[*Grammar]string{first: "apple", second: "pear"} {
+		p := new(SISRProcessor)
+		if assert.Nil(g.GetMatch("i want "+want+"s", p)) {
+			out, err := p.GetInstance()
+			assert.Nil(err)
+			assert.Equal(want, out)
+		}
+	}
+
+	// the expansion is left as it was, so a builder which lacks the rule it references reports it
+	_, err = NewBuilder().Rule("order", ScopePublic, order).Build()
+	assert.True(errors.Is(err, RuleNotFound))
+}
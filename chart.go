@@ -0,0 +1,686 @@
+package srgs
+
+import (
+	"strconv"
+	"strings"
+)
+
+// A Backend is an algorithm which a grammar can match strings with
+type Backend int
+
+const (
+	// Matches by backtracking through the matchers of the grammar's expansions. This is the default backend, but it
+	// cannot match rules which are left recursive.
+	BackendMatcher Backend = iota
+
+	// Matches with an Earley chart parser, which handles rules with any kind of recursion. When a string can be
+	// matched in more than one way, GetMatch scans the same path that BackendMatcher would have found first, and
+	// GetParses finds the parses in the same order.
+	BackendChart
+)
+
+// Implements fmt.Stringer String method
+func (b Backend) String() string {
+	switch b {
+	case BackendMatcher:
+		return "matcher"
+	case BackendChart:
+		return "chart"
+	}
+
+	return "backend " + strconv.Itoa(int(b))
+}
+
+// An Earley item: an expansion which started matching at origin, and how far through the expansion it has got. dot
+// is the index of the next expansion of a sequence, the number of repeats of an item, or the number of words of a
+// token. alt is the index of the alternative being matched for an Alternative.
+type chartItem struct {
+	exp    Expansion
+	alt    int
+	dot    int
+	origin int
+}
+
+// The Earley items at a position of the input
+type chartSet struct {
+	items []chartItem
+	seen  map[chartItem]bool
+
+	// the items waiting for each expansion predicted at this position
+	waiters map[Expansion][]chartItem
+
+	// the expansions predicted at this position which matched without consuming any words
+	empty map[Expansion]bool
+}
+
+// An expansion which started matching at a position of the input
+type chartSpan struct {
+	exp   Expansion
+	start int
+}
+
+// The ways the rest of a sequence or item can match from a position of the input
+type chartRest struct {
+	exp   Expansion
+	dot   int
+	start int
+}
+
+type chartParser struct {
+	words []string
+	sets  []*chartSet
+
+	// the incomplete last word of a prefix
+	partial string
+
+	// the positions each expansion ended at, keyed by where it started
+	ends map[chartSpan]map[int]bool
+
+	// whether each expansion can match any string at all
+	productive map[Expansion]bool
+
+	tokens map[*Token][]string
+	rests  map[chartRest]map[int]bool
+}
+
+// Matches a string against a rule with a chart parser. In ModePrefix the last word of the string may be incomplete.
+func parseChart(ref *RuleRef, str string, mode MatchMode) (matchPath, error) {
+	c, err := newChart(ref, str, mode)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if mode == ModePrefix {
+		if !c.hasPrefix(c.partial) {
+			return nil, NoMatch
+		}
+
+		return nil, nil
+	}
+
+	n := len(c.words)
+
+	if !c.ends[chartSpan{ref, 0}][n] {
+		return nil, NoMatch
+	}
+
+	return c.choose(ref, 0, map[int]bool{n: true}), nil
+}
+
+// Fills in the chart of a string matched against a rule. Returns NoMatch if the words of the string stop matching
+// before the end of the string.
+func newChart(ref *RuleRef, str string, mode MatchMode) (*chartParser, error) {
+	var words []string
+	partial := ""
+
+	if mode == ModePrefix {
+		words = strings.Split(str, " ")
+		partial = words[len(words)-1]
+		words = words[:len(words)-1]
+	} else if str != "" {
+		words = strings.Split(str, " ")
+
+		// a single trailing space is consumed along with the last word
+		if len(words) > 1 && words[len(words)-1] == "" {
+			words = words[:len(words)-1]
+		}
+	}
+
+	c := &chartParser{
+		words:      words,
+		partial:    partial,
+		sets:       make([]*chartSet, len(words)+1),
+		ends:       make(map[chartSpan]map[int]bool),
+		productive: findProductive(ref),
+		tokens:     make(map[*Token][]string),
+		rests:      make(map[chartRest]map[int]bool),
+	}
+
+	for i := range c.sets {
+		c.sets[i] = &chartSet{
+			seen:    make(map[chartItem]bool),
+			waiters: make(map[Expansion][]chartItem),
+			empty:   make(map[Expansion]bool),
+		}
+	}
+
+	c.predict(ref, 0)
+
+	for k, set := range c.sets {
+		for i := 0; i < len(set.items); i++ {
+			c.process(set.items[i], k)
+		}
+
+		if len(set.items) == 0 {
+			return nil, NoMatch
+		}
+	}
+
+	return c, nil
+}
+
+// Returns whether a partial word can come next after all of the complete words
+func (c *chartParser) hasPrefix(partial string) bool {
+	if partial == "" {
+		return true
+	}
+
+	for _, item := range c.sets[len(c.words)].items {
+		switch e := item.exp.(type) {
+		case *Token:
+			words := c.tokenWords(e)
+
+			if item.dot < len(words) && strings.HasPrefix(words[item.dot], partial) {
+				return true
+			}
+		case *Garbage:
+			if e.maxWords <= 0 || item.dot < e.maxWords {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (c *chartParser) tokenWords(t *Token) []string {
+	words, ok := c.tokens[t]
+
+	if !ok {
+		if t.token != "" {
+			words = strings.Split(t.token, " ")
+		}
+
+		c.tokens[t] = words
+	}
+
+	return words
+}
+
+// Returns the expansions reachable from a rule which can match some string, so that an expansion which can only fail
+// (such as one containing VOID) is never predicted or generated
+func findProductive(ref *RuleRef) map[Expansion]bool {
+	productive := make(map[Expansion]bool)
+
+	var exps []Expansion
+	seen := make(map[Expansion]bool)
+
+	var visit func(Expansion)
+	visit = func(exp Expansion) {
+		if seen[exp] {
+			return
+		}
+
+		seen[exp] = true
+		exps = append(exps, exp)
+
+		switch e := exp.(type) {
+		case *Sequence:
+			for _, child := range e.exps {
+				visit(child)
+			}
+		case *Alternative:
+			for _, child := range e.items {
+				visit(child)
+			}
+		case *Item:
+			visit(e.child)
+		case *RuleRef:
+			visit(e.rule)
+		}
+	}
+
+	visit(ref)
+
+	for changed := true; changed; {
+		changed = false
+
+		for _, exp := range exps {
+			if !productive[exp] && isProductive(exp, productive) {
+				productive[exp] = true
+				changed = true
+			}
+		}
+	}
+
+	return productive
+}
+
+func isProductive(exp Expansion, productive map[Expansion]bool) bool {
+	switch e := exp.(type) {
+	case *Sequence:
+		for _, child := range e.exps {
+			if !productive[child] {
+				return false
+			}
+		}
+
+		return true
+	case *Alternative:
+		for _, child := range e.items {
+			if productive[child] {
+				return true
+			}
+		}
+
+		return false
+	case *Item:
+		return e.repeatMin == 0 || productive[e.child]
+	case *RuleRef:
+		return productive[e.rule]
+	case *Void:
+		return false
+	}
+
+	return true
+}
+
+func (c *chartParser) add(k int, item chartItem) {
+	set := c.sets[k]
+
+	if it, ok := item.exp.(*Item); ok {
+		item.dot = it.normalizeCount(item.dot)
+	}
+
+	if !set.seen[item] {
+		set.seen[item] = true
+		set.items = append(set.items, item)
+	}
+}
+
+func (c *chartParser) predict(exp Expansion, k int) {
+	if !c.productive[exp] {
+		return
+	}
+
+	if a, ok := exp.(*Alternative); ok {
+		for alt, item := range a.items {
+			if c.productive[item] {
+				c.add(k, chartItem{exp: a, alt: alt, origin: k})
+			}
+		}
+
+		return
+	}
+
+	c.add(k, chartItem{exp: exp, origin: k})
+}
+
+// Waits for an expansion to match from position k, and then advances the item past it
+func (c *chartParser) wait(item chartItem, exp Expansion, k int) {
+	if !c.productive[exp] {
+		return
+	}
+
+	set := c.sets[k]
+	set.waiters[exp] = append(set.waiters[exp], item)
+
+	if set.empty[exp] {
+		c.add(k, advance(item))
+	}
+
+	c.predict(exp, k)
+}
+
+// Records that an item's expansion has matched from its origin up to position k, and advances its waiters
+func (c *chartParser) complete(item chartItem, k int) {
+	span := chartSpan{item.exp, item.origin}
+
+	if c.ends[span] == nil {
+		c.ends[span] = make(map[int]bool)
+	}
+
+	if c.ends[span][k] {
+		return
+	}
+
+	c.ends[span][k] = true
+
+	if item.origin == k {
+		c.sets[k].empty[item.exp] = true
+	}
+
+	waiters := c.sets[item.origin].waiters[item.exp]
+
+	for i := 0; i < len(waiters); i++ {
+		c.add(k, advance(waiters[i]))
+	}
+}
+
+func advance(item chartItem) chartItem {
+	item.dot++
+	return item
+}
+
+func (c *chartParser) process(item chartItem, k int) {
+	switch e := item.exp.(type) {
+	case *Token:
+		words := c.tokenWords(e)
+
+		if item.dot == len(words) {
+			c.complete(item, k)
+		} else if k < len(c.words) && words[item.dot] == c.words[k] {
+			c.add(k+1, advance(item))
+		}
+	case *Sequence:
+		if item.dot == len(e.exps) {
+			c.complete(item, k)
+		} else {
+			c.wait(item, e.exps[item.dot], k)
+		}
+	case *Alternative:
+		if item.dot == 1 {
+			c.complete(item, k)
+		} else {
+			c.wait(item, e.items[item.alt], k)
+		}
+	case *Item:
+		if item.dot >= e.repeatMin {
+			c.complete(item, k)
+		}
+
+		if e.canRepeat(item.dot) {
+			c.wait(item, e.child, k)
+		}
+	case *RuleRef:
+		if item.dot == 1 {
+			c.complete(item, k)
+		} else {
+			c.wait(item, e.rule, k)
+		}
+	case *Garbage:
+		// dot is the number of words the garbage has consumed
+		if item.dot >= e.minWords {
+			c.complete(item, k)
+		}
+
+		if k < len(c.words) && (e.maxWords <= 0 || item.dot < e.maxWords) && !e.stopWords[c.words[k]] {
+			c.add(k+1, advance(item))
+		}
+	case *Void:
+	default:
+		// tags and NULL consume nothing
+		c.complete(item, k)
+	}
+}
+
+// A node of the path through the grammar which matched a string
+type chartNode struct {
+	exp        Expansion
+	start, end int
+
+	children []*chartNode
+
+	// the index of the alternative taken by an alternative node
+	alt int
+
+	// the words consumed by a garbage node
+	garbage string
+}
+
+// Returns the positions the rest of a sequence can end at, starting with its expansion at index dot from position k
+func (c *chartParser) sequenceEnds(s *Sequence, dot, k int) map[int]bool {
+	if dot == len(s.exps) {
+		return map[int]bool{k: true}
+	}
+
+	key := chartRest{s, dot, k}
+
+	if out, ok := c.rests[key]; ok {
+		return out
+	}
+
+	out := make(map[int]bool)
+
+	for end := range c.ends[chartSpan{s.exps[dot], k}] {
+		for e := range c.sequenceEnds(s, dot+1, end) {
+			out[e] = true
+		}
+	}
+
+	c.rests[key] = out
+
+	return out
+}
+
+// Returns whether the rest of an item, after count repeats have ended at position k, can end at one of the positions
+// in ends. The answers are memoized in reaches, which must only be used with the one set of ends.
+func (c *chartParser) itemReaches(it *Item, count, k int, ends map[int]bool, reaches map[chartRest]bool) bool {
+	count = it.normalizeCount(count)
+	key := chartRest{it, count, k}
+
+	if out, ok := reaches[key]; ok {
+		return out
+	}
+
+	out := count >= it.repeatMin && ends[k]
+
+	for _, end := range c.repeatEnds(it, count, k) {
+		if out {
+			break
+		}
+
+		out = c.itemReaches(it, count+1, end, ends, reaches)
+	}
+
+	reaches[key] = out
+
+	return out
+}
+
+// Returns whether the rest of an item, after count repeats have ended at position k, can end at one of the positions
+// in ends with exactly target repeats. The answers are memoized in reaches, which must only be used with the one set
+// of ends and target.
+func (c *chartParser) itemReachesIn(it *Item, count, k, target int, ends map[int]bool, reaches map[chartRest]bool) bool {
+	if count == target {
+		return ends[k]
+	}
+
+	key := chartRest{it, count, k}
+
+	if out, ok := reaches[key]; ok {
+		return out
+	}
+
+	out := false
+
+	for _, end := range c.repeatEnds(it, count, k) {
+		if out = c.itemReachesIn(it, count+1, end, target, ends, reaches); out {
+			break
+		}
+	}
+
+	reaches[key] = out
+
+	return out
+}
+
+// Returns the number of repeats a lazy or greedy item from position k has when it ends at one of the positions in
+// ends, which is the fewest it can have for a lazy item, and the most for a greedy one. Returns -1 if it cannot end
+// at any of them.
+func (c *chartParser) repeatTarget(it *Item, k int, ends map[int]bool) int {
+	counts := c.repeatCounts(it, k, ends, it.repeatMode == RepeatModeLazy)
+
+	if len(counts) == 0 {
+		return -1
+	}
+
+	return counts[len(counts)-1]
+}
+
+// Returns the numbers of repeats, from the fewest to the most, with which an item from position k can end at one of
+// the positions in ends. If first is true, only the fewest is returned.
+func (c *chartParser) repeatCounts(it *Item, k int, ends map[int]bool, first bool) []int {
+	var counts []int
+	positions := map[int]bool{k: true}
+
+	// every repeat beyond the minimum consumes a word, so the positions run out
+	for count := 0; len(positions) > 0; count++ {
+		if count >= it.repeatMin && intersects(positions, ends) {
+			counts = append(counts, count)
+
+			if first {
+				break
+			}
+		}
+
+		next := make(map[int]bool)
+
+		for p := range positions {
+			for _, end := range c.repeatEnds(it, count, p) {
+				next[end] = true
+			}
+		}
+
+		positions = next
+	}
+
+	return counts
+}
+
+// Returns the positions another repeat of an item can end at, after count repeats have ended at position k. Like
+// the matcher, a repeat beyond the minimum must consume something.
+func (c *chartParser) repeatEnds(it *Item, count, k int) []int {
+	if !it.canRepeat(count) {
+		return nil
+	}
+
+	var out []int
+
+	for end := range c.ends[chartSpan{it.child, k}] {
+		if end > k || count < it.repeatMin {
+			out = append(out, end)
+		}
+	}
+
+	return out
+}
+
+// Chooses the path through an expansion from position k which ends at one of the positions in ends. When there are
+// several, it chooses the one the matcher would find first: alternatives in order, the repeats of an item in the order
+// of its RepeatMode, and the fewest words of garbage (or the most for greedy garbage).
+func (c *chartParser) choose(exp Expansion, k int, ends map[int]bool) *chartNode {
+	node := &chartNode{exp: exp, start: k, end: k}
+
+	switch e := exp.(type) {
+	case *Token:
+		node.end = k + len(c.tokenWords(e))
+	case *Sequence:
+		for dot, child := range e.exps {
+			next := make(map[int]bool)
+
+			for end := range c.ends[chartSpan{child, node.end}] {
+				if intersects(c.sequenceEnds(e, dot+1, end), ends) {
+					next[end] = true
+				}
+			}
+
+			node.children = append(node.children, c.choose(child, node.end, next))
+			node.end = node.children[len(node.children)-1].end
+		}
+	case *Alternative:
+		for alt, item := range e.items {
+			if intersects(c.ends[chartSpan{item, k}], ends) {
+				node.children = []*chartNode{c.choose(item, k, ends)}
+				node.end = node.children[0].end
+				node.alt = alt
+
+				break
+			}
+		}
+	case *Item:
+		// a lazy or greedy item has the fewest or most repeats it can, and a normal item stops as soon as it can
+		target := -1
+
+		if e.repeatMode == RepeatModeLazy || e.repeatMode == RepeatModeGreedy {
+			target = c.repeatTarget(e, k, ends)
+		}
+
+		reaches := make(map[chartRest]bool)
+
+		for count := 0; count != target; count++ {
+			next := make(map[int]bool)
+
+			for _, end := range c.repeatEnds(e, count, node.end) {
+				if target < 0 && c.itemReaches(e, count+1, end, ends, reaches) ||
+					target >= 0 && c.itemReachesIn(e, count+1, end, target, ends, reaches) {
+					next[end] = true
+				}
+			}
+
+			if len(next) == 0 || target < 0 && count >= e.repeatMin && ends[node.end] {
+				break
+			}
+
+			node.children = append(node.children, c.choose(e.child, node.end, next))
+			node.end = node.children[len(node.children)-1].end
+		}
+	case *RuleRef:
+		node.children = []*chartNode{c.choose(e.rule, k, ends)}
+		node.end = node.children[0].end
+	case *Garbage:
+		for i := 0; k+i <= len(c.words); i++ {
+			end := k + i
+
+			if e.repeatMode == RepeatModeGreedy {
+				end = len(c.words) - i
+			}
+
+			if ends[end] && c.ends[chartSpan{e, k}][end] {
+				node.end = end
+				break
+			}
+		}
+
+		node.garbage = strings.Join(c.words[k:node.end], " ")
+	}
+
+	return node
+}
+
+func intersects(a, b map[int]bool) bool {
+	for k := range a {
+		if b[k] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the probability of the path, in the same way as the matchers of each expansion
+func (n *chartNode) Score() float64 {
+	score := 1.0
+
+	switch e := n.exp.(type) {
+	case *Alternative:
+		score = e.probabilities()[n.alt]
+	case *Item:
+		score = e.repeatScore(len(n.children))
+	}
+
+	for _, child := range n.children {
+		score *= child.Score()
+	}
+
+	return score
+}
+
+// Implements matchPath Scan method, in the same way as the matchers of each expansion
+func (n *chartNode) Scan(p Processor) {
+	switch e := n.exp.(type) {
+	case *Token:
+		p.AppendString(e.token)
+	case *Tag:
+		scanTag(p, e)
+	case *RuleRef:
+		scanRuleRef(p, e, n.children[0])
+	case *Garbage:
+		scanGarbage(p, n.garbage, e.scanMatch)
+	default:
+		for _, child := range n.children {
+			child.Scan(p)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package srgs
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var listXml = `<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" xml:lang="en-US" root="order">
+	<rule id="order" scope="public">
+		i want <ruleref uri="#list" /> <tag>out = rules.list.out;</tag>
+	</rule>
+
+	<rule id="list">
+		<one-of>
+			<item><ruleref uri="#list" /> and <ruleref uri="#x" /> <tag>out = rules.list.out + "," + rules.x.out;</tag></item>
+			<item><ruleref uri="#x" /> <tag>out = rules.x.out;</tag></item>
+		</one-of>
+	</rule>
+
+	<rule id="x">
+		<one-of>
+			<item>apples <tag>out = "A";</tag></item>
+			<item>pears <tag>out = "P";</tag></item>
+			<item>green apples <tag>out = "G";</tag></item>
+		</one-of>
+	</rule>
+</grammar>
+`
+
+// Asserts that the chart backend matches a grammar exactly as the matcher backend does
+func assertSameAsMatcher(assert *assert.Assertions, xml string, inputs []string) {
+	g := NewGrammar()
+	if !assert.Nil(g.LoadXml(xml)) {
+		return
+	}
+
+	chart := NewGrammar()
+	chart.Backend = BackendChart
+	chart.LoadXml(xml)
+
+	for _, input := range inputs {
+		assert.Equal(g.HasPrefix(input), chart.HasPrefix(input), "HasPrefix(%q)", input)
+		assert.Equal(g.HasMatch(input), chart.HasMatch(input), "HasMatch(%q)", input)
+
+		want, got := new(SimpleProcessor), new(SimpleProcessor)
+		assert.Equal(g.GetMatch(input, want) == nil, chart.GetMatch(input, got) == nil, "GetMatch(%q)", input)
+		assert.Equal(want, got, "GetMatch(%q)", input)
+	}
+}
+
+func TestChart_SameAsMatcher(t *testing.T) {
+	assert := assert.New(t)
+
+	assertSameAsMatcher(assert, animalXml, []string{
+		"", "i", "i am an", "i am an an", "i am an antler", "i am an aardvark", "i am an antler ",
+		"i am an antler eater", "i am an ape", "i an",
+	})
+	assertSameAsMatcher(assert, nameXml, []string{
+		"my name", "my name is", "my name is rob", "my name is ram", "my name is kaustav", "my name ram",
+		"my name is is",
+	})
+	assertSameAsMatcher(assert, digitsXml, []string{
+		"on", "one two", "one two three four ", "three five four one", "one two three four five",
+		"triple three four five", "one two three four", "six five four three two two", "fix", "double four",
+		"twenty one", "nineteen eighty four",
+	})
+	assertSameAsMatcher(assert, garbageXml, []string{
+		"ten years old", "i am ten years old", "i am ten", "i am nine years old", "fifteen years old",
+		"ten years old ten years old",
+	})
+	assertSameAsMatcher(assert, cityXml, []string{
+		"please to portland", "to boston", "please please to boston",
+	})
+}
+
+func TestChart_LeftRecursion(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGrammar()
+	g.Backend = BackendChart
+	if !assert.Nil(g.LoadXml(listXml)) {
+		return
+	}
+
+	assert.True(g.HasMatch("i want apples"))
+	assert.True(g.HasMatch("i want apples and pears and green apples"))
+	assert.True(g.HasPrefix("i want apples and gr"))
+	assert.True(g.HasPrefix("i want apples and green apples and "))
+	assert.False(g.HasMatch("i want apples and"))
+	assert.False(g.HasPrefix("i want and apples"))
+
+	p := new(SISRProcessor)
+	if !assert.Nil(g.GetMatch("i want pears and green apples and apples", p)) {
+		return
+	}
+
+	assert.Equal("i want pears and green apples and apples", p.GetInterpretation())
+
+	out, err := p.GetInstance()
+	assert.Nil(err)
+	assert.Equal("P,G,A", out)
+
+	assert.Equal(NoMatch, g.GetMatch("i want apples and", new(SISRProcessor)))
+}
+
+func TestChart_Ambiguous(t *testing.T) {
+	assert := assert.New(t)
+
+	xml := `<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" xml:lang="en-US" root="example">
+	<rule id="example">
+		<item repeat="0-3"><ruleref uri="#word" /> <tag>out = (out || "") + rules.word.out;</tag></item>
+		<item repeat="0-3"><ruleref uri="#word" /> <tag>out = (out || "") + rules.word.out;</tag></item>
+		<ruleref special="GARBAGE" />
+		<ruleref special="GARBAGE" />
+		<one-of>
+			<item>new york <tag>out = (out || "") + "NY";</tag></item>
+			<item>new <ruleref uri="#york" /> <tag>out = (out || "") + "N" + rules.york.out;</tag></item>
+		</one-of>
+	</rule>
+
+	<rule id="word">
+		<one-of>
+			<item>new <tag>out = "n";</tag></item>
+			<item>york <tag>out = "y";</tag></item>
+		</one-of>
+	</rule>
+
+	<rule id="york">
+		york <tag>out = "Y";</tag>
+	</rule>
+</grammar>
+`
+
+	assertSameAsMatcher(assert, xml, []string{
+		"new york", "new york new york", "york new york", "new new york", "a new york", "new a b new york",
+	})
+
+	// greedy repeats take as many repeats as they can
+	assertSameAsMatcher(assert, `<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" xml:lang="en-US" root="example">
+	<rule id="example">
+		<item repeat="0-3" repeat-mode="greedy">a <tag>out = (out || "") + "x";</tag></item>
+		<item repeat="0-3">a <tag>out = (out || "") + "y";</tag></item>
+	</rule>
+</grammar>
+`, []string{"a", "a a", "a a a a"})
+}
+
+func TestChart_RightAndMutualRecursion(t *testing.T) {
+	assert := assert.New(t)
+
+	abnf := `#ABNF 1.0 UTF-8;
+root $start;
+
+public $start = go $right | $a | $nested;
+$right = on $right | stop;
+$a = x $b | done;
+$b = y $a;
+$nested = open $nested close | nothing;
+`
+
+	g := NewGrammar()
+	g.Backend = BackendChart
+	if !assert.Nil(g.LoadABNF(abnf)) {
+		return
+	}
+
+	assert.True(g.HasMatch("go on on on stop"))
+	assert.False(g.HasMatch("go on on"))
+	assert.True(g.HasMatch("x y x y done"))
+	assert.False(g.HasMatch("x y x done"))
+	assert.True(g.HasMatch("open open nothing close close"))
+	assert.False(g.HasMatch("open open nothing close"))
+	assert.True(g.HasPrefix("open open nothing close"))
+}
+
+func TestChart_Void(t *testing.T) {
+	assert := assert.New(t)
+
+	abnf := `#ABNF 1.0 UTF-8;
+root $start;
+
+public $start = a b $VOID | a c [$VOID];
+`
+
+	g := NewGrammar()
+	g.Backend = BackendChart
+	if !assert.Nil(g.LoadABNF(abnf)) {
+		return
+	}
+
+	assert.False(g.HasPrefix("a b"))
+	assert.False(g.HasMatch("a b"))
+	assert.True(g.HasPrefix("a "))
+	assert.True(g.HasMatch("a c"))
+}
+
+func BenchmarkChartDigitsMatchOneTwoThreeFourFive(b *testing.B) {
+	g := NewGrammar()
+	g.Backend = BackendChart
+	g.LoadXml(digitsXml)
+
+	benchmarkAutomaton(b, g, "one two three four five", ModeExact)
+}
+
+func BenchmarkChartDigitsMatchOneTwoThreeFourFix(b *testing.B) {
+	g := NewGrammar()
+	g.Backend = BackendChart
+	g.LoadXml(digitsXml)
+
+	benchmarkAutomaton(b, g, "one two three four fix", ModeExact)
+}
@@ -0,0 +1,341 @@
+package srgs
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Iterates over the paths through an expansion which a chart found, in the order the matchers would find them. Unlike
+// the matchers, the paths of rules which are left recursive can be iterated over, since every path is already known
+// to match.
+type chartPaths interface {
+	// Returns the next path, or nil once there are no more
+	next() *chartNode
+}
+
+// An expansion being iterated over from a position towards a set of positions. An iterator which finds itself among
+// the iterators enclosing it has no paths, since they would go around a cycle which consumed nothing, forever.
+type chartVisit struct {
+	exp    Expansion
+	start  int
+	ends   string
+	parent *chartVisit
+}
+
+// Returns the paths through an expansion from position k which end at one of the positions in ends
+func (c *chartParser) paths(exp Expansion, k int, ends map[int]bool, parent *chartVisit) chartPaths {
+	var reached []int
+
+	for end := range c.ends[chartSpan{exp, k}] {
+		if ends[end] {
+			reached = append(reached, end)
+		}
+	}
+
+	if len(reached) == 0 {
+		return new(nodePaths)
+	}
+
+	sort.Ints(reached)
+
+	key := make([]string, len(reached))
+	ends = make(map[int]bool, len(reached))
+
+	for i, end := range reached {
+		key[i] = strconv.Itoa(end)
+		ends[end] = true
+	}
+
+	visit := &chartVisit{exp: exp, start: k, ends: strings.Join(key, " "), parent: parent}
+
+	for v := parent; v != nil; v = v.parent {
+		if v.exp == exp && v.start == k && v.ends == visit.ends {
+			return new(nodePaths)
+		}
+	}
+
+	switch e := exp.(type) {
+	case *Sequence:
+		return &sequencePaths{c: c, seq: e, start: k, ends: ends, visit: visit}
+	case *Alternative:
+		return &alternativePaths{c: c, alt: e, start: k, ends: ends, visit: visit}
+	case *Item:
+		return newItemPaths(c, e, k, ends, visit)
+	case *RuleRef:
+		return &ruleRefPaths{ref: e, start: k, child: c.paths(e.rule, k, ends, visit)}
+	case *Garbage:
+		// the fewest words first, or the most for greedy garbage
+		paths := new(nodePaths)
+
+		for i := range reached {
+			end := reached[i]
+
+			if e.repeatMode == RepeatModeGreedy {
+				end = reached[len(reached)-1-i]
+			}
+
+			paths.nodes = append(paths.nodes,
+				&chartNode{exp: e, start: k, end: end, garbage: strings.Join(c.words[k:end], " ")})
+		}
+
+		return paths
+	}
+
+	// tokens, tags and NULL only match one way
+	return &nodePaths{nodes: []*chartNode{{exp: exp, start: k, end: reached[0]}}}
+}
+
+// A list of paths which are already known
+type nodePaths struct {
+	nodes []*chartNode
+}
+
+func (p *nodePaths) next() *chartNode {
+	if len(p.nodes) == 0 {
+		return nil
+	}
+
+	node := p.nodes[0]
+	p.nodes = p.nodes[1:]
+
+	return node
+}
+
+type ruleRefPaths struct {
+	ref   *RuleRef
+	start int
+	child chartPaths
+}
+
+func (p *ruleRefPaths) next() *chartNode {
+	child := p.child.next()
+
+	if child == nil {
+		return nil
+	}
+
+	return &chartNode{exp: p.ref, start: p.start, end: child.end, children: []*chartNode{child}}
+}
+
+// Iterates over the paths of each alternative in order
+type alternativePaths struct {
+	c     *chartParser
+	alt   *Alternative
+	start int
+	ends  map[int]bool
+	visit *chartVisit
+
+	ind   int
+	child chartPaths
+}
+
+func (p *alternativePaths) next() *chartNode {
+	for ; p.ind < len(p.alt.items); p.ind, p.child = p.ind+1, nil {
+		if p.child == nil {
+			p.child = p.c.paths(p.alt.items[p.ind], p.start, p.ends, p.visit)
+		}
+
+		if child := p.child.next(); child != nil {
+			return &chartNode{exp: p.alt, start: p.start, end: child.end, children: []*chartNode{child}, alt: p.ind}
+		}
+	}
+
+	return nil
+}
+
+// Iterates over the paths of a sequence, trying every path of an expansion with each path of the expansions before it
+type sequencePaths struct {
+	c     *chartParser
+	seq   *Sequence
+	start int
+	ends  map[int]bool
+	visit *chartVisit
+
+	started bool
+
+	// the iterators and current paths of the expansions matched so far
+	iters []chartPaths
+	nodes []*chartNode
+}
+
+func (p *sequencePaths) next() *chartNode {
+	if !p.started {
+		p.started = true
+
+		if len(p.seq.exps) == 0 {
+			return &chartNode{exp: p.seq, start: p.start, end: p.start}
+		}
+
+		p.push(p.start)
+	}
+
+	for len(p.iters) > 0 {
+		dot := len(p.iters) - 1
+		child := p.iters[dot].next()
+
+		if child == nil {
+			p.iters, p.nodes = p.iters[:dot], p.nodes[:dot]
+			continue
+		}
+
+		p.nodes[dot] = child
+
+		if dot+1 < len(p.seq.exps) {
+			p.push(child.end)
+			continue
+		}
+
+		return &chartNode{exp: p.seq, start: p.start, end: child.end, children: append([]*chartNode(nil), p.nodes...)}
+	}
+
+	return nil
+}
+
+// Starts iterating over the next expansion of the sequence from position k, towards the positions the rest of the
+// sequence can go on from
+func (p *sequencePaths) push(k int) {
+	dot := len(p.iters)
+	next := make(map[int]bool)
+
+	for end := range p.c.ends[chartSpan{p.seq.exps[dot], k}] {
+		if intersects(p.c.sequenceEnds(p.seq, dot+1, end), p.ends) {
+			next[end] = true
+		}
+	}
+
+	p.iters = append(p.iters, p.c.paths(p.seq.exps[dot], k, next, p.visit))
+	p.nodes = append(p.nodes, nil)
+}
+
+// Iterates over the paths of an item in the order of its RepeatMode. The repeats of a normal item are tried depth
+// first, stopping before each further repeat, and those of a lazy or greedy item the same way for one number of
+// repeats at a time.
+type itemPaths struct {
+	c     *chartParser
+	item  *Item
+	start int
+	ends  map[int]bool
+	visit *chartVisit
+
+	// the numbers of repeats of a lazy or greedy item, in the order they are tried, and the index of the one being
+	// tried. A normal item has none.
+	targets []int
+	round   int
+
+	// whether the path of the repeats in nodes has been tried
+	entered bool
+
+	// the iterators of the repeats matched so far, and of the next repeat
+	iters []chartPaths
+	nodes []*chartNode
+
+	reaches map[chartRest]bool
+}
+
+func newItemPaths(c *chartParser, it *Item, k int, ends map[int]bool, visit *chartVisit) chartPaths {
+	p := &itemPaths{c: c, item: it, start: k, ends: ends, visit: visit, reaches: make(map[chartRest]bool)}
+
+	if it.repeatMode == RepeatModeLazy || it.repeatMode == RepeatModeGreedy {
+		p.targets = c.repeatCounts(it, k, ends, false)
+
+		if len(p.targets) == 0 {
+			return new(nodePaths)
+		}
+
+		if it.repeatMode == RepeatModeGreedy {
+			for i, j := 0, len(p.targets)-1; i < j; i, j = i+1, j-1 {
+				p.targets[i], p.targets[j] = p.targets[j], p.targets[i]
+			}
+		}
+	}
+
+	return p
+}
+
+func (p *itemPaths) next() *chartNode {
+	for {
+		if !p.entered {
+			p.entered = true
+
+			count, end := len(p.nodes), p.end()
+			p.iters = append(p.iters, p.c.paths(p.item.child, end, p.repeatEnds(count, end), p.visit))
+
+			if p.stops(count, end) {
+				return &chartNode{exp: p.item, start: p.start, end: end, children: append([]*chartNode(nil), p.nodes...)}
+			}
+
+			continue
+		}
+
+		if len(p.iters) == 0 {
+			if p.round++; p.round >= len(p.targets) {
+				return nil
+			}
+
+			p.entered = false
+			p.reaches = make(map[chartRest]bool)
+
+			continue
+		}
+
+		dot := len(p.iters) - 1
+		child := p.iters[dot].next()
+
+		if child == nil {
+			p.iters = p.iters[:dot]
+
+			if dot > 0 {
+				p.nodes = p.nodes[:dot-1]
+			}
+
+			continue
+		}
+
+		p.nodes = append(p.nodes[:dot], child)
+		p.entered = false
+	}
+}
+
+// Returns the number of repeats being tried, or -1 for a normal item
+func (p *itemPaths) target() int {
+	if p.targets == nil {
+		return -1
+	}
+
+	return p.targets[p.round]
+}
+
+// Returns where the repeats matched so far end
+func (p *itemPaths) end() int {
+	if len(p.nodes) == 0 {
+		return p.start
+	}
+
+	return p.nodes[len(p.nodes)-1].end
+}
+
+// Returns whether the item can stop after count repeats which end at position k
+func (p *itemPaths) stops(count, k int) bool {
+	if target := p.target(); target >= 0 {
+		return count == target && p.ends[k]
+	}
+
+	return count >= p.item.repeatMin && p.ends[k]
+}
+
+// Returns the positions another repeat can end at after count repeats which end at position k, from which the item
+// can still end at one of its ends
+func (p *itemPaths) repeatEnds(count, k int) map[int]bool {
+	next := make(map[int]bool)
+	target := p.target()
+
+	for _, end := range p.c.repeatEnds(p.item, count, k) {
+		if target < 0 && p.c.itemReaches(p.item, count+1, end, p.ends, p.reaches) ||
+			count < target && p.c.itemReachesIn(p.item, count+1, end, target, p.ends, p.reaches) {
+			next[end] = true
+		}
+	}
+
+	return next
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/robcapo/srgs"
+	"math/rand"
+	"os"
+	"time"
+)
+
+func generate(args []string) error {
+	flags := flag.NewFlagSet("generate", flag.ExitOnError)
+	rule := flags.String("rule", "", "public rule to generate from (defaults to the root rule)")
+	maxWords := flags.Int("max-words", srgs.DefaultMaxWords, "longest sentence to generate, in words")
+	maxSentences := flags.Int("n", 0, "most sentences to enumerate, or the number of sentences to sample")
+	sample := flags.Bool("sample", false, "randomly sample sentences instead of enumerating them")
+	seed := flags.Int64("seed", 0, "seed for sampling (defaults to the current time)")
+	sisr := flags.Bool("sisr", false, "print the SISR instance of each sentence as JSON after a tab")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return errors.New("generate needs exactly one grammar file")
+	}
+
+	g, err := loadGrammar(flags.Arg(0))
+
+	if err != nil {
+		return err
+	}
+
+	opts := srgs.GenerateOptions{Rule: *rule, MaxWords: *maxWords, MaxSentences: *maxSentences}
+
+	if *sisr {
+		opts.NewProcessor = func() srgs.Processor { return new(srgs.SISRProcessor) }
+	}
+
+	var sentences []srgs.Sentence
+
+	if *sample {
+		if *seed == 0 {
+			*seed = time.Now().UnixNano()
+		}
+
+		n := *maxSentences
+		if n <= 0 {
+			n = 1
+		}
+
+		sentences, err = g.Sample(n, rand.New(rand.NewSource(*seed)), opts)
+	} else {
+		sentences, err = g.Enumerate(opts)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	for _, sentence := range sentences {
+		if sentence.Processor == nil {
+			fmt.Fprintln(out, sentence.Text)
+			continue
+		}
+
+		inst, err := sentence.Processor.(*srgs.SISRProcessor).GetJSON()
+
+		if err != nil {
+			return fmt.Errorf("%q: %w", sentence.Text, err)
+		}
+
+		fmt.Fprintf(out, "%s\t%s\n", sentence.Text, inst)
+	}
+
+	return nil
+}
+
+// Loads a grammar file in either XML or ABNF form. Rulerefs to other grammar files are resolved relative to it.
+func loadGrammar(name string) (*srgs.Grammar, error) {
+	g := srgs.NewGrammar()
+	g.Resolver = new(srgs.FileResolver)
+
+	if err := g.LoadUri(name); err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/robcapo/srgs"
+	"os"
+)
+
+// Exits with a failure status when a grammar fails to load or has any error diagnostics
+var lintFailed = errors.New("grammar has errors")
+
+func lint(args []string) error {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	chart := flags.Bool("chart", false, "lint for the chart backend, which can match left recursive rules")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		return errors.New("lint needs at least one grammar file")
+	}
+
+	failed := false
+
+	for _, name := range flags.Args() {
+		g := srgs.NewGrammar()
+		g.Resolver = new(srgs.FileResolver)
+
+		if *chart {
+			g.Backend = srgs.BackendChart
+		}
+
+		if err := g.LoadUri(name); err != nil {
+			var problems srgs.GrammarErrors
+
+			if !errors.As(err, &problems) {
+				return err
+			}
+
+			for _, problem := range problems {
+				file := problem.File
+				if file == "" {
+					file = name
+				}
+
+				// the file is given first, in the same form as the lint diagnostics
+				problem.File = ""
+				fmt.Fprintf(os.Stdout, "%s: error: %s\n", file, problem)
+			}
+
+			failed = true
+			continue
+		}
+
+		for _, diagnostic := range srgs.Lint(g) {
+			fmt.Fprintf(os.Stdout, "%s: %s\n", name, diagnostic)
+
+			if diagnostic.Severity == srgs.SeverityError {
+				failed = true
+			}
+		}
+	}
+
+	if failed {
+		return lintFailed
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+// Command srgs works with SRGS grammars in XML or ABNF form.
+//
+// Usage:
+//
+//	srgs generate [flags] grammar
+//	srgs lint [flags] grammar...
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const usage = `usage: srgs <command> [flags] grammar
+
+commands:
+  generate  enumerate or sample the sentences a grammar matches
+  lint      check grammars for likely mistakes, failing if any are errors
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+
+	switch os.Args[1] {
+	case "generate":
+		err = generate(os.Args[2:])
+	case "lint":
+		err = lint(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "srgs:", err)
+		os.Exit(1)
+	}
+}
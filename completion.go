@@ -0,0 +1,280 @@
+package srgs
+
+import (
+	"strings"
+)
+
+// A Suggestion is a word which can legally come after a prefix
+type Suggestion struct {
+	// The whole word which can come next. If the prefix ends part way through a word, this is the word it completes.
+	Word string
+
+	// The text to append to the prefix to complete the word, including a leading space if the prefix ends in a
+	// complete word
+	Suffix string
+
+	// Whether any word can come next because of a GARBAGE rule. Word and Suffix are empty for wildcards.
+	Wildcard bool
+}
+
+// Completions are the suggestions for a prefix of a grammar
+type Completions struct {
+	// Whether the prefix is already an exact match for the grammar
+	Complete bool
+
+	// The distinct words which can come next, in the order they appear in the grammar
+	Suggestions []Suggestion
+}
+
+// Returns the words which can legally come after a prefix of the grammar, and whether the prefix is already a complete
+// match. If limit is positive, at most limit suggestions are returned. Rules which are left recursive are completed
+// with the help of a chart, but whether the prefix is complete is found with the grammar's backend, so they still need
+// BackendChart.
+func (g *Grammar) Complete(prefix string, limit int) Completions {
+	prefix = g.normalizeInput(prefix, ModePrefix)
+
+	c := &completer{
+		root:      g.Root,
+		prefix:    prefix,
+		limit:     limit,
+		needSpace: prefix != "" && !strings.HasSuffix(prefix, " "),
+		seen:      make(map[Suggestion]bool),
+		active:    make(map[ruleVisit]bool),
+	}
+
+	c.walk(g.Root, prefix, func(rest string) bool {
+		return true
+	})
+
+	c.out.Complete = g.HasMatch(prefix)
+
+	return c.out
+}
+
+// A rule being walked at a position of the prefix, used to stop left recursive rules from being walked indefinitely
+type ruleVisit struct {
+	rule Expansion
+	str  string
+}
+
+type completer struct {
+	root      *RuleRef
+	prefix    string
+	limit     int
+	needSpace bool
+
+	seen   map[Suggestion]bool
+	active map[ruleVisit]bool
+
+	// the chart of the prefix, which is only filled in once a rule is found to be left recursive
+	chart      *chartParser
+	chartReady bool
+
+	out Completions
+}
+
+// Walks each way an expansion can match the start of str, calling next with the rest of the string after each one.
+// Returns false once no more suggestions are needed.
+func (c *completer) walk(exp Expansion, str string, next func(string) bool) bool {
+	switch e := exp.(type) {
+	case *Token:
+		return c.token(e.token, str, next)
+	case *Sequence:
+		return c.sequence(e.exps, str, next)
+	case *Alternative:
+		for _, item := range e.items {
+			if !c.walk(item, str, next) {
+				return false
+			}
+		}
+
+		return true
+	case *Item:
+		return c.item(e, 0, str, next)
+	case *RuleRef:
+		visit := ruleVisit{e.rule, str}
+
+		if c.active[visit] {
+			return c.recursion(e.rule, str, next)
+		}
+
+		c.active[visit] = true
+		ok := c.walk(e.rule, str, next)
+		delete(c.active, visit)
+
+		return ok
+	case *Garbage:
+		return c.garbage(e, str, next)
+	case *Void:
+		return true
+	}
+
+	// tags and NULL consume nothing
+	return next(str)
+}
+
+// Walks the rest of the string after a rule which is left recursive, which is already being walked from the same
+// position. The walk of the rule already suggests the words which can come while it is still matching, so only the
+// positions the rule can end at are needed, and those are found in a chart.
+func (c *completer) recursion(rule Expansion, str string, next func(string) bool) bool {
+	if !c.chartReady {
+		c.chartReady = true
+
+		// the chart treats the last word as complete if it can, since the rule may end after it
+		var err error
+		if c.chart, err = newChart(c.root, strings.TrimSuffix(c.prefix, " "), ModeExact); err != nil {
+			c.chart, _ = newChart(c.root, c.prefix, ModePrefix)
+		}
+	}
+
+	if c.chart == nil {
+		return true
+	}
+
+	// the words consumed before str, where str always starts at the start of a word
+	done := c.prefix[:len(c.prefix)-len(str)]
+	k := strings.Count(done, " ")
+
+	if done != "" && !strings.HasSuffix(done, " ") {
+		k++
+	}
+
+	ends := c.chart.ends[chartSpan{rule, k}]
+	rest := str
+
+	for end := k; end <= len(c.chart.words); end++ {
+		if ends[end] && !next(rest) {
+			return false
+		}
+
+		if ind := strings.Index(rest, " "); ind != -1 {
+			rest = rest[ind+1:]
+		} else {
+			rest = ""
+		}
+	}
+
+	return true
+}
+
+func (c *completer) sequence(exps []Expansion, str string, next func(string) bool) bool {
+	if len(exps) == 0 {
+		return next(str)
+	}
+
+	return c.walk(exps[0], str, func(rest string) bool {
+		return c.sequence(exps[1:], rest, next)
+	})
+}
+
+// Walks the repeats of an item after count repeats have already matched
+func (c *completer) item(it *Item, count int, str string, next func(string) bool) bool {
+	if count >= it.repeatMin && !next(str) {
+		return false
+	}
+
+	if !it.canRepeat(count) {
+		return true
+	}
+
+	return c.walk(it.child, str, func(rest string) bool {
+		// a repeat which consumes nothing only helps to reach the minimum number of repeats
+		if rest == str && count >= it.repeatMin {
+			return true
+		}
+
+		return c.item(it, count+1, rest, next)
+	})
+}
+
+func (c *completer) token(token, str string, next func(string) bool) bool {
+	if token == "" {
+		return next(str)
+	}
+
+	lent := len(token)
+	lens := len(str)
+
+	if str == "" {
+		word := firstWord(token)
+
+		if c.needSpace {
+			return c.suggest(Suggestion{Word: word, Suffix: " " + word})
+		}
+
+		return c.suggest(Suggestion{Word: word, Suffix: word})
+	}
+
+	// If the prefix ends part way through this token, suggest the rest of the word it ends in
+	if lens < lent {
+		if !strings.HasPrefix(token, str) {
+			return true
+		}
+
+		// the prefix ends with a complete word of the token, so suggest the next one
+		if token[lens] == ' ' {
+			word := firstWord(token[lens+1:])
+			return c.suggest(Suggestion{Word: word, Suffix: " " + word})
+		}
+
+		start := strings.LastIndex(token[:lens], " ") + 1
+		word := firstWord(token[start:])
+
+		return c.suggest(Suggestion{Word: word, Suffix: word[lens-start:]})
+	}
+
+	if !strings.HasPrefix(str, token) {
+		return true
+	}
+
+	if lens == lent {
+		return next("")
+	}
+
+	if str[lent] == ' ' {
+		return next(str[lent+1:])
+	}
+
+	return true
+}
+
+// Garbage matches words other than its stop words, so any word can come next while it has fewer than its maximum
+func (c *completer) garbage(g *Garbage, str string, next func(string) bool) bool {
+	stops := g.stops(str, ModePrefix)
+
+	// the garbage can consume the rest of the prefix, including any partial word, and then a word more if it has room
+	if last := stops[len(stops)-1]; last.offset == len(str) {
+		partial := str != "" && !strings.HasSuffix(str, " ")
+
+		if g.maxWords <= 0 || last.words < g.maxWords || partial {
+			if !c.suggest(Suggestion{Wildcard: true}) {
+				return false
+			}
+		}
+	}
+
+	for _, stop := range stops {
+		if stop.words >= g.minWords && !next(str[stop.offset:]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (c *completer) suggest(s Suggestion) bool {
+	if !c.seen[s] {
+		c.seen[s] = true
+		c.out.Suggestions = append(c.out.Suggestions, s)
+	}
+
+	return c.limit <= 0 || len(c.out.Suggestions) < c.limit
+}
+
+func firstWord(str string) string {
+	if ind := strings.Index(str, " "); ind != -1 {
+		return str[:ind]
+	}
+
+	return str
+}
@@ -0,0 +1,156 @@
+package srgs
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func words(c Completions) []string {
+	var out []string
+
+	for _, s := range c.Suggestions {
+		if s.Wildcard {
+			out = append(out, "*")
+		} else {
+			out = append(out, s.Word)
+		}
+	}
+
+	return out
+}
+
+func TestComplete(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGrammar()
+	if !assert.Nil(g.LoadXml(animalXml)) {
+		return
+	}
+
+	c := g.Complete("", 0)
+	assert.False(c.Complete)
+	assert.Equal([]Suggestion{{Word: "i", Suffix: "i"}}, c.Suggestions)
+
+	c = g.Complete("i am", 0)
+	assert.Equal([]Suggestion{{Word: "an", Suffix: " an"}}, c.Suggestions)
+
+	c = g.Complete("i am an ", 0)
+	assert.Equal([]Suggestion{{Word: "antler", Suffix: "antler"}, {Word: "aardvark", Suffix: "aardvark"}}, c.Suggestions)
+
+	c = g.Complete("i am an", 0)
+	assert.Equal([]Suggestion{{Word: "antler", Suffix: " antler"}, {Word: "aardvark", Suffix: " aardvark"}}, c.Suggestions)
+
+	c = g.Complete("I am an AN", 0)
+	assert.Equal([]Suggestion{{Word: "antler", Suffix: "tler"}}, c.Suggestions)
+
+	c = g.Complete("i am an antler", 0)
+	assert.True(c.Complete)
+	assert.Empty(c.Suggestions)
+
+	c = g.Complete("i am an ape", 0)
+	assert.False(c.Complete)
+	assert.Empty(c.Suggestions)
+
+	assert.Len(g.Complete("i am an ", 1).Suggestions, 1)
+}
+
+func TestComplete_RepeatsAndRuleRefs(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGrammar()
+	if !assert.Nil(g.LoadXml(digitsXml)) {
+		return
+	}
+
+	c := g.Complete("one two three four f", 0)
+	assert.Equal([]string{"four", "five"}, words(c))
+	assert.False(c.Complete)
+
+	c = g.Complete("one two three four five", 0)
+	assert.True(c.Complete)
+	assert.Empty(c.Suggestions)
+
+	// quad is a complete word, but also part of quadruple
+	c = g.Complete("quad", 0)
+	assert.Equal([]string{"oh", "zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine",
+		"quadruple"}, words(c))
+
+	assert.Equal([]string{"oh", "zero", "one"}, words(g.Complete("quad", 3)))
+
+	g = NewGrammar()
+	if !assert.Nil(g.LoadABNF(`#ABNF 1.0;
+root $number;
+public $number = $digit <2-3> [please];
+$digit = one | two;
+`)) {
+		return
+	}
+
+	assert.Equal([]string{"one", "two"}, words(g.Complete("one ", 0)))
+	assert.Equal([]string{"please", "one", "two"}, words(g.Complete("one two ", 0)))
+	assert.Equal([]string{"please"}, words(g.Complete("one two one ", 0)))
+	assert.True(g.Complete("one two", 0).Complete)
+}
+
+func TestComplete_Garbage(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGrammar()
+	if !assert.Nil(g.LoadABNF(`#ABNF 1.0;
+root $age;
+public $age = $GARBAGE (ten | twenty) years;
+`)) {
+		return
+	}
+
+	c := g.Complete("", 0)
+	assert.Equal([]string{"*", "ten", "twenty"}, words(c))
+
+	c = g.Complete("i am t", 0)
+	assert.Equal([]Suggestion{{Wildcard: true}, {Word: "ten", Suffix: "en"}, {Word: "twenty", Suffix: "wenty"},
+		{Word: "ten", Suffix: " ten"}, {Word: "twenty", Suffix: " twenty"}}, c.Suggestions)
+
+	c = g.Complete("i am ten ", 0)
+	assert.Equal([]string{"*", "years", "ten", "twenty"}, words(c))
+	assert.False(c.Complete)
+}
+
+func TestComplete_LeftRecursion(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGrammar()
+	g.Backend = BackendChart
+	if !assert.Nil(g.LoadABNF(`#ABNF 1.0;
+root $list;
+$list = $list and $x | $x;
+$x = a | b | a or b;
+`)) {
+		return
+	}
+
+	assert.Equal([]string{"a", "b"}, words(g.Complete("", 0)))
+	assert.Equal([]string{"a", "b"}, words(g.Complete("a and", 10)))
+	assert.Equal([]string{"a", "b"}, words(g.Complete("a and b and ", 0)))
+	assert.Equal([]string{"or", "and"}, words(g.Complete("a and a", 0)))
+	assert.Equal([]string{"and"}, words(g.Complete("a and a or b", 0)))
+	assert.Equal([]Suggestion{{Word: "and", Suffix: "d"}}, g.Complete("b an", 0).Suggestions)
+	assert.Len(g.Complete("a and b and ", 1).Suggestions, 1)
+	assert.Empty(g.Complete("a and and", 0).Suggestions)
+
+	c := g.Complete("a and b", 0)
+	assert.True(c.Complete)
+	assert.Equal([]string{"and"}, words(c))
+
+	// a rule which can match nothing before recursing
+	g = NewGrammar()
+	g.Backend = BackendChart
+	if !assert.Nil(g.LoadABNF(`#ABNF 1.0;
+root $main;
+$main = $main x | $NULL;
+`)) {
+		return
+	}
+
+	assert.Equal([]string{"x"}, words(g.Complete("x x", 0)))
+	assert.True(g.Complete("", 0).Complete)
+}
@@ -0,0 +1,144 @@
+package srgs
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var DuplicateRule = errors.New("rule is defined more than once")
+
+// A GrammarError is a problem found in a grammar document, along with where in the document it was found. It wraps
+// the underlying error, so errors.Is can be used to check for sentinels such as RuleNotFound.
+type GrammarError struct {
+	// The uri of the document, if it was loaded by uri
+	File string
+
+	// The position of the problem, starting from line 1, column 1. Both are 0 if the problem has no position, such as
+	// a root rule which is never defined.
+	Line   int
+	Column int
+
+	// The id of the rule the problem was found in, if any
+	Rule string
+
+	// The path of the XML element the problem was found in, such as "grammar/rule[2]/one-of/item[3]". Indexes are
+	// only given for elements with siblings of the same name, and ABNF documents have no element paths.
+	Path string
+
+	Err error
+}
+
+// Implements error Error method
+func (e *GrammarError) Error() string {
+	var context []string
+
+	if e.File != "" {
+		context = append(context, e.File)
+	}
+
+	if e.Line > 0 && e.Column > 0 {
+		context = append(context, fmt.Sprintf("line %d, column %d", e.Line, e.Column))
+	} else if e.Line > 0 {
+		context = append(context, fmt.Sprintf("line %d", e.Line))
+	}
+
+	if e.Rule != "" {
+		context = append(context, "rule "+e.Rule)
+	}
+
+	if e.Path != "" {
+		context = append(context, e.Path)
+	}
+
+	if len(context) == 0 {
+		return e.Err.Error()
+	}
+
+	return strings.Join(context, ", ") + ": " + e.Err.Error()
+}
+
+func (e *GrammarError) Unwrap() error {
+	return e.Err
+}
+
+// GrammarErrors are all of the problems found while loading a grammar document, in the order they appear in it.
+// errors.Is and errors.As check each of them.
+type GrammarErrors []*GrammarError
+
+// Implements error Error method, with one problem per line
+func (e GrammarErrors) Error() string {
+	lines := make([]string, len(e))
+
+	for i, err := range e {
+		lines[i] = err.Error()
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (e GrammarErrors) Unwrap() []error {
+	out := make([]error, len(e))
+
+	for i, err := range e {
+		out[i] = err
+	}
+
+	return out
+}
+
+// Collects the problems found while loading a grammar document, so that they can all be reported at once
+type loadProblems struct {
+	file string
+	errs GrammarErrors
+
+	// the local rule references made by the document, with where they were made
+	refs []refSite
+}
+
+type refSite struct {
+	ref *RuleRef
+	at  GrammarError
+}
+
+// Adds a problem found at a position of the document
+func (l *loadProblems) add(at GrammarError, err error) {
+	at.File = l.file
+	at.Err = err
+	l.errs = append(l.errs, &at)
+}
+
+// Records where a local rule reference was made, so that it can be reported if the rule is never defined
+func (l *loadProblems) addRef(ref *RuleRef, at GrammarError) {
+	l.refs = append(l.refs, refSite{ref, at})
+}
+
+// Reports every local rule reference which was never resolved
+func (l *loadProblems) checkRefs() {
+	for _, site := range l.refs {
+		if site.ref.rule == nil {
+			l.add(site.at, fmt.Errorf("%w: %s", RuleNotFound, site.ref.ruleId))
+		}
+	}
+}
+
+// Returns the problems in the order they appear in the document as an error, or nil if there were none. Problems
+// without a position come last.
+func (l *loadProblems) err() error {
+	if len(l.errs) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(l.errs, func(i, j int) bool {
+		a, b := l.errs[i], l.errs[j]
+
+		if (a.Line == 0) != (b.Line == 0) {
+			return b.Line == 0
+		}
+
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+
+	return l.errs
+}
@@ -0,0 +1,94 @@
+package srgs
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGrammarError_Xml(t *testing.T) {
+	assert := assert.New(t)
+
+	xml := `<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" xml:lang="en-US" root="example">
+	<rule id="example" scope="public">
+		<item repeat="many">hello</item>
+		<one-of>
+			<item>world</item>
+			<item><ruleref uri="#missing" /></item>
+		</one-of>
+		<unknown />
+	</rule>
+
+	<rule id="example">again</rule>
+</grammar>
+`
+
+	g := NewGrammar()
+	err := g.LoadXml(xml)
+
+	var problems GrammarErrors
+	if !assert.True(errors.As(err, &problems)) {
+		return
+	}
+
+	if !assert.Len(problems, 4) {
+		return
+	}
+
+	assert.Equal(GrammarError{Line: 4, Column: 3, Rule: "example", Path: "grammar/rule[1]/item", Err: problems[0].Err},
+		*problems[0])
+	assert.Equal("grammar/rule[1]/one-of/item[2]/ruleref", problems[1].Path)
+	assert.Equal(7, problems[1].Line)
+	assert.True(errors.Is(problems[1], RuleNotFound))
+	assert.Equal("grammar/rule[1]/unknown", problems[2].Path)
+	assert.Equal(12, problems[3].Line)
+	assert.True(errors.Is(problems[3], DuplicateRule))
+
+	assert.True(errors.Is(err, RuleNotFound))
+	assert.True(errors.Is(err, DuplicateRule))
+	assert.False(errors.Is(err, NoRoot))
+
+	assert.Contains(err.Error(), "line 7, column 10, rule example, grammar/rule[1]/one-of/item[2]/ruleref: unable to find rule: missing")
+}
+
+func TestGrammarError_Syntax(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGrammar()
+	err := g.LoadXml("<grammar root=\"example\">\n<rule id=example>hello</rule></grammar>")
+
+	var problem *GrammarError
+	if assert.True(errors.As(err, &problem)) {
+		assert.Equal(2, problem.Line)
+	}
+
+	err = g.LoadXml(`<grammar root="example"><rule id="other">hello</rule></grammar>`)
+	assert.True(errors.Is(err, RootNotFound))
+
+	assert.EqualError(&GrammarError{File: "a.grxml", Line: 3, Rule: "b", Err: NoRoot}, "a.grxml, line 3, rule b: "+NoRoot.Error())
+	assert.EqualError(&GrammarError{Err: NoRoot}, NoRoot.Error())
+}
+
+// Comments and processing instructions within a rule are skipped, but anything else unexpected is a problem
+func TestGrammarError_Tokens(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGrammar()
+	assert.Nil(g.LoadXml(`<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" xml:lang="en-US" root="example">
+	<rule id="example"><!-- a comment --> hello <?app hint?> <item>world <!-- another --></item></rule>
+</grammar>`))
+	assert.True(g.HasMatch("hello world"))
+
+	err := g.LoadXml(`<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" xml:lang="en-US" root="example">
+	<rule id="example">hello <item><!ENTITY world "world"></item></rule>
+</grammar>`)
+
+	var problems GrammarErrors
+	if assert.True(errors.As(err, &problems)) && assert.Len(problems, 1) {
+		assert.Equal("grammar/rule/item", problems[0].Path)
+		assert.EqualError(problems[0].Err, "unexpected directive in item")
+	}
+}
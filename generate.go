@@ -0,0 +1,463 @@
+package srgs
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+)
+
+var NoSentence = errors.New("unable to generate a sentence within the bounds")
+
+// The longest sentence generated when GenerateOptions has no MaxWords
+const DefaultMaxWords = 20
+
+// The most rules a sampled sentence may pass through before the attempt is abandoned, which stops recursive rules
+// from being sampled indefinitely
+const maxSampleDepth = 1000
+
+// The number of times a sample is attempted before giving up
+const maxSampleAttempts = 100
+
+// GenerateOptions control the sentences generated from a grammar
+type GenerateOptions struct {
+	// The public rule to generate sentences from. Defaults to the root rule.
+	Rule string
+
+	// Sentences with more words than this are not generated. Defaults to DefaultMaxWords.
+	MaxWords int
+
+	// If positive, at most this many sentences are enumerated
+	MaxSentences int
+
+	// If set, the path of each sentence through the grammar is scanned into a new processor from NewProcessor, which
+	// gives the interpretation and SISR instance of the sentence
+	NewProcessor func() Processor
+}
+
+// A Sentence generated from a grammar
+type Sentence struct {
+	Text string
+
+	// The processor the sentence was scanned into, if GenerateOptions has a NewProcessor
+	Processor Processor
+}
+
+// Returns the distinct sentences the grammar matches, in the order they appear in the grammar. GARBAGE generates the
+// fewest words it can match, repeating a filler word which is not one of its stop words, so that every sentence is
+// matched by the grammar.
+func (g *Grammar) Enumerate(opts GenerateOptions) ([]Sentence, error) {
+	gen, err := g.newGenerator(opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Sentence
+	seen := make(map[string]bool)
+
+	gen.walk(gen.ref, generated{}, func(s generated) bool {
+		text := strings.Join(s.words, " ")
+
+		if !seen[text] {
+			seen[text] = true
+			out = append(out, gen.sentence(s))
+		}
+
+		return opts.MaxSentences <= 0 || len(out) < opts.MaxSentences
+	})
+
+	return out, nil
+}
+
+// Returns n randomly sampled sentences of the grammar. Alternatives are chosen according to their weights, and the
+// number of repeats of an item according to its repeat probability, or uniformly if it has none (see chooseRepeats).
+// Sentences may repeat.
+func (g *Grammar) Sample(n int, rnd *rand.Rand, opts GenerateOptions) ([]Sentence, error) {
+	gen, err := g.newGenerator(opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !gen.productive[gen.ref] {
+		return nil, NoSentence
+	}
+
+	gen.rnd = rnd
+
+	var out []Sentence
+
+	for len(out) < n {
+		s, ok := gen.sampleSentence()
+
+		if !ok {
+			return out, NoSentence
+		}
+
+		out = append(out, gen.sentence(s))
+	}
+
+	return out, nil
+}
+
+// The words of a sentence being generated, and its path through the grammar
+type generated struct {
+	words []string
+	path  scanPath
+}
+
+// A path through a grammar which was generated rather than matched
+type scanPath []func(Processor)
+
+// Implements matchPath Scan method
+func (s scanPath) Scan(p Processor) {
+	for _, scan := range s {
+		scan(p)
+	}
+}
+
+func (s generated) addWords(words ...string) generated {
+	s.words = append(s.words[:len(s.words):len(s.words)], words...)
+	return s
+}
+
+func (s generated) addScan(scan func(Processor)) generated {
+	s.path = append(s.path[:len(s.path):len(s.path)], scan)
+	return s
+}
+
+// A rule being generated with a number of words already generated, used to stop left recursive rules from nesting
+// indefinitely
+type generatedVisit struct {
+	rule  Expansion
+	words int
+}
+
+type generator struct {
+	ref          *RuleRef
+	maxWords     int
+	newProcessor func() Processor
+
+	productive map[Expansion]bool
+	active     map[generatedVisit]int
+	tokens     map[*Token][]string
+
+	rnd *rand.Rand
+}
+
+func (g *Grammar) newGenerator(opts GenerateOptions) (*generator, error) {
+	ref := g.Root
+
+	if opts.Rule != "" {
+		var err error
+		if ref, err = g.publicRule(opts.Rule); err != nil {
+			return nil, err
+		}
+	}
+
+	if ref == nil {
+		return nil, NoRoot
+	}
+
+	gen := &generator{
+		ref:          ref,
+		maxWords:     opts.MaxWords,
+		newProcessor: opts.NewProcessor,
+		productive:   findProductive(ref),
+		active:       make(map[generatedVisit]int),
+		tokens:       make(map[*Token][]string),
+	}
+
+	if gen.maxWords <= 0 {
+		gen.maxWords = DefaultMaxWords
+	}
+
+	return gen, nil
+}
+
+func (gen *generator) sentence(s generated) Sentence {
+	out := Sentence{Text: strings.Join(s.words, " ")}
+
+	if gen.newProcessor != nil {
+		out.Processor = gen.newProcessor()
+		scanMatch(gen.ref, s.path, out.Processor)
+	}
+
+	return out
+}
+
+func (gen *generator) tokenWords(t *Token) []string {
+	words, ok := gen.tokens[t]
+
+	if !ok {
+		if t.token != "" {
+			words = strings.Split(t.token, " ")
+		}
+
+		gen.tokens[t] = words
+	}
+
+	return words
+}
+
+// Walks each way an expansion can be generated after s, calling next with each one. Returns false once no more
+// sentences are needed.
+func (gen *generator) walk(exp Expansion, s generated, next func(generated) bool) bool {
+	switch e := exp.(type) {
+	case *Token:
+		words := gen.tokenWords(e)
+
+		if len(s.words)+len(words) > gen.maxWords {
+			return true
+		}
+
+		return next(s.addWords(words...).addScan(func(p Processor) { p.AppendString(e.token) }))
+	case *Sequence:
+		return gen.sequence(e.exps, s, next)
+	case *Alternative:
+		for _, item := range e.items {
+			if !gen.walk(item, s, next) {
+				return false
+			}
+		}
+
+		return true
+	case *Item:
+		return gen.item(e, 0, s, next)
+	case *RuleRef:
+		visit := generatedVisit{e.rule, len(s.words)}
+
+		// a left recursive rule can only nest once for each word it has left to generate
+		if gen.active[visit] > gen.maxWords-len(s.words) {
+			return true
+		}
+
+		gen.active[visit]++
+		start := len(s.path)
+
+		ok := gen.walk(e.rule, s, func(after generated) bool {
+			rule := after.path[start:]
+			after.path = after.path[:start]
+
+			return next(after.addScan(func(p Processor) { scanRuleRef(p, e, rule) }))
+		})
+
+		gen.active[visit]--
+
+		return ok
+	case *Tag:
+		return next(s.addScan(func(p Processor) { scanTag(p, e) }))
+	case *Garbage:
+		words, ok := garbageWords(e)
+
+		if !ok || len(s.words)+len(words) > gen.maxWords {
+			return true
+		}
+
+		text := strings.Join(words, " ")
+
+		return next(s.addWords(words...).addScan(func(p Processor) { scanGarbage(p, text, e.scanMatch) }))
+	case *Void:
+		return true
+	}
+
+	// NULL generates nothing
+	return next(s)
+}
+
+func (gen *generator) sequence(exps []Expansion, s generated, next func(generated) bool) bool {
+	if len(exps) == 0 {
+		return next(s)
+	}
+
+	return gen.walk(exps[0], s, func(rest generated) bool {
+		return gen.sequence(exps[1:], rest, next)
+	})
+}
+
+// Walks the repeats of an item after count repeats have already been generated
+func (gen *generator) item(it *Item, count int, s generated, next func(generated) bool) bool {
+	if count >= it.repeatMin && !next(s) {
+		return false
+	}
+
+	if !it.canRepeat(count) {
+		return true
+	}
+
+	return gen.walk(it.child, s, func(rest generated) bool {
+		// a repeat which generates nothing only helps to reach the minimum number of repeats
+		if len(rest.words) == len(s.words) && count >= it.repeatMin {
+			return true
+		}
+
+		return gen.item(it, count+1, rest, next)
+	})
+}
+
+// Samples a single sentence, retrying whenever a sample goes beyond the bounds
+func (gen *generator) sampleSentence() (generated, bool) {
+	for attempt := 0; attempt < maxSampleAttempts; attempt++ {
+		s := new(generated)
+
+		if gen.sample(gen.ref, s, 0) {
+			return *s, true
+		}
+	}
+
+	return generated{}, false
+}
+
+// Samples an expansion onto the end of s. Returns false if the sample goes beyond the bounds.
+func (gen *generator) sample(exp Expansion, s *generated, depth int) bool {
+	switch e := exp.(type) {
+	case *Token:
+		*s = s.addWords(gen.tokenWords(e)...).addScan(func(p Processor) { p.AppendString(e.token) })
+
+		return len(s.words) <= gen.maxWords
+	case *Sequence:
+		for _, child := range e.exps {
+			if !gen.sample(child, s, depth) {
+				return false
+			}
+		}
+
+		return true
+	case *Alternative:
+		return gen.sample(gen.chooseAlternative(e), s, depth)
+	case *Item:
+		if !gen.productive[e.child] {
+			return true
+		}
+
+		repeats := gen.chooseRepeats(e, gen.maxWords-len(s.words))
+
+		for i := 0; i < repeats; i++ {
+			if !gen.sample(e.child, s, depth) {
+				return false
+			}
+		}
+
+		return true
+	case *RuleRef:
+		if depth >= maxSampleDepth {
+			return false
+		}
+
+		start := len(s.path)
+
+		if !gen.sample(e.rule, s, depth+1) {
+			return false
+		}
+
+		rule := s.path[start:]
+		s.path = s.path[:start]
+		*s = s.addScan(func(p Processor) { scanRuleRef(p, e, rule) })
+
+		return true
+	case *Tag:
+		*s = s.addScan(func(p Processor) { scanTag(p, e) })
+	case *Garbage:
+		words, ok := garbageWords(e)
+
+		if !ok {
+			return false
+		}
+
+		text := strings.Join(words, " ")
+		*s = s.addWords(words...).addScan(func(p Processor) { scanGarbage(p, text, e.scanMatch) })
+
+		return len(s.words) <= gen.maxWords
+	case *Void:
+		return false
+	}
+
+	return true
+}
+
+// The words garbage is generated from, the first of which is not a stop word
+var garbageFillers = []string{"something", "anything", "whatever"}
+
+// Returns the words generated for garbage, which are the fewest it can match. Returns false if every filler word is a
+// stop word of the garbage.
+func garbageWords(g *Garbage) ([]string, bool) {
+	if g.minWords == 0 {
+		return nil, true
+	}
+
+	for _, filler := range garbageFillers {
+		if !g.stopWords[filler] {
+			words := make([]string, g.minWords)
+
+			for i := range words {
+				words[i] = filler
+			}
+
+			return words, true
+		}
+	}
+
+	return nil, false
+}
+
+// Chooses one of the productive alternatives at random, according to their weights
+func (gen *generator) chooseAlternative(a *Alternative) Expansion {
+	var items []Expansion
+	var weights []float64
+	total := 0.0
+
+	for _, item := range a.items {
+		if !gen.productive[item] {
+			continue
+		}
+
+		weight := 1.0
+		if it, ok := item.(*Item); ok {
+			weight = it.weight
+		}
+
+		items = append(items, item)
+		weights = append(weights, weight)
+		total += weight
+	}
+
+	if total <= 0 {
+		return items[gen.rnd.Intn(len(items))]
+	}
+
+	r := gen.rnd.Float64() * total
+
+	for i, weight := range weights {
+		if r < weight {
+			return items[i]
+		}
+
+		r -= weight
+	}
+
+	return items[len(items)-1]
+}
+
+// Chooses the number of repeats of an item. With a repeat probability p, each repeat beyond the minimum happens with
+// probability p. Otherwise every number of repeats is equally likely, or for an unbounded item, each repeat beyond the
+// minimum happens with probability 1/2. Since a repeat which adds to the sentence adds at least one word, there are no
+// more repeats beyond the minimum than words left, so that a probability of 1 still ends.
+func (gen *generator) chooseRepeats(it *Item, words int) int {
+	prob := it.repeatProb
+
+	if prob < 0 {
+		if it.repeatMax != RepeatUnbounded {
+			return it.repeatMin + gen.rnd.Intn(it.repeatMax-it.repeatMin+1)
+		}
+
+		prob = 0.5
+	}
+
+	n := it.repeatMin
+
+	for it.canRepeat(n) && n < words && gen.rnd.Float64() < prob {
+		n++
+	}
+
+	return n
+}
@@ -0,0 +1,198 @@
+package srgs
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func texts(sentences []Sentence) []string {
+	var out []string
+
+	for _, sentence := range sentences {
+		out = append(out, sentence.Text)
+	}
+
+	return out
+}
+
+func TestEnumerate(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGrammar()
+	if !assert.Nil(g.LoadXml(nameXml)) {
+		return
+	}
+
+	sentences, err := g.Enumerate(GenerateOptions{})
+	assert.Nil(err)
+	assert.Equal([]string{
+		"my name is is rob", "my name is ram", "my name is kaustav", "my name is rob", "my name ram", "my name kaustav",
+	}, texts(sentences))
+
+	for _, sentence := range sentences {
+		assert.True(g.HasMatch(sentence.Text), sentence.Text)
+	}
+
+	sentences, err = g.Enumerate(GenerateOptions{MaxSentences: 2})
+	assert.Nil(err)
+	assert.Equal([]string{"my name is is rob", "my name is ram"}, texts(sentences))
+
+	sentences, err = g.Enumerate(GenerateOptions{MaxWords: 3})
+	assert.Nil(err)
+	assert.Equal([]string{"my name ram", "my name kaustav"}, texts(sentences))
+}
+
+func TestEnumerate_RecursionAndRepeats(t *testing.T) {
+	assert := assert.New(t)
+
+	abnf := `#ABNF 1.0 UTF-8;
+root $start;
+
+public $start = $list | go $right;
+$list = $list and $x | $x;
+$x = a | b;
+$right = on $right | (stop [now]) <2> | $VOID;
+`
+
+	g := NewGrammar()
+	if !assert.Nil(g.LoadABNF(abnf)) {
+		return
+	}
+
+	sentences, err := g.Enumerate(GenerateOptions{MaxWords: 3})
+	assert.Nil(err)
+	assert.ElementsMatch([]string{
+		"a", "b", "a and a", "a and b", "b and a", "b and b", "go stop stop",
+	}, texts(sentences))
+
+	sentences, err = g.Enumerate(GenerateOptions{MaxWords: 4, Rule: "start", MaxSentences: 100})
+	assert.Nil(err)
+	assert.Contains(texts(sentences), "go stop now stop")
+	assert.Contains(texts(sentences), "go on stop stop")
+	assert.NotContains(texts(sentences), "go on on stop stop")
+
+	_, err = g.Enumerate(GenerateOptions{Rule: "x"})
+	assert.True(errors.Is(err, PrivateRule))
+}
+
+func TestEnumerate_Sisr(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGrammar()
+	if !assert.Nil(g.LoadXml(cityXml)) {
+		return
+	}
+
+	sentences, err := g.Enumerate(GenerateOptions{NewProcessor: newSISRProcessor})
+	if !assert.Nil(err) {
+		return
+	}
+
+	// the second portland has the same text, so only the first one is enumerated
+	assert.Equal([]string{"to portland", "to boston", "please to portland", "please to boston"}, texts(sentences))
+
+	var instances []string
+	for _, sentence := range sentences {
+		inst, err := sentence.Processor.GetInstance()
+		assert.Nil(err)
+		instances = append(instances, inst)
+	}
+
+	assert.Equal([]string{"PDX", "BOS", "PDX", "BOS"}, instances)
+}
+
+func TestSample(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGrammar()
+	if !assert.Nil(g.LoadXml(cityXml)) {
+		return
+	}
+
+	sentences, err := g.Sample(2000, rand.New(rand.NewSource(1)), GenerateOptions{NewProcessor: newSISRProcessor})
+	if !assert.Nil(err) {
+		return
+	}
+
+	counts := make(map[string]int)
+	please := 0
+
+	for _, sentence := range sentences {
+		assert.True(g.HasMatch(sentence.Text), sentence.Text)
+
+		inst, err := sentence.Processor.GetInstance()
+		assert.Nil(err)
+		counts[inst]++
+
+		if sentence.Processor.GetInterpretation() != sentence.Text {
+			t.Errorf("interpretation %q of %q", sentence.Processor.GetInterpretation(), sentence.Text)
+		}
+
+		if len(sentence.Text) > 7 && sentence.Text[:7] == "please " {
+			please++
+		}
+	}
+
+	// the cities have weights 3, 1 and 4, and please has a repeat probability of 0.8
+	assert.InDelta(0.375, float64(counts["PDX"])/2000, 0.03)
+	assert.InDelta(0.125, float64(counts["PWM"])/2000, 0.03)
+	assert.InDelta(0.5, float64(counts["BOS"])/2000, 0.03)
+	assert.InDelta(0.8, float64(please)/2000, 0.03)
+}
+
+func TestSample_Bounds(t *testing.T) {
+	assert := assert.New(t)
+
+	abnf := `#ABNF 1.0 UTF-8;
+root $start;
+
+public $start = $list | $VOID;
+$list = $list and $x | $x;
+$x = a | b;
+`
+
+	g := NewGrammar()
+	if !assert.Nil(g.LoadABNF(abnf)) {
+		return
+	}
+
+	sentences, err := g.Sample(100, rand.New(rand.NewSource(1)), GenerateOptions{MaxWords: 5})
+	assert.Nil(err)
+	assert.Len(sentences, 100)
+
+	for _, sentence := range sentences {
+		assert.True(len(sentence.Text) <= len("a and b and a"), sentence.Text)
+	}
+
+	g.LoadABNF("#ABNF 1.0 UTF-8;\nroot $start;\npublic $start = a $VOID;\n")
+	_, err = g.Sample(1, rand.New(rand.NewSource(1)), GenerateOptions{})
+	assert.Equal(NoSentence, err)
+}
+
+// An unbounded item which always repeats stops once the sentence has no words left
+func TestSample_CertainRepeats(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGrammar()
+	if !assert.Nil(g.LoadXml(`<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" xml:lang="en-US" root="main">
+	<rule id="main">hello <item repeat="1-" repeat-prob="1">there</item></rule>
+</grammar>`)) {
+		return
+	}
+
+	sentences, err := g.Sample(3, rand.New(rand.NewSource(1)), GenerateOptions{MaxWords: 5})
+	if assert.Nil(err) {
+		for _, sentence := range sentences {
+			assert.Equal("hello there there there there", sentence.Text)
+		}
+	}
+
+	sentences, err = g.Sample(1, rand.New(rand.NewSource(1)), GenerateOptions{})
+	if assert.Nil(err) {
+		assert.Equal(DefaultMaxWords, len(strings.Fields(sentences[0].Text)))
+	}
+}
@@ -1,9 +1,12 @@
 package srgs
 
 import (
+	"encoding/xml"
 	"errors"
 	"fmt"
 	"github.com/beevik/etree"
+	"math"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -26,10 +29,23 @@ var (
 	RootNotFound       = errors.New("unable to find root rule")
 	UnidentifiableRule = errors.New("rules must have an id")
 	EmptyRuleRefUri    = errors.New("rulerefs must have a non-empty uri")
+	UnknownSpecialRule = errors.New("unknown special rule")
+	RuleNotFound       = errors.New("unable to find rule")
+	PrivateRule        = errors.New("cannot reference a private rule from outside its grammar")
 )
 
-// An expansion is any part of a grammar that can match a string
+// An expansion is any part of a grammar that can match a string. Expansions are immutable once a grammar has been
+// loaded, so a single grammar can be matched by any number of goroutines at once. The state of an individual match
+// is held by the Matcher returned from NewMatcher.
 type Expansion interface {
+	// Returns a new matcher for the expansion. A single path through a grammar may reference the same rule multiple
+	// times, so each matcher holds its own state, independent of any other matcher of the same expansion.
+	NewMatcher() Matcher
+}
+
+// A Matcher holds the state of a single match against an Expansion. Matchers are not safe for concurrent use; create
+// a new matcher for each goroutine instead.
+type Matcher interface {
 	// Set the string to match on the expansion. Match either in ModePrefix (return nil error as soon as a prefix is
 	// found) or ModeExact (return nil only if there is an exact match -- otherwise return PrefixOnly error)
 	Match(string, MatchMode)
@@ -38,13 +54,13 @@ type Expansion interface {
 	// next version of the consumed string. Otherwise return "" and Exhausted error
 	Next() (string, error)
 
-	// Append this expansion to a processor. This will be enable the Processor to provide the output for a given path
+	// Append this matcher's current path to a processor. This will be enable the Processor to provide the output for
+	// a given path
 	Scan(Processor)
 
-	// Returns a copy of the expansion. This is needed because expansions implementations are stateful, and a single
-	// path through a grammar may reference the same rule multiple times. In that case, the states of each reference
-	// must be independent.
-	Copy(RuleRefs) Expansion
+	// Returns the probability of the matcher's current path, based on the weights of the alternatives and the repeat
+	// probabilities of the items along it
+	Score() float64
 }
 
 // State is the internal representation of an Expansion which matched an utterance. This is used to determine the
@@ -60,14 +76,45 @@ type SequenceState []State
 
 type ItemState []State
 
-// Grammar is a representation of an SRGS grammar.
+// Grammar is a representation of an SRGS grammar. Once loaded, a grammar is safe for concurrent use by multiple
+// goroutines.
 type Grammar struct {
 	Root *RuleRef
 	Xml  string
+	Abnf string
+
+	// Loads the documents of rulerefs which are not local to this grammar. If nil, only local rulerefs are allowed.
+	Resolver Resolver
+
+	// The algorithm used to match strings against the grammar. Defaults to BackendMatcher.
+	Backend Backend
+
+	// Puts the grammar's tokens and the strings matched against it into the same form. The tokens are normalized as
+	// the grammar is loaded, so the Normalizer must be set before loading it.
+	Normalizer Normalizer
 
 	root     Expansion
 	rules    Rules
+	scopes   map[string]RuleScope
 	ruleRefs RuleRefs
+
+	// the ids of the rules in the order they were defined, so that they can be written out in the same order
+	ruleOrder []string
+
+	// the language, mode and tag format declared by the grammar's document, if any
+	lang      string
+	mode      string
+	tagFormat string
+
+	// the uri of this grammar's document (empty unless loaded by uri), and the base uri which relative rulerefs are
+	// resolved against
+	uri  string
+	base string
+
+	imports *imports
+
+	// the compiled automaton used by HasPrefix and HasMatch, if the grammar has been compiled
+	automaton *Automaton
 }
 
 // Creates a new grammar
@@ -78,119 +125,271 @@ func NewGrammar() *Grammar {
 // Returns whether a specific string is a prefix of the grammar. For example, a grammar that matches the string
 // "i want to go to the park", will also return true for HasPrefix("i want to g")
 func (g *Grammar) HasPrefix(str string) bool {
-	str = strings.ToLower(str)
-	g.Root.Match(str, ModePrefix)
-	str, err := g.Root.Next()
-
-	for {
-		if err != nil {
-			return false
-		}
+	if g.automaton != nil {
+		return g.automaton.HasPrefix(g.normalizeInput(str, ModePrefix))
+	}
 
-		if len(str) == 0 {
-			return true
-		}
+	_, err := g.match(g.Root, str, ModePrefix)
 
-		str, err = g.Root.Next()
-	}
+	return err == nil
 }
 
 // Returns whether a specific string is an exact match for the grammar. Note that this means the string is not a prefix
 // and it is also not longer than the grammar.
 func (g *Grammar) HasMatch(str string) bool {
-	str = strings.ToLower(str)
-	g.Root.Match(str, ModeExact)
-	str, err := g.Root.Next()
+	if g.automaton != nil {
+		return g.automaton.HasMatch(g.normalizeInput(str, ModeExact))
+	}
 
-	for {
-		if err != nil {
-			return false
-		}
+	_, err := g.match(g.Root, str, ModeExact)
 
-		if len(str) == 0 {
-			return true
+	return err == nil
+}
+
+// Uses a processor to find a match and scan the match into the processor for SISR
+func (g *Grammar) GetMatch(str string, p Processor) error {
+	return g.getMatch(g.Root, str, p)
+}
+
+// Returns the ids of the grammar's public rules, in alphabetical order. Any of them can be matched with HasPrefixRule,
+// HasMatchRule and GetMatchRule.
+func (g *Grammar) PublicRules() []string {
+	var ids []string
+
+	for id, scope := range g.scopes {
+		if scope == ScopePublic {
+			ids = append(ids, id)
 		}
+	}
+
+	sort.Strings(ids)
+
+	return ids
+}
 
-		str, err = g.Root.Next()
+// Returns the scope of a rule of the grammar, and whether the rule exists
+func (g *Grammar) RuleScope(id string) (RuleScope, bool) {
+	scope, ok := g.scopes[id]
+
+	return scope, ok
+}
+
+// Same as HasPrefix, but matches against a public rule of the grammar instead of the root rule
+func (g *Grammar) HasPrefixRule(id, str string) bool {
+	ref, err := g.publicRule(id)
+
+	if err != nil {
+		return false
 	}
+
+	_, err = g.match(ref, str, ModePrefix)
+
+	return err == nil
 }
 
-// Uses a processor to find a match and scan the match into the processor for SISR
-func (g *Grammar) GetMatch(str string, p Processor) error {
-	str = strings.ToLower(str)
-	g.Root.Match(str, ModeExact)
-	str, err := g.Root.Next()
+// Same as HasMatch, but matches against a public rule of the grammar instead of the root rule
+func (g *Grammar) HasMatchRule(id, str string) bool {
+	ref, err := g.publicRule(id)
+
+	if err != nil {
+		return false
+	}
+
+	_, err = g.match(ref, str, ModeExact)
+
+	return err == nil
+}
+
+// Same as GetMatch, but matches against a public rule of the grammar instead of the root rule
+func (g *Grammar) GetMatchRule(id, str string, p Processor) error {
+	ref, err := g.publicRule(id)
+
+	if err != nil {
+		return err
+	}
+
+	return g.getMatch(ref, str, p)
+}
+
+// Returns a reference to a public rule, which can be used as an entry point to the grammar
+func (g *Grammar) publicRule(id string) (*RuleRef, error) {
+	rule, ok := g.rules[id]
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", RuleNotFound, id)
+	}
+
+	if g.scopes[id] != ScopePublic {
+		return nil, fmt.Errorf("%w: %s", PrivateRule, id)
+	}
+
+	return &RuleRef{ruleId: id, rule: rule, format: g.sisrFormat()}, nil
+}
+
+func (g *Grammar) getMatch(ref *RuleRef, str string, p Processor) error {
+	m, err := g.match(ref, str, ModeExact)
+
+	if err != nil {
+		return err
+	}
+
+	scanMatch(ref, m, p)
+
+	return nil
+}
+
+// A path through a grammar which matched a string, such as a Matcher after a successful match
+type matchPath interface {
+	Scan(Processor)
+}
+
+// Scans the path of a matcher which matched a rule into a processor
+func scanMatch(ref *RuleRef, m matchPath, p Processor) {
+	scanEvent(p, sisrEvent{kind: eventMatchStart})
+	m.Scan(p)
+	scanEvent(p, sisrEvent{kind: eventMatchEnd, ref: ref})
+}
+
+// Matches a string against a rule with the grammar's backend, and returns the path which consumed the whole string
+func (g *Grammar) match(ref *RuleRef, str string, mode MatchMode) (matchPath, error) {
+	str = g.normalizeInput(str, mode)
+
+	if g.Backend == BackendChart {
+		return parseChart(ref, str, mode)
+	}
+
+	m := ref.NewMatcher()
+	m.Match(str, mode)
+	str, err := m.Next()
 
 	for {
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		if len(str) == 0 {
-			break
+			return m, nil
 		}
-		str, err = g.Root.Next()
-	}
-
-	p.AppendTag("var scopes = [{'rules':{}}];")
-	g.Root.Scan(p)
-	p.AppendTag(fmt.Sprintf("root = scopes[0]['rules']['%s'];", g.Root.ruleId))
 
-	return nil
+		str, err = m.Next()
+	}
 }
 
 // Loads an XML document into a grammar
 func (g *Grammar) LoadXml(xml string) error {
-	g.Xml = xml
+	g.uri = ""
+	g.imports = newImports(g)
+	defer g.clearImports()
+
+	return g.loadXml(xml)
+}
 
+func (g *Grammar) loadXml(src string) error {
+	g.Xml = src
+
+	problems := &loadProblems{file: g.uri}
 	doc := etree.NewDocument()
 
-	if err := doc.ReadFromString(xml); err != nil {
-		return err
+	if err := doc.ReadFromString(src); err != nil {
+		var at GrammarError
+
+		if syntax, ok := err.(*xml.SyntaxError); ok {
+			at.Line = syntax.Line
+		}
+
+		problems.add(at, err)
+
+		return problems.err()
 	}
 
 	grammar := doc.SelectElement("grammar")
 
 	if grammar == nil {
-		return InvalidGrammar
+		problems.add(GrammarError{}, InvalidGrammar)
+
+		return problems.err()
 	}
 
+	d := &xmlDecoder{g: g, problems: problems, positions: elementPositions(src, doc)}
+
 	rootId := grammar.SelectAttrValue("root", "")
 
-	if rootId == "" {
-		return NoRoot
+	if rootId == "" && !g.imported() {
+		d.add(grammar, NoRoot)
 	}
 
-	var root Expansion
+	g.resetRules()
+	g.base = grammar.SelectAttrValue("xml:base", g.uri)
+	g.lang = grammar.SelectAttrValue("xml:lang", "")
+	g.mode = grammar.SelectAttrValue("mode", "")
+	g.tagFormat = grammar.SelectAttrValue("tag-format", "")
+
+	for _, rule := range grammar.SelectElements("rule") {
+		d.decodeRule(rule)
+	}
+
+	d.rule = ""
+	problems.checkRefs()
+
+	// unresolved rule references have already been reported where they were made
+	g.ruleRefs = make(RuleRefs)
+
+	if err := g.link(rootId); err != nil && rootId != "" {
+		d.add(grammar, fmt.Errorf("%w: %s", err, rootId))
+	}
+
+	return problems.err()
+}
 
+// Clears any rules and declarations from a previous load
+func (g *Grammar) resetRules() {
 	g.rules = Rules{}
+	g.scopes = make(map[string]RuleScope)
+	g.ruleOrder = nil
+	g.lang, g.mode, g.tagFormat = "", "", ""
 
 	// holds references to a given rule id so that they can be filled in once all rules have been processed
-	g.ruleRefs = make(map[string][]*RuleRef)
+	g.ruleRefs = make(RuleRefs)
+	g.automaton = nil
+}
 
-	for _, rule := range grammar.SelectElements("rule") {
-		id, exp, err := g.decodeRule(rule)
+// Adds a decoded rule to the grammar, and fills in any references to it that were decoded before the rule itself
+func (g *Grammar) addRule(id string, scope RuleScope, exp Expansion) {
+	g.rules[id] = exp
+	g.scopes[id] = scope
+	g.ruleOrder = append(g.ruleOrder, id)
 
-		if err != nil {
-			return err
+	if refs, ok := g.ruleRefs[id]; ok {
+		for _, ref := range refs {
+			ref.rule = exp
 		}
 
-		if id == rootId {
-			root = exp
-		}
+		delete(g.ruleRefs, id)
+	}
+}
 
-		g.rules[id] = exp
+// Returns a reference to a rule of this grammar. If the rule has not been added yet, the reference is filled in when
+// it is
+func (g *Grammar) newRuleRef(id string) *RuleRef {
+	ruleRef := new(RuleRef)
+	ruleRef.ruleId = id
+	ruleRef.format = g.sisrFormat()
+
+	if rule, ok := g.rules[id]; ok {
+		ruleRef.rule = rule
+	} else {
+		g.ruleRefs[id] = append(g.ruleRefs[id], ruleRef)
+	}
 
-		if refs, ok := g.ruleRefs[id]; ok {
-			for _, ref := range refs {
-				ref.rule = exp.Copy(g.ruleRefs)
-			}
+	return ruleRef
+}
 
-			delete(g.ruleRefs, id)
-		}
-	}
+// Sets the root rule once all rules have been added, and ensures that every rule reference was resolved. Grammars
+// which are only imported by other grammars do not need a root rule.
+func (g *Grammar) link(rootId string) error {
+	root, ok := g.rules[rootId]
 
-	if root == nil {
+	if !ok && (rootId != "" || !g.imported()) {
 		return RootNotFound
 	}
 
@@ -202,32 +401,81 @@ func (g *Grammar) LoadXml(xml string) error {
 		return errors.New("unresolved rule refs: " + strings.TrimSuffix(refs, ", "))
 	}
 
+	if !ok {
+		g.Root = nil
+		return nil
+	}
+
 	g.Root = &RuleRef{
 		ruleId: rootId,
 		rule:   root,
+		format: g.sisrFormat(),
 	}
 
 	return nil
 }
 
-func (g *Grammar) decodeRule(rule *etree.Element) (string, Expansion, error) {
+// Decodes the rules of an XML grammar document, collecting every problem found along the way
+type xmlDecoder struct {
+	g        *Grammar
+	problems *loadProblems
+
+	positions map[*etree.Element]position
+
+	// the id of the rule being decoded
+	rule string
+}
+
+// The line and column an element starts at
+type position struct {
+	line   int
+	column int
+}
+
+// Returns the location of an element, to report a problem found in it
+func (d *xmlDecoder) at(el *etree.Element) GrammarError {
+	pos := d.positions[el]
+
+	return GrammarError{Line: pos.line, Column: pos.column, Rule: d.rule, Path: elementPath(el)}
+}
+
+// Reports a problem found in an element
+func (d *xmlDecoder) add(el *etree.Element, err error) {
+	d.problems.add(d.at(el), err)
+}
+
+func (d *xmlDecoder) decodeRule(rule *etree.Element) {
 	id := rule.SelectAttrValue("id", "")
+	d.rule = id
 
 	if id == "" {
-		return "", nil, UnidentifiableRule
+		d.add(rule, UnidentifiableRule)
+		return
 	}
 
-	exp, err := g.decodeElement(rule)
+	scope := RuleScope(rule.SelectAttrValue("scope", string(ScopePrivate)))
 
-	return id, exp, err
+	if scope != ScopePublic && scope != ScopePrivate {
+		d.add(rule, errors.New("invalid scope "+string(scope)))
+		scope = ScopePrivate
+	}
+
+	if _, ok := d.g.rules[id]; ok {
+		d.add(rule, DuplicateRule)
+		return
+	}
+
+	d.g.addRule(id, scope, d.decodeElement(rule))
 }
 
-func (g *Grammar) decodeElement(element *etree.Element) (Expansion, error) {
+// Decodes the contents of an element. Problems are reported and the offending element skipped, so that the rest of
+// the document can still be checked.
+func (d *xmlDecoder) decodeElement(element *etree.Element) Expansion {
 	out := new(Sequence)
 
 	for _, tok := range element.Child {
 		if data, ok := tok.(*etree.CharData); ok {
-			str := strings.ToLower(strings.TrimSpace(data.Data))
+			str := d.g.Normalize(data.Data)
 
 			if len(str) == 0 {
 				continue
@@ -236,106 +484,359 @@ func (g *Grammar) decodeElement(element *etree.Element) (Expansion, error) {
 			out.exps = append(out.exps, decodeCharData(str))
 		} else if el, ok := tok.(*etree.Element); ok {
 			if el.Tag == "ruleref" {
-				special := el.SelectAttrValue("special", "")
-
-				if special == "GARBAGE" {
-					tempGarbage := new(Garbage)
-					out.exps = append(out.exps, tempGarbage)
-					scan := el.SelectAttrValue("scan-match", "")
-					if scan == "true" {
-						tempGarbage.scanMatch = true
+				switch special := el.SelectAttrValue("special", ""); special {
+				case "":
+				case "GARBAGE":
+					if garbage, err := decodeGarbage(el, d.g.Normalize); err != nil {
+						d.add(el, err)
+					} else {
+						out.exps = append(out.exps, garbage)
 					}
 					continue
+				case "NULL":
+					out.exps = append(out.exps, new(Null))
+					continue
+				case "VOID":
+					out.exps = append(out.exps, new(Void))
+					continue
+				default:
+					d.add(el, fmt.Errorf("%w: %s", UnknownSpecialRule, special))
+					continue
 				}
 
 				ref := el.SelectAttrValue("uri", "")
 
 				if ref == "" {
-					return nil, EmptyRuleRefUri
+					d.add(el, EmptyRuleRefUri)
+					continue
 				}
 
 				if ref[0] != '#' {
-					return nil, errors.New("cannot understand ruleref uri " + ref + " because it is not local")
-				}
-
-				ruleRef := new(RuleRef)
-				ruleRef.ruleId = ref[1:]
+					ruleRef, err := d.g.externalRuleRef(ref)
 
-				out.exps = append(out.exps, ruleRef)
+					if err != nil {
+						d.add(el, err)
+						continue
+					}
 
-				if rule, ok := g.rules[ruleRef.ruleId]; ok {
-					ruleRef.rule = rule.Copy(g.ruleRefs)
-				} else {
-					g.ruleRefs[ruleRef.ruleId] = append(g.ruleRefs[ruleRef.ruleId], ruleRef)
+					out.exps = append(out.exps, ruleRef)
+					continue
 				}
-			} else if el.Tag == "item" {
-				exp, err := g.decodeElement(el)
 
-				if err != nil {
-					return nil, err
-				}
+				ruleRef := d.g.newRuleRef(ref[1:])
+				d.problems.addRef(ruleRef, d.at(el))
 
-				out.exps = append(out.exps, exp)
+				out.exps = append(out.exps, ruleRef)
+			} else if el.Tag == "item" {
+				out.exps = append(out.exps, d.decodeElement(el))
 			} else if el.Tag == "one-of" {
 				alt := new(Alternative)
 				for _, item := range el.SelectElements("item") {
-					exp, err := g.decodeElement(item)
-
-					if err != nil {
-						return nil, err
-					}
-
-					alt.items = append(alt.items, exp.(*Item))
+					alt.items = append(alt.items, d.decodeElement(item).(*Item))
 				}
 
 				out.exps = append(out.exps, alt)
 			} else if el.Tag == "tag" {
-				out.exps = append(out.exps, NewTag(el.Text()))
+				out.exps = append(out.exps, d.g.newTag(el.Text()))
 			} else if el.Tag == "example" {
 				// ignore
 			} else {
-				return nil, errors.New("unable to parse tag " + el.Tag + " " + el.SelectAttrValue("id", "no id"))
+				d.add(el, errors.New("unable to parse tag "+el.Tag))
 			}
+		} else if _, ok := tok.(*etree.Comment); ok {
+			// comments have no meaning in a grammar
+		} else if _, ok := tok.(*etree.ProcInst); ok {
+			// nor do processing instructions within a rule
 		} else {
-			fmt.Println("Unable to process", tok, "Ignoring.")
+			d.add(element, errors.New("unexpected directive in "+element.Tag))
 		}
-
 	}
 
 	if element.Tag == "item" {
 		repeat := element.SelectAttrValue("repeat", "1-1")
-		repeatmode := RepeatMode(element.SelectAttrValue("repeat-mode", string(RepeatModeNormal)))
+		repeatmode, err := parseRepeatMode(element.SelectAttrValue("repeat-mode", string(RepeatModeNormal)))
+
+		if err != nil {
+			d.add(element, err)
+		}
 
-		minMax := strings.Split(repeat, "-")
+		min, max, err := parseRepeat(repeat)
 
-		var min, max int
-		var err error
+		if err != nil {
+			d.add(element, err)
+			min, max = 1, 1
+		}
+
+		item := NewItem(out, repeatmode, min, max)
 
-		if len(minMax) == 1 {
-			if min, err = strconv.Atoi(minMax[0]); err != nil {
-				return nil, err
+		if weight := element.SelectAttrValue("weight", ""); weight != "" {
+			if w, err := parseWeight(weight); err != nil {
+				d.add(element, err)
+			} else {
+				item.weight = w
 			}
+		}
 
-			max = min
-		} else if len(minMax) == 2 {
-			if minMax[0] == "" {
-				min = 0
-			} else if min, err = strconv.Atoi(minMax[0]); err != nil {
-				return nil, err
+		if prob := element.SelectAttrValue("repeat-prob", ""); prob != "" {
+			if p, err := parseRepeatProb(prob); err != nil {
+				d.add(element, err)
+			} else {
+				item.repeatProb = p
 			}
-			if minMax[1] == "" {
-				return nil, errors.New(`upper bounds of item repeats must be explicitly stated (e.g. please do not use repeat="1-")`)
-			} else if max, err = strconv.Atoi(minMax[1]); err != nil {
-				return nil, err
+		}
+
+		return item
+	}
+
+	return out
+}
+
+// Returns the path of an element from the root of its document, such as "grammar/rule[2]/one-of/item[3]"
+func elementPath(el *etree.Element) string {
+	var parts []string
+
+	for ; el != nil && el.Parent() != nil; el = el.Parent() {
+		part := el.Tag
+		siblings, index := 0, 0
+
+		for _, sibling := range el.Parent().ChildElements() {
+			if sibling.Tag == el.Tag {
+				siblings++
+			}
+
+			if sibling == el {
+				index = siblings
+			}
+		}
+
+		if siblings > 1 {
+			part += "[" + strconv.Itoa(index) + "]"
+		}
+
+		parts = append([]string{part}, parts...)
+	}
+
+	return strings.Join(parts, "/")
+}
+
+// Returns the line and column each element of a document starts at. etree does not keep track of positions, so the
+// source is tokenized again, and its start elements are matched up with the document's elements in order.
+func elementPositions(src string, doc *etree.Document) map[*etree.Element]position {
+	var offsets []int64
+	dec := xml.NewDecoder(strings.NewReader(src))
+
+	for {
+		offset := dec.InputOffset()
+		tok, err := dec.RawToken()
+
+		if err != nil {
+			break
+		}
+
+		if _, ok := tok.(xml.StartElement); ok {
+			offsets = append(offsets, offset)
+		}
+	}
+
+	var elements []*etree.Element
+
+	var visit func(el *etree.Element)
+	visit = func(el *etree.Element) {
+		elements = append(elements, el)
+
+		for _, child := range el.ChildElements() {
+			visit(child)
+		}
+	}
+
+	for _, el := range doc.ChildElements() {
+		visit(el)
+	}
+
+	positions := make(map[*etree.Element]position)
+
+	if len(elements) != len(offsets) {
+		return positions
+	}
+
+	pos := position{line: 1, column: 1}
+	last := 0
+
+	for i, offset := range offsets {
+		for _, r := range src[last:offset] {
+			if r == '\n' {
+				pos.line++
+				pos.column = 1
+			} else {
+				pos.column++
 			}
-		} else {
-			return nil, errors.New("invalid repeat")
 		}
 
-		return NewItem(out, repeatmode, min, max, g.ruleRefs), nil
+		last = int(offset)
+		positions[elements[i]] = pos
+	}
+
+	return positions
+}
+
+// Parses a repeat-mode attribute. An invalid mode is returned as RepeatModeNormal along with the error.
+func parseRepeatMode(mode string) (RepeatMode, error) {
+	switch m := RepeatMode(strings.TrimSpace(mode)); m {
+	case RepeatModeLazy, RepeatModeNormal, RepeatModeGreedy:
+		return m, nil
+	}
+
+	return RepeatModeNormal, errors.New("invalid repeat-mode " + mode)
+}
+
+// The repeats written as words or symbols by some tools, in place of the numbers of the SRGS spec
+var repeatAliases = map[string][2]int{
+	"optional": {0, 1},
+	"?":        {0, 1},
+	"*":        {0, RepeatUnbounded},
+	"+":        {1, RepeatUnbounded},
+}
+
+// Parses the repeat of an item, such as "3", "0-1", or "1-" which has no upper bound (max is RepeatUnbounded)
+func parseRepeat(repeat string) (int, int, error) {
+	repeat = strings.TrimSpace(repeat)
+
+	if alias, ok := repeatAliases[repeat]; ok {
+		return alias[0], alias[1], nil
+	}
+
+	minMax := strings.Split(repeat, "-")
+
+	for i := range minMax {
+		minMax[i] = strings.TrimSpace(minMax[i])
+	}
+
+	var min, max int
+	var err error
+
+	if len(minMax) == 1 {
+		if min, err = strconv.Atoi(minMax[0]); err != nil {
+			return 0, 0, err
+		}
+
+		max = min
+	} else if len(minMax) == 2 {
+		if minMax[0] == "" {
+			min = 0
+		} else if min, err = strconv.Atoi(minMax[0]); err != nil {
+			return 0, 0, err
+		}
+		if minMax[1] == "" {
+			max = RepeatUnbounded
+		} else if max, err = strconv.Atoi(minMax[1]); err != nil {
+			return 0, 0, err
+		}
+	} else {
+		return 0, 0, errors.New("invalid repeat")
+	}
+
+	if min < 0 || max < min && max != RepeatUnbounded {
+		return 0, 0, errors.New("invalid repeat " + repeat)
+	}
+
+	return min, max, nil
+}
+
+// The namespace of the attributes which extend SRGS, such as the bounds of GARBAGE
+const ExtensionNamespace = "https://github.com/robcapo/srgs"
+
+// Decodes a GARBAGE ruleref. Besides scan-match, its words can be bounded by attributes in the ExtensionNamespace:
+// repeat ("2", "1-3" or "1-"), repeat-mode, and stop-words, a space separated list of words it never matches, which
+// are normalized like the grammar's tokens.
+func decodeGarbage(el *etree.Element, normalize func(string) string) (*Garbage, error) {
+	opts := GarbageOptions{ScanMatch: el.SelectAttrValue("scan-match", "") == "true"}
+
+	if repeat, ok := extensionAttr(el, "repeat"); ok {
+		var err error
+		if opts.MinWords, opts.MaxWords, err = parseGarbageRepeat(repeat); err != nil {
+			return nil, err
+		}
+	}
+
+	if mode, ok := extensionAttr(el, "repeat-mode"); ok {
+		var err error
+		if opts.Mode, err = parseRepeatMode(mode); err != nil {
+			return nil, err
+		}
+	}
+
+	if words, ok := extensionAttr(el, "stop-words"); ok {
+		opts.StopWords = strings.Fields(words)
+	}
+
+	return newGarbage(opts, normalize), nil
+}
+
+// Parses the repeat attribute of GARBAGE, which is written like the repeat of an item but must let it match a word. A
+// max of 0 has no bound.
+func parseGarbageRepeat(repeat string) (int, int, error) {
+	min, max, err := parseRepeat(repeat)
+
+	if err != nil {
+		return 0, 0, errors.New("invalid GARBAGE repeat " + repeat)
+	}
+
+	if max == 0 {
+		return 0, 0, errors.New("invalid GARBAGE repeat " + repeat)
+	}
+
+	if max == RepeatUnbounded {
+		max = 0
+	}
+
+	return min, max, nil
+}
+
+// Returns the value of an attribute in the ExtensionNamespace, whatever prefix the document gives the namespace
+func extensionAttr(el *etree.Element, key string) (string, bool) {
+	for _, attr := range el.Attr {
+		if attr.Key == key && attr.Space != "" && attr.Space != "xmlns" &&
+			namespaceOf(el, attr.Space) == ExtensionNamespace {
+			return attr.Value, true
+		}
+	}
+
+	return "", false
+}
+
+// Returns the namespace a prefix is declared as on an element or its parents
+func namespaceOf(el *etree.Element, prefix string) string {
+	for ; el != nil; el = el.Parent() {
+		for _, attr := range el.Attr {
+			if attr.Space == "xmlns" && attr.Key == prefix {
+				return attr.Value
+			}
+		}
+	}
+
+	return ""
+}
+
+// Parses the weight of an alternative, which must be a non-negative number
+func parseWeight(weight string) (float64, error) {
+	w, err := strconv.ParseFloat(strings.TrimSpace(weight), 64)
+
+	if err != nil || w < 0 || math.IsInf(w, 0) || math.IsNaN(w) {
+		return 0, errors.New("invalid weight " + weight)
+	}
+
+	return w, nil
+}
+
+// Parses the repeat probability of an item, which must be between 0 and 1
+func parseRepeatProb(prob string) (float64, error) {
+	p, err := strconv.ParseFloat(strings.TrimSpace(prob), 64)
+
+	if err != nil || p < 0 || p > 1 {
+		return 0, errors.New("invalid repeat probability " + prob)
 	}
 
-	return out, nil
+	return p, nil
 }
 
 func decodeCharData(data string) Expansion {
@@ -343,5 +844,5 @@ func decodeCharData(data string) Expansion {
 		return nil
 	}
 
-	return NewToken(strings.ToLower(data))
+	return NewToken(data)
 }
@@ -1,7 +1,9 @@
 package srgs
 
 import (
+	"errors"
 	"github.com/stretchr/testify/assert"
+	"sync"
 	"testing"
 )
 
@@ -289,3 +291,95 @@ func TestGrammarWithGarbage(t *testing.T) {
 	assert.Nil(err)
 	assert.Equal("10", out)
 }
+
+func TestConcurrentMatch(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGrammar()
+	if !assert.Nil(g.LoadXml(digitsXml)) {
+		return
+	}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 10; j++ {
+				assert.True(g.HasPrefix("one two three"))
+				assert.False(g.HasPrefix("six five four three two two"))
+				assert.True(g.HasMatch("one two three four five"))
+				assert.False(g.HasMatch("one two three four"))
+
+				p := new(SISRProcessor)
+				if !assert.Nil(g.GetMatch("triple three four five", p)) {
+					return
+				}
+
+				out, err := p.GetInstance()
+				assert.Nil(err)
+				assert.Equal("33345", out)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+func TestPublicRules(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGrammar()
+	if !assert.Nil(g.LoadXml(digitsXml)) {
+		return
+	}
+
+	assert.Equal([]string{"combined", "four"}, g.PublicRules())
+
+	scope, ok := g.RuleScope("digit")
+	assert.True(ok)
+	assert.Equal(ScopePrivate, scope)
+
+	_, ok = g.RuleScope("five")
+	assert.False(ok)
+
+	assert.True(g.HasMatchRule("four", "quadruple"))
+	assert.True(g.HasPrefixRule("four", "quad"))
+	assert.False(g.HasMatchRule("four", "one"))
+	assert.True(g.HasMatchRule("combined", "one two three four five"))
+
+	// private and missing rules are not entry points
+	assert.False(g.HasMatchRule("digit", "five"))
+	assert.False(g.HasPrefixRule("digit", "five"))
+	assert.False(g.HasMatchRule("five", "five"))
+	assert.True(errors.Is(g.GetMatchRule("digit", "five", new(SISRProcessor)), PrivateRule))
+	assert.True(errors.Is(g.GetMatchRule("five", "five", new(SISRProcessor)), RuleNotFound))
+}
+
+func TestGetMatchRule(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGrammar()
+	if !assert.Nil(g.LoadABNF(`#ABNF 1.0;
+root $order;
+public $order = i want $digit {out = rules.digit.out;};
+public $digit = one {out = 1;} | two {out = 2;};
+`)) {
+		return
+	}
+
+	assert.Equal([]string{"digit", "order"}, g.PublicRules())
+	assert.True(g.HasMatchRule("digit", "two"))
+	assert.False(g.HasMatch("two"))
+
+	p := new(SISRProcessor)
+	if !assert.Nil(g.GetMatchRule("digit", "two", p)) {
+		return
+	}
+
+	out, err := p.GetInstance()
+	assert.Nil(err)
+	assert.Equal("2", out)
+}
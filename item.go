@@ -1,129 +1,293 @@
 package srgs
 
+import "math"
+
+// RepeatMode decides the order in which the ways an item can repeat are tried. It is the order in which GetMatch
+// chooses between them, GetParses finds them, and GetNBest keeps the parses which are equally likely. Whatever the
+// mode, a repeat beyond the minimum must consume something.
 type RepeatMode string
 
 const (
-	RepeatModeLazy   RepeatMode = "lazy"
+	// The fewest repeats first, and the ways with the same number of repeats in the order of RepeatModeNormal. The
+	// matcher backend searches again for each number of repeats, so a lazy item which needs many repeats is slower to
+	// match than a normal one.
+	RepeatModeLazy RepeatMode = "lazy"
+
+	// The order of the grammar. The repeats are tried depth first, like the rule $r = $NULL | child $r, so that
+	// stopping is tried before each further repeat, and each repeat tries the ways its child matches in order. This is
+	// the same as RepeatModeLazy when the child only matches one way at each position.
 	RepeatModeNormal RepeatMode = "normal"
+
+	// The most repeats first, and the ways with the same number of repeats in the order of RepeatModeNormal
 	RepeatModeGreedy RepeatMode = "greedy"
 )
 
+// The repeatMax of an item which can repeat any number of times, such as repeat="1-"
+const RepeatUnbounded = -1
+
 type Item struct {
-	children   []Expansion
-	repeatMin  int
+	child     Expansion
+	repeatMin int
+
+	// the most repeats, or RepeatUnbounded
 	repeatMax  int
 	repeatMode RepeatMode
 
-	saveString []string
-	str        string
-	mode       MatchMode
+	// the weight of the item when it is an alternative of a one-of
+	weight float64
 
-	nextInd int
-	scanInd int
+	// the probability of each repeat beyond repeatMin, or negative if the item has no repeat probability
+	repeatProb float64
 }
 
-func (it *Item) Copy(refs RuleRefs) Expansion {
-	children := make([]Expansion, len(it.children))
-	for i := 0; i < len(it.children); i++ {
-		children[i] = it.children[i].Copy(refs)
-	}
-
+// Creates an item which repeats its child between repeatMin and repeatMax times. A repeatMax of RepeatUnbounded has no
+// upper bound.
+func NewItem(child Expansion, repeatMode RepeatMode, repeatMin, repeatMax int) *Item {
 	return &Item{
-		children:   children,
-		repeatMin:  it.repeatMin,
-		repeatMax:  it.repeatMax,
-		str:        it.str,
-		mode:       it.mode,
-		nextInd:    it.nextInd,
-		repeatMode: it.repeatMode,
-		saveString: it.saveString,
+		child:      child,
+		repeatMin:  repeatMin,
+		repeatMax:  repeatMax,
+		repeatMode: repeatMode,
+		weight:     1,
+		repeatProb: -1,
 	}
 }
 
-func NewItem(child Expansion, repeatMode RepeatMode, repeatMin, repeatMax int, r RuleRefs) *Item {
-	children := make([]Expansion, repeatMax+1)
-	children[0] = NewToken("")
-	for i := 1; i < len(children); i++ {
-		children[i] = child.Copy(r)
-	}
+// Returns whether an item can repeat again after count repeats
+func (it *Item) canRepeat(count int) bool {
+	return it.repeatMax == RepeatUnbounded || count < it.repeatMax
+}
 
-	return &Item{
-		children:   children,
-		repeatMin:  repeatMin,
-		repeatMax:  repeatMax,
-		repeatMode: repeatMode,
-		saveString: make([]string, repeatMax+1),
+// Returns the number of repeats which behaves the same as count. Once an unbounded item has its minimum repeats, more
+// repeats make no difference to what can follow, so parsers which remember the repeats of an item need only
+// remember up to the minimum.
+func (it *Item) normalizeCount(count int) int {
+	if it.repeatMax == RepeatUnbounded && count > it.repeatMin {
+		return it.repeatMin
 	}
+
+	return count
+}
+
+// Implements Expansion NewMatcher method. The matchers of the repeats are only created as the repeats are reached, so
+// that an item with many or unbounded repeats costs no more than the string it matches.
+func (it *Item) NewMatcher() Matcher {
+	return &itemMatcher{item: it}
 }
 
-func (it *Item) Match(str string, mode MatchMode) {
-	it.str = str
+// The rounds of an itemMatcher which search every path, rather than the paths with a number of repeats
+const (
+	// every path, yielding each one with at least the minimum repeats
+	roundAll = -1
+
+	// every path, yielding none, to find the most repeats of any path
+	roundDeepest = -2
+)
+
+// Searches the ways an item can repeat depth first, where the children of a path are the ways another repeat can match
+// after it. An item in RepeatModeNormal yields the paths in a single round, as it reaches them. Lazy and greedy items
+// search in rounds, one for each number of repeats from the fewest or the most, and yield the paths with exactly that
+// number of repeats.
+type itemMatcher struct {
+	item *Item
+	mode MatchMode
+
+	// the matcher of each repeat, created as the repeats are first reached. children[d] matches repeat d+1 against
+	// rests[d], the string left after the first d repeats of the path, once started[d] is set.
+	children []Matcher
+	rests    []string
+	started  []bool
+
+	// the number of repeats of the current path, or -1 once the round has tried every path, and whether the path has
+	// been entered
+	depth   int
+	entered bool
+
+	// the number of repeats the current round searches for, or roundAll or roundDeepest, whether a path in the round
+	// reached it, and the most repeats of any path reached
+	round   int
+	reached bool
+	deepest int
+
+	// whether a repeat could only match a prefix of the string, which makes the item's error PrefixOnly
+	prefixOnly bool
+
+	// the number of repeats of the path last yielded
+	scanInd int
+}
+
+func (it *itemMatcher) Match(str string, mode MatchMode) {
 	it.mode = mode
-	it.nextInd = 0
-	it.saveString[0] = ""
-	it.children[0].Match(str, mode)
+	it.rests = append(it.rests[:0], str)
+	it.started = append(it.started[:0], false)
+	it.prefixOnly = false
+	it.deepest = 0
+
+	switch it.item.repeatMode {
+	case RepeatModeLazy:
+		it.startRound(it.item.repeatMin)
+	case RepeatModeGreedy:
+		it.startRound(roundDeepest)
+	default:
+		it.startRound(roundAll)
+	}
+}
+
+func (it *itemMatcher) startRound(round int) {
+	it.round = round
+	it.reached = false
+	it.depth = 0
+	it.entered = false
+	it.started[0] = false
 }
 
-func (it *Item) Next() (string, error) {
-	if it.nextInd < 0 {
-		return "", NoMatch
+// Moves on to the next round, and returns false if there are no more
+func (it *itemMatcher) nextRound() bool {
+	min := it.item.repeatMin
+
+	switch {
+	case it.round == roundAll:
+		return false
+	case it.round == roundDeepest:
+		if it.deepest < min {
+			return false
+		}
+
+		it.startRound(it.deepest)
+	case it.item.repeatMode == RepeatModeGreedy:
+		if it.round <= min {
+			return false
+		}
+
+		it.startRound(it.round - 1)
+	default:
+		// no path which fell short of this round's repeats can reach the next round's
+		if !it.reached || !it.item.canRepeat(it.round) {
+			return false
+		}
+
+		it.startRound(it.round + 1)
 	}
 
-	var str string
-	var err error
-	var lastSuccessfulString string
-	var hadSuccess bool
-	// loop all the way up to the child right before the min-repeat
+	return true
+}
+
+func (it *itemMatcher) Next() (string, error) {
 	for {
-		str, err = it.children[it.nextInd].Next()
+		for it.depth >= 0 {
+			d := it.depth
 
-		if err != nil {
-			if hadSuccess && it.repeatMode == RepeatModeGreedy {
-				return lastSuccessfulString, nil
-			}
-			it.scanInd--
-			it.nextInd--
+			if !it.entered {
+				it.entered = true
+
+				if d > it.deepest {
+					it.deepest = d
+				}
 
-			str2, err2 := it.Next()
+				if d == it.round {
+					it.reached = true
+				}
 
-			if err2 == nil {
-				return str2, err2
+				if it.round == roundAll && d >= it.item.repeatMin || d == it.round {
+					it.scanInd = d
+					return it.rests[d], nil
+				}
 			}
 
-			if err == PrefixOnly {
-				return str, err
+			if it.item.canRepeat(d) && (it.round < 0 || d < it.round) {
+				if rest, ok := it.repeat(d); ok {
+					it.push(rest)
+					continue
+				}
 			}
 
-			return str2, err2
+			it.depth--
 		}
-		lastSuccessfulString = str
-		if it.nextInd > 0 {
-			hadSuccess = true
+
+		if !it.nextRound() {
+			break
 		}
-		breaker := false
-		if it.nextInd >= it.repeatMin && it.repeatMode != RepeatModeGreedy {
-			breaker = true
+	}
+
+	if it.prefixOnly {
+		return "", PrefixOnly
+	}
+
+	return "", NoMatch
+}
+
+// Returns the next way another repeat can match after the first d repeats of the current path. A repeat beyond the
+// minimum must consume something.
+func (it *itemMatcher) repeat(d int) (string, bool) {
+	if !it.started[d] {
+		if d == len(it.children) {
+			it.children = append(it.children, it.item.child.NewMatcher())
 		}
 
-		it.scanInd = it.nextInd
-		if it.nextInd+1 < len(it.children) && str != it.saveString[it.nextInd] {
+		it.children[d].Match(it.rests[d], it.mode)
+		it.started[d] = true
+	}
+
+	for {
+		rest, err := it.children[d].Next()
+
+		if err != nil {
+			if err == PrefixOnly {
+				it.prefixOnly = true
+			}
 
-			it.saveString[it.nextInd] = str
-			it.nextInd++
-			it.saveString[it.nextInd] = str
-			it.children[it.nextInd].Match(str, it.mode)
+			return "", false
 		}
 
-		if breaker {
-			break
+		if d < it.item.repeatMin || rest != it.rests[d] {
+			return rest, true
+		}
+	}
+}
+
+// Adds a repeat which leaves rest to the current path
+func (it *itemMatcher) push(rest string) {
+	it.depth++
+	it.entered = false
+
+	if it.depth == len(it.rests) {
+		it.rests = append(it.rests, rest)
+		it.started = append(it.started, false)
+	} else {
+		it.rests[it.depth] = rest
+		it.started[it.depth] = false
+	}
+}
+
+// Implements Matcher Score method
+func (it *itemMatcher) Score() float64 {
+	score := it.item.repeatScore(it.scanInd)
+
+	for i := 0; i < it.scanInd; i++ {
+		score *= it.children[i].Score()
+	}
+
+	return score
+}
+
+// Returns the probability of an item repeating count times. With a repeat probability p, each repeat beyond the
+// minimum has probability p, and stopping before the maximum has probability 1-p.
+func (it *Item) repeatScore(count int) float64 {
+	score := 1.0
+
+	if p := it.repeatProb; p >= 0 {
+		score *= math.Pow(p, float64(count-it.repeatMin))
+
+		if it.canRepeat(count) {
+			score *= 1 - p
 		}
 	}
 
-	return str, err
+	return score
 }
 
-func (it *Item) Scan(processor Processor) {
-	for i := 1; i <= it.scanInd; i++ {
+func (it *itemMatcher) Scan(processor Processor) {
+	for i := 0; i < it.scanInd; i++ {
 		it.children[i].Scan(processor)
 	}
 }
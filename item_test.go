@@ -1,7 +1,9 @@
 package srgs
 
 import (
+	"fmt"
 	"github.com/stretchr/testify/assert"
+	"strings"
 	"testing"
 )
 
@@ -9,7 +11,7 @@ func TestItem_MatchRepeatRange(t *testing.T) {
 	assert := assert.New(t)
 
 	tok := NewToken("rob")
-	item := NewItem(tok, 3, 5, make(RuleRefs))
+	item := NewItem(tok, RepeatModeNormal, 3, 5).NewMatcher()
 
 	item.Match("rob", ModeExact)
 	_, err := item.Next()
@@ -40,3 +42,307 @@ func TestItem_MatchRepeatRange(t *testing.T) {
 	assert.Equal(NoMatch, err)
 
 }
+
+func TestItem_Unbounded(t *testing.T) {
+	assert := assert.New(t)
+
+	item := NewItem(NewToken("rob"), RepeatModeNormal, 1, RepeatUnbounded).NewMatcher()
+
+	item.Match("", ModeExact)
+	_, err := item.Next()
+	assert.NotNil(err)
+
+	item.Match("rob rob rob", ModeExact)
+
+	for _, want := range []string{"rob rob", "rob", ""} {
+		str, err := item.Next()
+		assert.Nil(err)
+		assert.Equal(want, str)
+	}
+
+	// another repeat could follow the last one, so the item stops with PrefixOnly
+	_, err = item.Next()
+	assert.Equal(PrefixOnly, err)
+
+	// the matchers of the repeats are only created as they are needed
+	assert.Len(item.(*itemMatcher).children, 4)
+	assert.Empty(NewItem(NewToken("rob"), RepeatModeNormal, 0, 1000000).NewMatcher().(*itemMatcher).children)
+}
+
+// Every form of repeat is matched the same way by every backend, and written back in the form of the SRGS spec
+func TestItem_RepeatForms(t *testing.T) {
+	tests := []struct {
+		repeat, written string
+		min, max        int
+	}{
+		{"1-", "1-", 1, RepeatUnbounded},
+		{"0-", "0-", 0, RepeatUnbounded},
+		{"2-", "2-", 2, RepeatUnbounded},
+		{"2-3", "2-3", 2, 3},
+		{" 1 - ", "1-", 1, RepeatUnbounded},
+		{"optional", "0-1", 0, 1},
+		{"?", "0-1", 0, 1},
+		{"*", "0-", 0, RepeatUnbounded},
+		{"+", "1-", 1, RepeatUnbounded},
+	}
+
+	for _, test := range tests {
+		xml := `<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" root="main">
+	<rule id="main">start <item repeat="` + test.repeat + `">one</item> end</rule>
+</grammar>`
+
+		for _, backend := range []Backend{BackendMatcher, BackendChart} {
+			assert := assert.New(t)
+
+			g := NewGrammar()
+			g.Backend = backend
+			if !assert.Nil(g.LoadXml(xml), test.repeat) {
+				continue
+			}
+
+			compiled := NewGrammar()
+			compiled.LoadXml(xml)
+			if !assert.Nil(compiled.Compile(), test.repeat) {
+				continue
+			}
+
+			input := "start"
+
+			for count := 0; count <= 6; count++ {
+				want := count >= test.min && (test.max == RepeatUnbounded || count <= test.max)
+
+				assert.Equal(want, g.HasMatch(input+" end"), "%s: %q: %d", backend, test.repeat, count)
+				assert.Equal(want, compiled.HasMatch(input+" end"), "compiled: %q: %d", test.repeat, count)
+				assert.Equal(g.HasPrefix(input+" en"), compiled.HasPrefix(input+" en"), "%q: %d", test.repeat, count)
+
+				input += " one"
+			}
+
+			assert.Contains(g.WriteXml(), `repeat="`+test.written+`"`, test.repeat)
+			assertRoundTrips(assert, g)
+		}
+	}
+
+	for _, repeat := range []string{"3-2", "-1-", "x", "1-x", "sometimes"} {
+		err := NewGrammar().LoadXml(`<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" root="main">
+	<rule id="main">start <item repeat="` + repeat + `">one</item> end</rule>
+</grammar>`)
+
+		assert.NotNil(t, err, repeat)
+	}
+}
+
+// The words of a long string of digits, and a grammar which matches any number of digits
+func longDigits(n int) (string, string) {
+	digits := []string{"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine"}
+	words := make([]string, n)
+
+	for i := range words {
+		words[i] = digits[i%len(digits)]
+	}
+
+	xml := `<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" root="number">
+	<rule id="number">number <item repeat="1-"><ruleref uri="#digit" /></item> <item repeat="0-1">point</item></rule>
+	<rule id="digit"><one-of><item>` + strings.Join(digits, "</item><item>") + `</item></one-of></rule>
+</grammar>`
+
+	return "number " + strings.Join(words, " "), xml
+}
+
+// Long strings are matched by every backend without running out of memory or time
+func TestItem_LongInput(t *testing.T) {
+	input, xml := longDigits(2000)
+
+	for _, backend := range []Backend{BackendMatcher, BackendChart} {
+		assert := assert.New(t)
+
+		g := NewGrammar()
+		g.Backend = backend
+		if !assert.Nil(g.LoadXml(xml)) {
+			return
+		}
+
+		assert.True(g.HasMatch(input), backend)
+		assert.True(g.HasMatch(input+" point"), backend)
+		assert.False(g.HasMatch(input+" ten"), backend)
+		assert.True(g.HasPrefix(input+" poi"), backend)
+		assert.False(g.HasPrefix(input+" ten"), backend)
+
+		p := new(SISRProcessor)
+		if assert.Nil(g.GetMatch(input+" point", p), backend) {
+			assert.Equal(input+" point", p.GetInterpretation())
+		}
+	}
+
+	g := NewGrammar()
+	if assert.Nil(t, g.LoadXml(xml)) && assert.Nil(t, g.Compile()) {
+		assert.True(t, g.HasMatch(input))
+		assert.False(t, g.HasMatch(input+" ten"))
+	}
+}
+
+func benchmarkLongDigits(b *testing.B, backend Backend, compile bool) {
+	input, xml := longDigits(1000)
+
+	g := NewGrammar()
+	g.Backend = backend
+	g.LoadXml(xml)
+
+	if compile {
+		g.Compile()
+	}
+
+	benchmarkAutomaton(b, g, input, ModeExact)
+}
+
+func BenchmarkMatcherLongDigits(b *testing.B)   { benchmarkLongDigits(b, BackendMatcher, false) }
+func BenchmarkChartLongDigits(b *testing.B)     { benchmarkLongDigits(b, BackendChart, false) }
+func BenchmarkAutomatonLongDigits(b *testing.B) { benchmarkLongDigits(b, BackendMatcher, true) }
+
+// The repeats of an item are tried in the order of its repeat-mode, which decides the instance of GetMatch with
+// either backend, and the order of the parses of GetParses and of equally likely parses of GetNBest
+func TestItem_RepeatMode(t *testing.T) {
+	tests := []struct {
+		name string
+
+		// the rule, with %s for the repeat-mode of the item under test
+		rule  string
+		input string
+
+		// the instance of GetMatch for lazy, normal and greedy repeats
+		lazy, normal, greedy string
+	}{
+		{
+			"unambiguous",
+			`<item repeat="0-3" repeat-mode="%s">a <tag>out += "x";</tag></item>
+			<item repeat="0-3">a <tag>out += "y";</tag></item>`,
+			"a a", "yy", "yy", "xx",
+		},
+		{
+			"minimum",
+			`<item repeat="1-" repeat-mode="%s">a <tag>out += "x";</tag></item>
+			<item repeat="0-">a <tag>out += "y";</tag></item>`,
+			"a a a", "xyy", "xyy", "xxx",
+		},
+		{
+			"alternative",
+			`<item repeat="1-" repeat-mode="%s">
+				<one-of><item>a <tag>out += "1";</tag></item><item>a a <tag>out += "2";</tag></item></one-of>
+			</item> b`,
+			"a a a b", "12", "111", "111",
+		},
+		{
+			"alternative longest first",
+			`<item repeat="1-" repeat-mode="%s">
+				<one-of><item>a a <tag>out += "2";</tag></item><item>a <tag>out += "1";</tag></item></one-of>
+			</item> b`,
+			"a a a b", "21", "21", "111",
+		},
+		{
+			"before garbage",
+			`<item repeat="0-" repeat-mode="%s">a <tag>out += "x";</tag></item> <ruleref special="GARBAGE" />`,
+			"a a", "", "", "xx",
+		},
+		{
+			"garbage repeated",
+			`<item repeat="1-" repeat-mode="%s"><ruleref special="GARBAGE" /> a <tag>out += "x";</tag></item>
+			<item repeat="0-1">a b <tag>out += "y";</tag></item>`,
+			"c a a a b", "xy", "xxy", "xxy",
+		},
+	}
+
+	for _, test := range tests {
+		for mode, want := range map[RepeatMode]string{
+			RepeatModeLazy: test.lazy, RepeatModeNormal: test.normal, RepeatModeGreedy: test.greedy,
+		} {
+			xml := `<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" xml:lang="en-US" root="main">
+	<rule id="main"><tag>out = "";</tag>` + fmt.Sprintf(test.rule, mode) + `</rule>
+</grammar>`
+
+			for _, backend := range []Backend{BackendMatcher, BackendChart} {
+				g := NewGrammar()
+				g.Backend = backend
+				if !assert.Nil(t, g.LoadXml(xml), test.name) {
+					continue
+				}
+
+				p := new(SISRProcessor)
+				if assert.Nil(t, g.GetMatch(test.input, p), "%s: %s: %s", test.name, mode, backend) {
+					out, err := p.GetInstance()
+					assert.Nil(t, err, "%s: %s: %s", test.name, mode, backend)
+					assert.Equal(t, want, out, "%s: %s: %s", test.name, mode, backend)
+				}
+			}
+		}
+	}
+}
+
+func TestItem_RepeatModeParses(t *testing.T) {
+	for mode, want := range map[RepeatMode][]string{
+		RepeatModeLazy:   {"+..", ".+.", "..."},
+		RepeatModeNormal: {"+..", ".+.", "..."},
+		RepeatModeGreedy: {"...", "+..", ".+."},
+	} {
+		g := NewGrammar()
+		if !assert.Nil(t, g.LoadXml(`<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" xml:lang="en-US" root="main">
+	<rule id="main">
+		<tag>out = "";</tag>
+		<item repeat="1-" repeat-mode="`+string(mode)+`">
+			a <item repeat="0-1" repeat-mode="greedy">a <tag>out += "+";</tag></item> <tag>out += ".";</tag>
+		</item>
+	</rule>
+</grammar>`), mode) {
+			continue
+		}
+
+		var parses []string
+		it := g.GetParses("a a a", newSISRProcessor)
+
+		for it.Next() {
+			out, _ := it.Processor().GetInstance()
+			parses = append(parses, out)
+		}
+
+		assert.Equal(t, want, parses, mode)
+
+		// every parse has a score of 1, so they keep their order
+		results, err := g.GetNBest("a a a", 0, newSISRProcessor)
+		assert.Nil(t, err, mode)
+
+		parses = nil
+		for _, result := range results {
+			out, _ := result.Processor.GetInstance()
+			parses = append(parses, out)
+		}
+
+		assert.Equal(t, want, parses, mode)
+	}
+
+	// a repeat-mode which is not in the spec is an error
+	assert.EqualError(t, NewGrammar().LoadXml(`<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" xml:lang="en-US" root="main">
+	<rule id="main"><item repeat="0-1" repeat-mode="eager">a</item></rule>
+</grammar>`), "line 3, column 18, rule main, grammar/rule/item: invalid repeat-mode eager")
+}
+
+// A prefix which ends before an item has reached its minimum number of repeats can still be completed
+func TestItem_PrefixBelowMinimum(t *testing.T) {
+	assert := assert.New(t)
+
+	item := NewItem(NewToken("one"), RepeatModeNormal, 3, 4).NewMatcher()
+
+	for _, prefix := range []string{"", "one", "one one", "one one one one"} {
+		item.Match(prefix, ModePrefix)
+		_, err := item.Next()
+		assert.Nil(err, prefix)
+	}
+
+	item.Match("one two", ModePrefix)
+	_, err := item.Next()
+	assert.NotNil(err)
+}
@@ -0,0 +1,271 @@
+package srgs
+
+import (
+	"context"
+	"errors"
+	"github.com/dop251/goja/ast"
+	"github.com/dop251/goja/file"
+	"github.com/dop251/goja/parser"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ScriptLimits limit the tag scripts run for the SISR instance of a match. The zero value has no limits.
+type ScriptLimits struct {
+	// The length in bytes of the longest tag script which may run, or 0 for no limit
+	MaxScriptSize int
+
+	// The most steps the tag scripts of a match may take, or 0 for no limit. A step is a call of a function, including
+	// the function each tag runs as, or an iteration of a loop.
+	MaxSteps int
+}
+
+var (
+	ScriptTooLarge    = errors.New("tag script is larger than the maximum script size")
+	StepLimitExceeded = errors.New("tag scripts took more than the maximum number of steps")
+	UncountableScript = errors.New("unable to count the steps of the tag script")
+)
+
+// A LimitError is returned when the tag scripts of a match are stopped by a limit. It wraps ScriptTooLarge,
+// StepLimitExceeded, UncountableScript, or the error of the context which was done, such as context.DeadlineExceeded.
+type LimitError struct {
+	Err error
+}
+
+// Implements error Error method
+func (e *LimitError) Error() string {
+	return "script limit: " + e.Err.Error()
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+// Returns an error if a script is larger than the limits allow
+func (l ScriptLimits) checkSize(script string) error {
+	if l.MaxScriptSize > 0 && len(script) > l.MaxScriptSize {
+		return &LimitError{Err: ScriptTooLarge}
+	}
+
+	return nil
+}
+
+// Returns an error if a context is done
+func checkContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return &LimitError{Err: err}
+	}
+
+	return nil
+}
+
+// Enforces the limits of the scripts run in a VM, from when it is started until it is finished
+type scriptLimiter struct {
+	ctx    context.Context
+	limits ScriptLimits
+	steps  int
+
+	// stops watching the context
+	stop func()
+}
+
+// Starts counting steps. If interrupt is not nil, it is called from another goroutine if the context is done before
+// finish is called.
+func (l *scriptLimiter) start(ctx context.Context, limits ScriptLimits, interrupt func(err error)) {
+	l.ctx, l.limits, l.steps, l.stop = ctx, limits, 0, nil
+
+	if ctx.Done() == nil || interrupt == nil {
+		return
+	}
+
+	done, finished := make(chan struct{}), make(chan struct{})
+
+	go func() {
+		defer close(finished)
+
+		select {
+		case <-ctx.Done():
+			interrupt(&LimitError{Err: ctx.Err()})
+		case <-done:
+		}
+	}()
+
+	l.stop = func() {
+		close(done)
+		<-finished
+	}
+}
+
+// Stops watching the context. Once it returns, interrupt is not called.
+func (l *scriptLimiter) finish() {
+	if l.stop != nil {
+		l.stop()
+	}
+
+	l.ctx, l.stop = nil, nil
+}
+
+// Counts a step, and returns an error if there have been more than the limits allow or the context is done. Once it
+// returns an error, it returns one for every step after.
+func (l *scriptLimiter) step() error {
+	l.steps++
+	return l.err()
+}
+
+// Returns the error of the limit which stops the scripts, if any
+func (l *scriptLimiter) err() error {
+	if l.limits.MaxSteps > 0 && l.steps > l.limits.MaxSteps {
+		return &LimitError{Err: StepLimitExceeded}
+	}
+
+	if l.ctx != nil {
+		return checkContext(l.ctx)
+	}
+
+	return nil
+}
+
+// The name of the global function which scripts call to count their steps
+const stepFunction = "__sisrStep"
+
+// Returns a script which counts its steps, by calling the step function at the start of every function and loop body.
+// A script which cannot be made to count its steps is never run, since nothing could stop it, so an error is returned
+// for a script which does not parse, or which the step function cannot be inserted into.
+func countSteps(script string) (string, error) {
+	program, err := parser.ParseFile(nil, "", script, 0)
+
+	if err != nil {
+		return "", err
+	}
+
+	base := program.File.Base()
+	var inserts []stepInsert
+
+	insert := func(idx file.Idx, text string) {
+		inserts = append(inserts, stepInsert{int(idx) - base, len(inserts), text})
+	}
+
+	block := func(body ast.Statement) {
+		if b, ok := body.(*ast.BlockStatement); ok {
+			insert(b.LeftBrace+1, stepFunction+"();")
+		} else if body != nil {
+			insert(statementStart(script, base, body), "{"+stepFunction+"();")
+
+			// the end of a statement does not include the semicolon which ends it
+			end := int(body.Idx1()) - base
+
+			if end > len(script) {
+				end = len(script)
+			}
+
+			semicolon := end + len(script[end:]) - len(strings.TrimLeft(script[end:], " \t\r\n"))
+
+			if semicolon < len(script) && script[semicolon] == ';' {
+				end = semicolon + 1
+			}
+
+			insert(file.Idx(end+base), "}")
+		}
+	}
+
+	walkAst(reflect.ValueOf(program), make(map[uintptr]bool), func(node ast.Node) {
+		switch n := node.(type) {
+		case *ast.FunctionLiteral:
+			block(n.Body)
+		case *ast.ArrowFunctionLiteral:
+			if body, ok := n.Body.(*ast.ExpressionBody); ok {
+				insert(body.Expression.Idx0(), "("+stepFunction+"(), ")
+				insert(body.Expression.Idx1(), ")")
+			} else if body, ok := n.Body.(*ast.BlockStatement); ok {
+				block(body)
+			}
+		case *ast.ForStatement:
+			block(n.Body)
+		case *ast.ForInStatement:
+			block(n.Body)
+		case *ast.ForOfStatement:
+			block(n.Body)
+		case *ast.WhileStatement:
+			block(n.Body)
+		case *ast.DoWhileStatement:
+			block(n.Body)
+		}
+	})
+
+	// text inserted at the same offset keeps the order it was found in, which puts outer loops before inner ones
+	sort.Slice(inserts, func(i, j int) bool {
+		a, b := inserts[i], inserts[j]
+		return a.offset < b.offset || a.offset == b.offset && a.order < b.order
+	})
+
+	out := make([]byte, 0, len(script)+len(inserts)*len(stepFunction))
+	last := 0
+
+	for _, in := range inserts {
+		if in.offset < last || in.offset > len(script) {
+			return "", &LimitError{Err: UncountableScript}
+		}
+
+		out = append(append(out, script[last:in.offset]...), in.text...)
+		last = in.offset
+	}
+
+	return string(append(out, script[last:]...)), nil
+}
+
+// Returns where a statement starts. The parser does not record where an if statement starts, so it is found before
+// the condition.
+func statementStart(script string, base int, stmt ast.Statement) file.Idx {
+	if s, ok := stmt.(*ast.IfStatement); ok && s.If == 0 {
+		test := int(s.Test.Idx0()) - base
+
+		if test >= 0 && test <= len(script) {
+			if ind := strings.LastIndex(script[:test], "if"); ind >= 0 {
+				return file.Idx(ind + base)
+			}
+		}
+	}
+
+	return stmt.Idx0()
+}
+
+type stepInsert struct {
+	offset, order int
+	text          string
+}
+
+var astNodeType = reflect.TypeOf((*ast.Node)(nil)).Elem()
+
+// Calls visit with every node of a syntax tree once, before the nodes it contains. goja has no walker of its own, so
+// the nodes are found with reflection.
+func walkAst(v reflect.Value, seen map[uintptr]bool, visit func(node ast.Node)) {
+	switch v.Kind() {
+	case reflect.Interface:
+		if !v.IsNil() {
+			walkAst(v.Elem(), seen, visit)
+		}
+	case reflect.Ptr:
+		if v.IsNil() || v.Elem().Type().PkgPath() != astNodeType.PkgPath() || seen[v.Pointer()] {
+			return
+		}
+
+		seen[v.Pointer()] = true
+
+		if node, ok := v.Interface().(ast.Node); ok {
+			visit(node)
+		}
+
+		walkAst(v.Elem(), seen, visit)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				walkAst(v.Field(i), seen, visit)
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			walkAst(v.Index(i), seen, visit)
+		}
+	}
+}
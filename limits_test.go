@@ -0,0 +1,316 @@
+package srgs
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Returns a grammar whose main rule matches "word" and runs a tag
+func tagGrammar(t *testing.T, tag string) *Grammar {
+	g := NewGrammar()
+	assert.Nil(t, g.LoadXml(`<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" root="main">
+	<rule id="main">word <tag>`+strings.NewReplacer("&", "&amp;", "<", "&lt;").Replace(tag)+`</tag></rule>
+</grammar>`))
+
+	return g
+}
+
+// A script engine which is not one of the engines with VMs, so that the processor runs the script of the whole match
+type plainEngine struct {
+	ScriptEngine
+}
+
+func TestLimits_Deadline(t *testing.T) {
+	g := tagGrammar(t, `while (true) {}`)
+
+	for name, engine := range scriptEngines {
+		p := NewSISRProcessor(engine)
+		if !assert.Nil(t, g.GetMatch("word", p), name) {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		_, err := p.GetInstanceContext(ctx)
+		cancel()
+
+		var limit *LimitError
+		assert.True(t, errors.As(err, &limit), name)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded), name)
+
+		// the script of the whole match is interrupted too
+		ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+		_, err = p.run(ctx, "root.out")
+		cancel()
+
+		assert.True(t, errors.Is(err, context.DeadlineExceeded), name)
+
+		// the VM which was interrupted runs the next match
+		p = NewSISRProcessor(engine)
+		if assert.Nil(t, tagGrammar(t, `out = [1, 2].join("-")`).GetMatch("word", p), name) {
+			out, err := p.GetInstanceContext(context.Background())
+			assert.Nil(t, err, name)
+			assert.Equal(t, "1-2", out, name)
+		}
+	}
+}
+
+// A loop whose body is an if statement without braces counts its steps like any other, and a script which could not
+// count them would not run at all
+func TestLimits_IfLoop(t *testing.T) {
+	g := tagGrammar(t, `while(true) if(1);`)
+
+	for name, engine := range scriptEngines {
+		p := NewSISRProcessor(engine)
+		p.Limits.MaxSteps = 100
+
+		if !assert.Nil(t, g.GetMatch("word", p), name) {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		_, err := p.GetInstanceContext(ctx)
+		cancel()
+
+		assert.True(t, errors.Is(err, StepLimitExceeded), "%s: %v", name, err)
+	}
+}
+
+// The interrupt of an otto VM stops a script between its steps, even one which catches it
+func TestLimits_OttoInterrupt(t *testing.T) {
+	for _, script := range []string{`while (true) {}`, `while (true) { try { while (true) {} } catch (e) {} }`} {
+		v := OttoEngine{}.acquire().(*ottoVM)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		v.start(ctx, ScriptLimits{})
+
+		err := func() (err error) {
+			defer recoverOttoLimit(&err)
+			_, err = v.vm.Run(script)
+			return v.check(err)
+		}()
+
+		v.finish()
+		cancel()
+
+		assert.True(t, errors.Is(err, context.DeadlineExceeded), "%s: %v", script, err)
+
+		// the VM runs the next script once it is finished
+		v.start(context.Background(), ScriptLimits{})
+		value, err := v.vm.Run(`1 + 1`)
+		v.finish()
+
+		assert.Nil(t, err, script)
+		assert.Equal(t, "2", value.String(), script)
+
+		OttoEngine{}.release(v)
+	}
+}
+
+func TestLimits_Context(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// a match evaluated in Go does not run once its context is done
+	p := new(SISRProcessor)
+	if assert.Nil(t, tagGrammar(t, `out = "5"`).GetMatch("word", p)) {
+		_, err := p.GetValueContext(ctx)
+		assert.True(t, errors.Is(err, context.Canceled))
+
+		_, err = p.GetJSONContext(ctx)
+		assert.True(t, errors.Is(err, context.Canceled))
+
+		value, err := p.GetValueContext(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, "5", value)
+	}
+
+	// nor does the script of a script engine which cannot be interrupted
+	p = NewSISRProcessor(plainEngine{OttoEngine{}})
+	if assert.Nil(t, tagGrammar(t, `out = "5"`).GetMatch("word", p)) {
+		_, err := p.GetInstanceContext(ctx)
+		assert.True(t, errors.Is(err, context.Canceled))
+	}
+}
+
+func TestLimits_MaxSteps(t *testing.T) {
+	tests := []struct {
+		tag   string
+		steps int
+		ok    bool
+	}{
+		{`for (var i = 0; i < 10; i++) {} out = i;`, 11, true},
+		{`for (var i = 0; i < 10; i++) {} out = i;`, 10, false},
+		{`var i = 0; while (i < 10) i++; out = i;`, 11, true},
+		{`var i = 0; do { i++ } while (i < 10); out = i;`, 10, false},
+		{`function f(n) { return n ? f(n - 1) : 0; } out = f(5);`, 7, true},
+		{`function f(n) { return n ? f(n - 1) : 0; } out = f(5);`, 6, false},
+		{`out = [1, 2, 3].map(function (x) { return x; }).length;`, 4, true},
+		{`out = [1, 2, 3].map(function (x) { return x; }).length;`, 3, false},
+		{`try { while (true) {} } catch (e) { out = "caught"; }`, 1000, false},
+		{`while (true) { try { for (;;) ; } finally { continue; } }`, 1000, false},
+	}
+
+	for _, test := range tests {
+		g := tagGrammar(t, test.tag)
+
+		for name, engine := range scriptEngines {
+			p := NewSISRProcessor(engine)
+			p.Limits.MaxSteps = test.steps
+
+			if !assert.Nil(t, g.GetMatch("word", p), test.tag) {
+				continue
+			}
+
+			_, err := p.GetInstance()
+			assert.Equal(t, test.ok, err == nil, "%s: %s: %v", name, test.tag, err)
+
+			if !test.ok {
+				assert.True(t, errors.Is(err, StepLimitExceeded), "%s: %s: %v", name, test.tag, err)
+			}
+
+			_, err = p.run(context.Background(), "root.out")
+
+			if !test.ok {
+				assert.True(t, errors.Is(err, StepLimitExceeded), "%s: %s: %v", name, test.tag, err)
+			}
+		}
+	}
+
+	// the steps of every tag of the match count towards the limit
+	g := NewGrammar()
+	if assert.Nil(t, g.LoadXml(`<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" root="main">
+	<rule id="main">
+		<item repeat="1-4"><ruleref uri="#word" /></item> <tag>out = rules.word.length;</tag>
+	</rule>
+	<rule id="word">word <tag>out = [raw].concat([]);</tag></rule>
+</grammar>`)) {
+		for name, engine := range scriptEngines {
+			for words, ok := range map[string]bool{"word word": true, "word word word word": false} {
+				p := NewSISRProcessor(engine)
+				p.Limits.MaxSteps = 3
+
+				if assert.Nil(t, g.GetMatch(words, p), name) {
+					_, err := p.GetInstance()
+					assert.Equal(t, ok, err == nil, "%s: %s", name, words)
+				}
+			}
+		}
+	}
+}
+
+func TestLimits_MaxScriptSize(t *testing.T) {
+	for _, tag := range []string{`out = "a long string";`, `out = ["a", "long", "array"].join(" ");`} {
+		g := tagGrammar(t, tag)
+
+		for name, engine := range scriptEngines {
+			p := NewSISRProcessor(engine)
+			p.Limits.MaxScriptSize = 20
+
+			if !assert.Nil(t, g.GetMatch("word", p), tag) {
+				continue
+			}
+
+			_, err := p.GetInstance()
+			assert.True(t, errors.Is(err, ScriptTooLarge), "%s: %s", name, tag)
+
+			_, err = p.run(context.Background(), "root.out")
+			assert.True(t, errors.Is(err, ScriptTooLarge), "%s: %s", name, tag)
+
+			p.Limits.MaxScriptSize = len(tag)
+			_, err = p.GetInstance()
+			assert.Nil(t, err, "%s: %s", name, tag)
+		}
+	}
+
+	// literals are not scripts, so they are never too large
+	g := NewGrammar()
+	if assert.Nil(t, g.LoadXml(`<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" root="main" tag-format="semantics/1.0-literals">
+	<rule id="main">word <tag>a long literal tag</tag></rule>
+</grammar>`)) {
+		p := new(SISRProcessor)
+		p.Limits.MaxScriptSize = 1
+
+		if assert.Nil(t, g.GetMatch("word", p)) {
+			out, err := p.GetInstance()
+			assert.Nil(t, err)
+			assert.Equal(t, "a long literal tag", out)
+		}
+	}
+}
+
+// Tags cannot make code from strings or change the built-in objects, which would be seen by the next match run in
+// the VM
+func TestLimits_LockedDown(t *testing.T) {
+	tests := map[string]string{
+		`out = eval("1")`:                                                "",
+		`out = Function("return 1")()`:                                   "",
+		`out = (function () {}).constructor("return 1")()`:               "",
+		`out = typeof eval + (function () {} instanceof Function)`:       "functiontrue",
+		`Array.prototype.push = null; out = [].push(1)`:                  "1",
+		`Object.prototype.tampered = 1; out = typeof {}.tampered`:        "undefined",
+		`Math = null; JSON.parse = null; out = Math.max(1, 2)`:           "2",
+		`out = {}; out.toString = function () { return "x" }; out += ""`: "x",
+		`out = {}; out.hasOwnProperty = 2; out = JSON.stringify(out)`:    `{"hasOwnProperty":2}`,
+	}
+
+	for tag, want := range tests {
+		g := tagGrammar(t, tag)
+
+		for name, engine := range scriptEngines {
+			for i := 0; i < 2; i++ {
+				p := NewSISRProcessor(engine)
+				if !assert.Nil(t, g.GetMatch("word", p), tag) {
+					continue
+				}
+
+				out, err := p.GetInstance()
+
+				if want == "" {
+					assert.NotNil(t, err, "%s: %s", name, tag)
+				} else {
+					assert.Nil(t, err, "%s: %s", name, tag)
+					assert.Equal(t, want, out, "%s: %s", name, tag)
+				}
+
+				out, err = p.run(context.Background(), "root.out")
+				assert.Equal(t, want == "", err != nil, "%s: %s", name, tag)
+				assert.Equal(t, want, out, "%s: %s", name, tag)
+			}
+		}
+	}
+}
+
+func TestCountSteps(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := map[string]string{
+		`out = 1`:                            `out = 1`,
+		`function f() { return 1 }`:          `function f() {__sisrStep(); return 1 }`,
+		`while (x) x--;`:                     `while (x) {__sisrStep();x--;}`,
+		`for (;;) for (;;) {}`:               `for (;;) {__sisrStep();for (;;) {__sisrStep();}}`,
+		`do x++; while (x < 2)`:              `do {__sisrStep();x++;} while (x < 2)`,
+		`for (k in o) {}`:                    `for (k in o) {__sisrStep();}`,
+		`var f = x => x + 1`:                 `var f = x => (__sisrStep(), x + 1)`,
+		`var f = x => ({a: x})`:              `var f = x => ((__sisrStep(), {a: x}))`,
+		`var o = {get a() { return 1 }}`:     `var o = {get a() {__sisrStep(); return 1 }}`,
+		`while (x) if (x) x--;`:              `while (x) {__sisrStep();if (x) x--;}`,
+		`do if (x) x--; else x++; while (x)`: `do {__sisrStep();if (x) x--; else x++;} while (x)`,
+	}
+
+	for script, want := range tests {
+		out, err := countSteps(script)
+		assert.Nil(err, script)
+		assert.Equal(want, out, script)
+	}
+
+	// a script which does not parse cannot count its steps, so it is never run
+	_, err := countSteps(`out = (`)
+	assert.NotNil(err)
+}
@@ -0,0 +1,500 @@
+package srgs
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Severity int
+
+const (
+	// A likely mistake, which does not stop the grammar from being used
+	SeverityWarning Severity = iota
+
+	// A problem which stops the grammar from matching as intended
+	SeverityError
+)
+
+// Implements fmt.Stringer String method
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+
+	return "warning"
+}
+
+// A Diagnostic is a likely mistake found in a grammar by Lint
+type Diagnostic struct {
+	Severity Severity
+
+	// The id of the rule the mistake was found in, if any
+	Rule string
+
+	Message string
+}
+
+// Implements fmt.Stringer String method
+func (d Diagnostic) String() string {
+	if d.Rule == "" {
+		return d.Severity.String() + ": " + d.Message
+	}
+
+	return d.Severity.String() + ": rule " + d.Rule + ": " + d.Message
+}
+
+// Checks a grammar for likely mistakes which are not load errors, such as rules which can never be reached or tags
+// which refer to rules that are never matched. Diagnostics are ordered by rule id.
+func Lint(g *Grammar) []Diagnostic {
+	l := &linter{g: g, nullable: findNullable(g)}
+
+	var ids []string
+	for id := range g.rules {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	referenced, reachable := l.findReferenced(), l.findReachable()
+
+	for _, id := range ids {
+		l.rule = id
+		rule := g.rules[id]
+		entry := g.scopes[id] == ScopePublic || g.Root != nil && g.Root.ruleId == id
+
+		if !entry && !referenced[id] {
+			l.add(SeverityWarning, "rule is never used")
+		} else if !reachable[id] {
+			l.add(SeverityWarning, "rule is referenced, but cannot be reached from the root rule or a public rule")
+		}
+
+		if isEmpty(rule) {
+			l.add(SeverityWarning, "rule is empty")
+		}
+
+		refs := make(map[string]bool)
+		var tags []*Tag
+
+		l.walk(rule, refs, &tags)
+		l.checkTags(tags, refs)
+
+		if path := l.leftRecursion(rule); path != nil && g.Backend != BackendChart {
+			l.add(SeverityError, "rule is left recursive (%s), which the matcher backend cannot match; use BackendChart "+
+				"instead", strings.Join(path, " -> "))
+		}
+	}
+
+	return l.diagnostics
+}
+
+type linter struct {
+	g           *Grammar
+	nullable    map[Expansion]bool
+	diagnostics []Diagnostic
+
+	// the id of the rule being checked
+	rule string
+}
+
+func (l *linter) add(severity Severity, format string, args ...interface{}) {
+	l.diagnostics = append(l.diagnostics, Diagnostic{severity, l.rule, fmt.Sprintf(format, args...)})
+}
+
+// Returns whether a rule reference is to a rule of this grammar rather than an imported one
+func (l *linter) isLocal(ref *RuleRef) bool {
+	rule, ok := l.g.rules[ref.ruleId]
+
+	return ref.rule == nil || ok && rule == ref.rule
+}
+
+// Returns the ids of the rules referenced by another rule
+func (l *linter) findReferenced() map[string]bool {
+	referenced := make(map[string]bool)
+
+	for id, rule := range l.g.rules {
+		for _, ref := range ruleRefs(rule) {
+			if ref.ruleId != id && l.isLocal(ref) {
+				referenced[ref.ruleId] = true
+			}
+		}
+	}
+
+	return referenced
+}
+
+// Returns the ids of the rules which can be reached from the root rule or a public rule
+func (l *linter) findReachable() map[string]bool {
+	reachable := make(map[string]bool)
+
+	var visit func(id string)
+	visit = func(id string) {
+		rule, ok := l.g.rules[id]
+
+		if !ok || reachable[id] {
+			return
+		}
+
+		reachable[id] = true
+
+		for _, ref := range ruleRefs(rule) {
+			if l.isLocal(ref) {
+				visit(ref.ruleId)
+			}
+		}
+	}
+
+	if l.g.Root != nil {
+		visit(l.g.Root.ruleId)
+	}
+
+	for id, scope := range l.g.scopes {
+		if scope == ScopePublic {
+			visit(id)
+		}
+	}
+
+	return reachable
+}
+
+// Returns the rule references made directly by an expansion, without following them into the referenced rules
+func ruleRefs(exp Expansion) []*RuleRef {
+	switch e := exp.(type) {
+	case *Sequence:
+		var out []*RuleRef
+		for _, child := range e.exps {
+			out = append(out, ruleRefs(child)...)
+		}
+		return out
+	case *Alternative:
+		var out []*RuleRef
+		for _, child := range e.items {
+			out = append(out, ruleRefs(child)...)
+		}
+		return out
+	case *Item:
+		return ruleRefs(e.child)
+	case *RuleRef:
+		return []*RuleRef{e}
+	}
+
+	return nil
+}
+
+// Returns whether a rule has nothing but tags in it
+func isEmpty(exp Expansion) bool {
+	switch e := exp.(type) {
+	case *Sequence:
+		for _, child := range e.exps {
+			if !isEmpty(child) {
+				return false
+			}
+		}
+
+		return true
+	case *Tag:
+		return true
+	}
+
+	return false
+}
+
+// Checks the expansions of a rule, collecting the ids of the rules it references and the text of its tags
+func (l *linter) walk(exp Expansion, refs map[string]bool, tags *[]*Tag) {
+	switch e := exp.(type) {
+	case *Sequence:
+		for _, child := range e.exps {
+			l.walk(child, refs, tags)
+		}
+	case *Alternative:
+		seen := make(map[string]bool)
+
+		for _, item := range e.items {
+			key := item
+
+			if it, ok := item.(*Item); ok {
+				key = it.child
+			}
+
+			text := expansionText(key)
+
+			if seen[text] {
+				l.add(SeverityWarning, "one-of has more than one %s item", text)
+			}
+
+			seen[text] = true
+			l.walk(item, refs, tags)
+		}
+	case *Item:
+		if e.repeatMax == 0 {
+			l.add(SeverityWarning, "item %s has repeat=\"0-0\", so it never matches anything", expansionText(e.child))
+		}
+
+		l.walk(e.child, refs, tags)
+	case *RuleRef:
+		refs[e.ruleId] = true
+	case *Tag:
+		*tags = append(*tags, e)
+	}
+}
+
+var (
+	rulesProperty = regexp.MustCompile(`\brules\s*\.\s*([A-Za-z_$][\w$]*)|\brules\s*\[\s*['"]([^'"]*)['"]\s*\]`)
+	ruleProperty  = regexp.MustCompile(`\brule\s*\.\s*([A-Za-z_$][\w$]*)`)
+)
+
+// Checks that the tags of a rule only refer to the rules it references. Each mistake is only reported once per rule.
+func (l *linter) checkTags(tags []*Tag, refs map[string]bool) {
+	// the rules which have been reported as unknown, or which need not be referenced
+	known, misspelt := make(map[string]bool), make(map[string]bool)
+
+	for _, t := range tags {
+		if t.format == sisrLiterals {
+			continue
+		}
+
+		tag := t.text
+
+		if t.format == sisrSemantics {
+			// $X refers to rules.X, and rules.latest() to the rule referenced last
+			tag = expandShorthands(tag)
+			known["latest"] = true
+		}
+
+		for _, match := range rulesProperty.FindAllStringSubmatch(tag, -1) {
+			id := match[1] + match[2]
+
+			if !refs[id] && !known[id] {
+				known[id] = true
+				l.add(SeverityWarning, "tag refers to rules.%s, but the rule never references %s", id, id)
+			}
+		}
+
+		for _, match := range ruleProperty.FindAllStringSubmatch(tag, -1) {
+			if id := match[1]; !misspelt[id] {
+				misspelt[id] = true
+				l.add(SeverityWarning, "tag refers to rule.%s, which should probably be rules.%s", id, id)
+			}
+		}
+	}
+}
+
+// Returns a path of rule ids by which a rule references itself before matching any words, or nil if it has none
+func (l *linter) leftRecursion(rule Expansion) []string {
+	visited := make(map[Expansion]bool)
+
+	var visit func(exp Expansion) []string
+	visit = func(exp Expansion) []string {
+		for _, ref := range l.leftRefs(exp) {
+			if ref.rule == rule {
+				return []string{l.rule, ref.ruleId}
+			}
+
+			if ref.rule == nil || visited[ref.rule] {
+				continue
+			}
+
+			visited[ref.rule] = true
+
+			if path := visit(ref.rule); path != nil {
+				return append(path[:1], append([]string{ref.ruleId}, path[1:]...)...)
+			}
+		}
+
+		return nil
+	}
+
+	return visit(rule)
+}
+
+// Returns the rule references an expansion can make before it matches any words
+func (l *linter) leftRefs(exp Expansion) []*RuleRef {
+	switch e := exp.(type) {
+	case *Sequence:
+		var out []*RuleRef
+
+		for _, child := range e.exps {
+			out = append(out, l.leftRefs(child)...)
+
+			if !l.nullable[child] {
+				break
+			}
+		}
+
+		return out
+	case *Alternative:
+		var out []*RuleRef
+		for _, child := range e.items {
+			out = append(out, l.leftRefs(child)...)
+		}
+		return out
+	case *Item:
+		if e.repeatMax == 0 {
+			return nil
+		}
+
+		return l.leftRefs(e.child)
+	case *RuleRef:
+		return []*RuleRef{e}
+	}
+
+	return nil
+}
+
+// Returns the expansions of a grammar which can match without consuming any words
+func findNullable(g *Grammar) map[Expansion]bool {
+	var exps []Expansion
+	seen := make(map[Expansion]bool)
+
+	var visit func(Expansion)
+	visit = func(exp Expansion) {
+		if exp == nil || seen[exp] {
+			return
+		}
+
+		seen[exp] = true
+		exps = append(exps, exp)
+
+		switch e := exp.(type) {
+		case *Sequence:
+			for _, child := range e.exps {
+				visit(child)
+			}
+		case *Alternative:
+			for _, child := range e.items {
+				visit(child)
+			}
+		case *Item:
+			visit(e.child)
+		case *RuleRef:
+			visit(e.rule)
+		}
+	}
+
+	for _, rule := range g.rules {
+		visit(rule)
+	}
+
+	nullable := make(map[Expansion]bool)
+
+	for changed := true; changed; {
+		changed = false
+
+		for _, exp := range exps {
+			if !nullable[exp] && isNullable(exp, nullable) {
+				nullable[exp] = true
+				changed = true
+			}
+		}
+	}
+
+	return nullable
+}
+
+func isNullable(exp Expansion, nullable map[Expansion]bool) bool {
+	switch e := exp.(type) {
+	case *Token:
+		return e.token == ""
+	case *Sequence:
+		for _, child := range e.exps {
+			if !nullable[child] {
+				return false
+			}
+		}
+
+		return true
+	case *Alternative:
+		for _, child := range e.items {
+			if nullable[child] {
+				return true
+			}
+		}
+
+		return false
+	case *Item:
+		return e.repeatMin == 0 || nullable[e.child]
+	case *RuleRef:
+		return e.rule != nil && nullable[e.rule]
+	case *Garbage:
+		return e.minWords == 0
+	case *Void:
+		return false
+	}
+
+	// tags and NULL can match without consuming any words
+	return true
+}
+
+// Returns a short ABNF-like description of an expansion, such as "(new $york | $VOID)", which is the same for two
+// expansions that match the same way
+func expansionText(exp Expansion) string {
+	switch e := exp.(type) {
+	case *Token:
+		return strconv.Quote(e.token)
+	case *Sequence:
+		if len(e.exps) == 1 {
+			return expansionText(e.exps[0])
+		}
+
+		parts := make([]string, len(e.exps))
+		for i, child := range e.exps {
+			parts[i] = expansionText(child)
+		}
+
+		return "(" + strings.Join(parts, " ") + ")"
+	case *Alternative:
+		parts := make([]string, len(e.items))
+		for i, child := range e.items {
+			parts[i] = expansionText(child)
+		}
+
+		return "(" + strings.Join(parts, " | ") + ")"
+	case *Item:
+		if e.repeatMin == 1 && e.repeatMax == 1 {
+			return expansionText(e.child)
+		}
+
+		if e.repeatMax == RepeatUnbounded {
+			return fmt.Sprintf("%s<%d->", expansionText(e.child), e.repeatMin)
+		}
+
+		return fmt.Sprintf("%s<%d-%d>", expansionText(e.child), e.repeatMin, e.repeatMax)
+	case *RuleRef:
+		return "$" + e.ruleId
+	case *Tag:
+		return "{" + e.text + "}"
+	case *Garbage:
+		return garbageText(e)
+	case *Null:
+		return "$NULL"
+	case *Void:
+		return "$VOID"
+	}
+
+	return fmt.Sprintf("%T", exp)
+}
+
+// Returns the text of garbage with its options, such as $GARBAGE<1-3 greedy -and -or>
+func garbageText(g *Garbage) string {
+	var opts []string
+
+	if g.minWords != 0 || g.maxWords != 0 {
+		opts = append(opts, formatGarbageRepeat(g.minWords, g.maxWords))
+	}
+
+	if g.repeatMode != "" && g.repeatMode != RepeatModeNormal {
+		opts = append(opts, string(g.repeatMode))
+	}
+
+	for _, word := range g.sortedStopWords() {
+		opts = append(opts, "-"+word)
+	}
+
+	if len(opts) == 0 {
+		return "$GARBAGE"
+	}
+
+	return "$GARBAGE<" + strings.Join(opts, " ") + ">"
+}
@@ -0,0 +1,128 @@
+package srgs
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	assert := assert.New(t)
+
+	xml := `<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" xml:lang="en-US" root="order">
+	<rule id="order" scope="public">
+		i want <ruleref uri="#list" />
+		<item repeat="0-0">now</item>
+		<tag>out = rules.list.out + rules.lsit.out;</tag>
+	</rule>
+
+	<rule id="list">
+		<one-of>
+			<item><ruleref uri="#list" /> and <ruleref uri="#x" /></item>
+			<item><ruleref uri="#x" /></item>
+		</one-of>
+	</rule>
+
+	<rule id="x">
+		<one-of>
+			<item>apples</item>
+			<item>pears</item>
+			<item weight="2">apples</item>
+		</one-of>
+		<tag>out = rule.x.out;</tag>
+	</rule>
+
+	<rule id="unused">nothing</rule>
+	<rule id="island"><ruleref uri="#island" /> <ruleref uri="#empty" /></rule>
+	<rule id="empty"><tag>out = 1;</tag></rule>
+</grammar>
+`
+
+	g := NewGrammar()
+	if !assert.Nil(g.LoadXml(xml)) {
+		return
+	}
+
+	assert.Equal([]Diagnostic{
+		{SeverityWarning, "empty", "rule is referenced, but cannot be reached from the root rule or a public rule"},
+		{SeverityWarning, "empty", "rule is empty"},
+		{SeverityWarning, "island", "rule is never used"},
+		{SeverityError, "island", "rule is left recursive (island -> island), which the matcher backend cannot match; use BackendChart instead"},
+		{SeverityError, "list", "rule is left recursive (list -> list), which the matcher backend cannot match; use BackendChart instead"},
+		{SeverityWarning, "order", `item "now" has repeat="0-0", so it never matches anything`},
+		{SeverityWarning, "order", "tag refers to rules.lsit, but the rule never references lsit"},
+		{SeverityWarning, "unused", "rule is never used"},
+		{SeverityWarning, "x", `one-of has more than one "apples" item`},
+		{SeverityWarning, "x", "tag refers to rule.x, which should probably be rules.x"},
+	}, Lint(g))
+
+	// the chart backend can match left recursive rules
+	g.Backend = BackendChart
+	for _, diagnostic := range Lint(g) {
+		assert.Equal(SeverityWarning, diagnostic.Severity, diagnostic.String())
+	}
+
+	assert.Equal("warning: rule list: rule is empty", Diagnostic{SeverityWarning, "list", "rule is empty"}.String())
+}
+
+func TestLint_Examples(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGrammar()
+	if !assert.Nil(g.LoadXml(digitsXml)) {
+		return
+	}
+
+	assert.Equal([]Diagnostic{{SeverityWarning, "quintet", "tag refers to rule.digit, which should probably be rules.digit"}}, Lint(g))
+
+	for _, xml := range []string{nameXml, animalXml, cityXml} {
+		g := NewGrammar()
+		if assert.Nil(g.LoadXml(xml)) {
+			assert.Empty(Lint(g))
+		}
+	}
+}
+
+func TestLint_IndirectLeftRecursion(t *testing.T) {
+	assert := assert.New(t)
+
+	abnf := `#ABNF 1.0 UTF-8;
+root $start;
+
+public $start = [please] $a | $right;
+$a = $b x | y;
+$b = $GARBAGE $a z;
+$right = go $right | stop;
+`
+
+	g := NewGrammar()
+	if !assert.Nil(g.LoadABNF(abnf)) {
+		return
+	}
+
+	assert.Equal([]Diagnostic{
+		{SeverityError, "a", "rule is left recursive (a -> b -> a), which the matcher backend cannot match; use BackendChart instead"},
+		{SeverityError, "b", "rule is left recursive (b -> a -> b), which the matcher backend cannot match; use BackendChart instead"},
+	}, Lint(g))
+}
+
+func TestLint_Shorthands(t *testing.T) {
+	assert := assert.New(t)
+
+	abnf := `#ABNF 1.0 UTF-8;
+root $pizza;
+tag-format <semantics/1.0>;
+
+public $pizza = a $size pizza {$.size = $size; $.last = rules.latest(); $.x = $topping;};
+$size = small | large;
+`
+
+	g := NewGrammar()
+	if !assert.Nil(g.LoadABNF(abnf)) {
+		return
+	}
+
+	assert.Equal([]Diagnostic{
+		{SeverityWarning, "pizza", "tag refers to rules.topping, but the rule never references topping"},
+	}, Lint(g))
+}
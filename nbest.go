@@ -0,0 +1,213 @@
+package srgs
+
+import (
+	"sort"
+)
+
+// A ParseIterator iterates over the distinct parses of a string which exactly match a grammar, in the order in which
+// GetMatch would find them. Two paths through the grammar which scan identically are the same parse.
+type ParseIterator struct {
+	ref          *RuleRef
+	paths        parsePaths
+	newProcessor func() Processor
+
+	seen    map[string]bool
+	matched bool
+	done    bool
+	err     error
+
+	processor Processor
+	score     float64
+}
+
+// Returns an iterator over every distinct parse of a string. Each parse is scanned into a new processor from
+// newProcessor, which gives the interpretation and SISR instance of that parse. The parses are found with the
+// grammar's backend, so the parses of a rule which is left recursive can only be found with BackendChart.
+func (g *Grammar) GetParses(str string, newProcessor func() Processor) *ParseIterator {
+	it := &ParseIterator{
+		ref:          g.Root,
+		newProcessor: newProcessor,
+		seen:         make(map[string]bool),
+	}
+
+	if g.Root == nil {
+		it.done, it.err = true, NoRoot
+		return it
+	}
+
+	str = g.Normalize(str)
+
+	if g.Backend == BackendChart {
+		it.paths = newChartParses(g.Root, str)
+	} else {
+		m := g.Root.NewMatcher()
+		m.Match(str, ModeExact)
+		it.paths = &matcherParses{m}
+	}
+
+	return it
+}
+
+// The paths through a grammar which match the whole of a string
+type parsePaths interface {
+	// Returns the next path and its score, or an error once there are no more paths
+	next() (matchPath, float64, error)
+}
+
+// The paths found by the matcher of a grammar's root rule
+type matcherParses struct {
+	m Matcher
+}
+
+func (p *matcherParses) next() (matchPath, float64, error) {
+	for {
+		rest, err := p.m.Next()
+
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if len(rest) == 0 {
+			return p.m, p.m.Score(), nil
+		}
+	}
+}
+
+// The paths found in the chart of a string
+type chartParses struct {
+	paths chartPaths
+	err   error
+}
+
+func newChartParses(ref *RuleRef, str string) *chartParses {
+	c, err := newChart(ref, str, ModeExact)
+
+	if err != nil {
+		return &chartParses{err: err}
+	}
+
+	return &chartParses{paths: c.paths(ref, 0, map[int]bool{len(c.words): true}, nil)}
+}
+
+func (p *chartParses) next() (matchPath, float64, error) {
+	if p.err != nil {
+		return nil, 0, p.err
+	}
+
+	node := p.paths.next()
+
+	if node == nil {
+		return nil, 0, NoMatch
+	}
+
+	return node, node.Score(), nil
+}
+
+// Advances to the next parse, and returns false once there are no more parses
+func (it *ParseIterator) Next() bool {
+	for !it.done {
+		path, score, err := it.paths.next()
+
+		if err != nil {
+			it.done = true
+
+			if !it.matched {
+				it.err = err
+			}
+
+			break
+		}
+
+		it.matched = true
+
+		trace := new(SimpleProcessor)
+		scanMatch(it.ref, path, trace)
+
+		key := trace.output + "\x00" + trace.script
+
+		if it.seen[key] {
+			continue
+		}
+
+		it.seen[key] = true
+
+		it.processor = it.newProcessor()
+		scanMatch(it.ref, path, it.processor)
+		it.score = score
+
+		return true
+	}
+
+	it.processor = nil
+	it.score = 0
+
+	return false
+}
+
+// Returns the processor the current parse was scanned into
+func (it *ParseIterator) Processor() Processor {
+	return it.processor
+}
+
+// Returns the probability of the current parse, based on the weights of the alternatives and the repeat probabilities
+// of the items it passes through. Unlike the scores of GetNBest, it is not normalized over the other parses.
+func (it *ParseIterator) Score() float64 {
+	return it.score
+}
+
+// Returns NoMatch or PrefixOnly if the string has no parses, once Next has returned false
+func (it *ParseIterator) Err() error {
+	return it.err
+}
+
+// Returns whether a string has more than one distinct parse in the grammar
+func (g *Grammar) IsAmbiguous(str string) bool {
+	it := g.GetParses(str, func() Processor { return new(SimpleProcessor) })
+
+	return it.Next() && it.Next()
+}
+
+// A Result is one distinct parse of a string, scanned into its own processor
+type Result struct {
+	// The probability of the parse, normalized so that the scores of every parse of the string sum to 1
+	Score float64
+
+	Processor Processor
+}
+
+// Returns every distinct parse of a string which exactly matches the grammar, from the most to the least likely. The
+// likelihood of a parse is based on the weights of the alternatives and the repeat probabilities of the items it
+// passes through. Each parse is scanned into a new processor from newProcessor. If n is positive, at most n results
+// are returned.
+func (g *Grammar) GetNBest(str string, n int, newProcessor func() Processor) ([]Result, error) {
+	var results []Result
+	total := 0.0
+
+	it := g.GetParses(str, newProcessor)
+
+	for it.Next() {
+		total += it.Score()
+		results = append(results, Result{Score: it.Score(), Processor: it.Processor()})
+	}
+
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	if total > 0 {
+		for i := range results {
+			results[i].Score /= total
+		}
+	}
+
+	// parses which are equally likely stay in the order GetMatch would find them
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
+	if n > 0 && len(results) > n {
+		results = results[:n]
+	}
+
+	return results, nil
+}
@@ -0,0 +1,369 @@
+package srgs
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var cityXml = `<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" xml:lang="en-US" root="trip">
+	<rule id="trip" scope="public">
+		<item repeat="0-1" repeat-prob="0.8">please</item>
+		to <ruleref uri="#city" /> <tag>out = rules.city.out;</tag>
+	</rule>
+
+	<rule id="city">
+		<one-of>
+			<item weight="3">portland <tag>out = "PDX";</tag></item>
+			<item weight="1">portland <tag>out = "PWM";</tag></item>
+			<item weight="4">boston <tag>out = "BOS";</tag></item>
+		</one-of>
+	</rule>
+</grammar>
+`
+
+func newSISRProcessor() Processor {
+	return new(SISRProcessor)
+}
+
+func instances(assert *assert.Assertions, results []Result) []string {
+	var out []string
+
+	for _, result := range results {
+		inst, err := result.Processor.GetInstance()
+		assert.Nil(err)
+		out = append(out, inst)
+	}
+
+	return out
+}
+
+func TestGetNBest_Weights(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGrammar()
+	if !assert.Nil(g.LoadXml(cityXml)) {
+		return
+	}
+
+	// the two portlands have weights 3 and 1, and scores are normalized over the parses of the string
+	results, err := g.GetNBest("please to portland", 0, newSISRProcessor)
+	if !assert.Nil(err) {
+		return
+	}
+
+	assert.Equal([]string{"PDX", "PWM"}, instances(assert, results))
+	assert.InDelta(0.75, results[0].Score, 1e-9)
+	assert.InDelta(0.25, results[1].Score, 1e-9)
+	assert.Equal("please to portland", results[0].Processor.GetInterpretation())
+
+	results, err = g.GetNBest("to portland", 1, newSISRProcessor)
+	if !assert.Nil(err) {
+		return
+	}
+
+	assert.Equal([]string{"PDX"}, instances(assert, results))
+
+	results, err = g.GetNBest("to boston", 0, newSISRProcessor)
+	if !assert.Nil(err) {
+		return
+	}
+
+	assert.Equal([]string{"BOS"}, instances(assert, results))
+	assert.InDelta(1, results[0].Score, 1e-9)
+
+	_, err = g.GetNBest("to seattle", 0, newSISRProcessor)
+	assert.Equal(NoMatch, err)
+}
+
+func TestGetNBest_RepeatProb(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGrammar()
+	if !assert.Nil(g.LoadABNF(`#ABNF 1.0;
+root $greeting;
+public $greeting = (hello {out = "short";} | hello <0-1 /0.9/> hello {out = "long";});
+`)) {
+		return
+	}
+
+	results, err := g.GetNBest("hello hello", 0, newSISRProcessor)
+	if !assert.Nil(err) {
+		return
+	}
+
+	assert.Equal([]string{"long"}, instances(assert, results))
+
+	// a single hello takes the first alternative, or the second without the optional repeat
+	results, err = g.GetNBest("hello", 0, newSISRProcessor)
+	if !assert.Nil(err) {
+		return
+	}
+
+	assert.Equal([]string{"short", "long"}, instances(assert, results))
+	assert.InDelta(1/1.1, results[0].Score, 1e-9)
+	assert.InDelta(0.1/1.1, results[1].Score, 1e-9)
+}
+
+func TestGetNBest_Digits(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGrammar()
+	if !assert.Nil(g.LoadXml(digitsXml)) {
+		return
+	}
+
+	results, err := g.GetNBest("one two three four five", 0, newSISRProcessor)
+	if !assert.Nil(err) {
+		return
+	}
+
+	assert.True(len(results) > 1)
+
+	total := 0.0
+	for i, result := range results {
+		total += result.Score
+
+		if i > 0 {
+			assert.True(results[i-1].Score >= result.Score)
+		}
+
+		assert.Equal("one two three four five", result.Processor.GetInterpretation())
+	}
+
+	assert.InDelta(1, total, 1e-9)
+
+	// the best parse of an unweighted grammar is the one GetMatch finds
+	p := new(SISRProcessor)
+	assert.Nil(g.GetMatch("one two three four five", p))
+	best, _ := results[0].Processor.GetInstance()
+	inst, _ := p.GetInstance()
+	assert.Equal(inst, best)
+
+	limited, err := g.GetNBest("one two three four five", 2, newSISRProcessor)
+	assert.Nil(err)
+	assert.Len(limited, 2)
+}
+
+func TestGetParses(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGrammar()
+	if !assert.Nil(g.LoadXml(`<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" xml:lang="en-US" root="order">
+	<rule id="order" scope="public">
+		<one-of>
+			<item>
+				<ruleref uri="#size" /> <ruleref uri="#drink" />
+				<tag>out = rules.size.out + " " + rules.drink.out;</tag>
+			</item>
+			<item>
+				<ruleref uri="#drink" />
+				<tag>out = "medium " + rules.drink.out;</tag>
+			</item>
+		</one-of>
+	</rule>
+
+	<rule id="size">
+		<item repeat="0-1">large <tag>out = "large";</tag></item>
+		<tag>out = out ? out : "medium";</tag>
+	</rule>
+
+	<rule id="drink">
+		<one-of>
+			<item>large coffee <tag>out = "coffee";</tag></item>
+			<item>coffee <tag>out = "coffee";</tag></item>
+		</one-of>
+	</rule>
+</grammar>
+`)) {
+		return
+	}
+
+	it := g.GetParses("large coffee", newSISRProcessor)
+
+	var interpretations, outs []string
+	for it.Next() {
+		interpretations = append(interpretations, it.Processor().GetInterpretation())
+
+		out, err := it.Processor().GetInstance()
+		assert.Nil(err)
+		outs = append(outs, out)
+
+		assert.InDelta(0.25, it.Score(), 1e-9)
+	}
+
+	assert.Nil(it.Err())
+	assert.Nil(it.Processor())
+	assert.False(it.Next())
+
+	// the first parse is the one GetMatch finds, and the rest are every other derivation
+	assert.Equal([]string{"large coffee", "large coffee", "large coffee"}, interpretations)
+	assert.Equal([]string{"medium coffee", "large coffee", "medium coffee"}, outs)
+
+	p := new(SISRProcessor)
+	assert.Nil(g.GetMatch("large coffee", p))
+	out, _ := p.GetInstance()
+	assert.Equal(outs[0], out)
+
+	assert.True(g.IsAmbiguous("large coffee"))
+
+	it = g.GetParses("large tea", newSISRProcessor)
+	assert.False(it.Next())
+	assert.Equal(NoMatch, it.Err())
+}
+
+func TestIsAmbiguous(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGrammar()
+	if !assert.Nil(g.LoadXml(digitsXml)) {
+		return
+	}
+
+	assert.True(g.IsAmbiguous("one two three four five"))
+	assert.False(g.IsAmbiguous("quad five"))
+	assert.False(g.IsAmbiguous("six"))
+}
+
+// Asserts that the chart backend finds the same parses as the matcher backend, in the same order and with the same
+// scores
+func assertSameParses(assert *assert.Assertions, load func(g *Grammar) error, inputs []string) {
+	g, chart := NewGrammar(), NewGrammar()
+	chart.Backend = BackendChart
+
+	if !assert.Nil(load(g)) || !assert.Nil(load(chart)) {
+		return
+	}
+
+	for _, input := range inputs {
+		want, err := g.GetNBest(input, 0, newSISRProcessor)
+		got, chartErr := chart.GetNBest(input, 0, newSISRProcessor)
+
+		assert.Equal(err, chartErr, input)
+		assert.Equal(instances(assert, want), instances(assert, got), input)
+
+		if assert.Equal(len(want), len(got), input) {
+			for i := range want {
+				assert.InDelta(want[i].Score, got[i].Score, 1e-9, input)
+				assert.Equal(want[i].Processor.GetInterpretation(), got[i].Processor.GetInterpretation(), input)
+			}
+		}
+
+		assert.Equal(g.IsAmbiguous(input), chart.IsAmbiguous(input), input)
+	}
+}
+
+func TestGetParses_Chart(t *testing.T) {
+	assert := assert.New(t)
+
+	xml := func(doc string) func(g *Grammar) error {
+		return func(g *Grammar) error { return g.LoadXml(doc) }
+	}
+
+	assertSameParses(assert, xml(cityXml), []string{"please to portland", "to boston", "to seattle", "please"})
+	assertSameParses(assert, xml(digitsXml), []string{"one two three four five", "quad five", "six", "one two"})
+	assertSameParses(assert, xml(garbageXml), []string{"i want a large pizza please", "i want pizza", "large"})
+	assertSameParses(assert, xml(orderXml), []string{"latte to go", "two lattes and a mocha", "latte and"})
+
+	assertSameParses(assert, func(g *Grammar) error {
+		return g.LoadABNF(`#ABNF 1.0;
+root $main;
+public $main = $greeting $GARBAGE [$greeting <0-2 /0.3/>];
+$greeting = (hello {out = "short";} | hello <0-1 /0.9/> hello {out = "long";} | $NULL);
+`)
+	}, []string{"hello", "hello hello", "hello hello hello hello", "x hello"})
+
+	for _, mode := range []RepeatMode{RepeatModeLazy, RepeatModeNormal, RepeatModeGreedy} {
+		assertSameParses(assert, xml(`<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" xml:lang="en-US" root="main">
+	<rule id="main">
+		<tag>out = "";</tag>
+		<item repeat="0-" repeat-mode="`+string(mode)+`">
+			<one-of><item>a <tag>out += "1";</tag></item><item>a a <tag>out += "2";</tag></item></one-of>
+		</item>
+		<item repeat="0-2">a <tag>out += "y";</tag></item>
+	</rule>
+</grammar>`), []string{"", "a", "a a a", "a a a a"})
+	}
+}
+
+// The chart backend finds the parses of rules which are left recursive, which the matchers cannot
+func TestGetParses_LeftRecursion(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGrammar()
+	g.Backend = BackendChart
+	if !assert.Nil(g.LoadABNF(`#ABNF 1.0;
+root $list;
+public $list = $list and $x {out = rules.list.out + "," + rules.x.out;} | $x {out = rules.x.out;};
+$x = a {out = "A";} | b {out = "B";} | a and b {out = "AB";};
+`)) {
+		return
+	}
+
+	parses := func(str string) []string {
+		var outs []string
+
+		for it := g.GetParses(str, newSISRProcessor); it.Next(); {
+			out, _ := it.Processor().GetInstance()
+			outs = append(outs, out)
+		}
+
+		return outs
+	}
+
+	// the parses are found in the order of the alternatives, and GetNBest orders them by their score
+	assert.Equal([]string{"A,B", "AB"}, parses("a and b"))
+	assert.Equal([]string{"A,B,A,B", "A,B,AB", "AB,A,B", "AB,AB"}, parses("a and b and a and b"))
+
+	results, err := g.GetNBest("a and b and a and b", 0, newSISRProcessor)
+	if assert.Nil(err) && assert.Len(results, 4) {
+		assert.Equal([]string{"AB,AB", "A,B,AB", "AB,A,B", "A,B,A,B"}, instances(assert, results))
+		assert.InDelta(1.0/36/(1.0/36+2.0/216+1.0/1296), results[0].Score, 1e-9)
+	}
+
+	// the first parse is the one GetMatch finds
+	p := new(SISRProcessor)
+	if assert.Nil(g.GetMatch("a and b and a and b", p)) {
+		out, _ := p.GetInstance()
+		assert.Equal("A,B,A,B", out)
+	}
+
+	assert.True(g.IsAmbiguous("a and b"))
+	assert.False(g.IsAmbiguous("b and a"))
+
+	_, err = g.GetNBest("a and", 0, newSISRProcessor)
+	assert.Equal(NoMatch, err)
+
+	// a rule which can derive itself without matching anything has a parse for each way of not going around the cycle
+	g = NewGrammar()
+	g.Backend = BackendChart
+	if assert.Nil(g.LoadABNF(`#ABNF 1.0;
+root $a;
+public $a = $a | $a $NULL | x {out = "X";};
+`)) {
+		results, err = g.GetNBest("x", 0, newSISRProcessor)
+		assert.Nil(err)
+		assert.Equal([]string{"X"}, instances(assert, results))
+	}
+}
+
+// A grammar with nothing loaded has no parses
+func TestGetParses_NoRoot(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, backend := range []Backend{BackendMatcher, BackendChart} {
+		g := NewGrammar()
+		g.Backend = backend
+
+		it := g.GetParses("anything", newSISRProcessor)
+		assert.False(it.Next(), backend)
+		assert.Equal(NoRoot, it.Err(), backend)
+
+		_, err := g.GetNBest("anything", 0, newSISRProcessor)
+		assert.Equal(NoRoot, err, backend)
+
+		assert.False(g.IsAmbiguous("anything"), backend)
+	}
+}
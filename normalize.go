@@ -0,0 +1,175 @@
+package srgs
+
+import (
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+	"golang.org/x/text/unicode/norm"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A NormalForm is the Unicode normalization form which a Normalizer puts text into
+type NormalForm int
+
+const (
+	// Canonical composition, so that an accented letter typed as one character matches the same letter typed with a
+	// combining accent
+	NormalFormNFC NormalForm = iota
+
+	// Compatibility composition, which also replaces characters such as ligatures, full width letters and superscript
+	// digits with their plain equivalents
+	NormalFormNFKC
+)
+
+// A Normalizer puts the tokens of a grammar and the strings matched against it into the same form, so that text which
+// reads the same matches however it was typed. Whatever the options, whitespace of any kind, such as tabs, newlines
+// and non-breaking spaces, is collapsed into single spaces between words. The zero value composes characters with NFC
+// and folds case by the xml:lang of the grammar.
+type Normalizer struct {
+	// The Unicode normalization form. Defaults to NormalFormNFC.
+	Form NormalForm
+
+	// Whether case is kept, rather than folded to lower case
+	CaseSensitive bool
+
+	// The language whose rules fold case, such as "tr" for the dotted and dotless i of Turkish. If empty, the language
+	// of the grammar is used, which is its xml:lang, or the language declared by an ABNF grammar.
+	Lang string
+
+	// Whether punctuation is removed, so that "rock'n'roll" matches "rocknroll", and "hello, world" matches
+	// "hello world"
+	StripPunctuation bool
+}
+
+// Returns a string in normal form, with its case folded by the rules of lang unless the normalizer has a language of
+// its own
+func (n Normalizer) normalize(str, lang string) string {
+	if n.Lang != "" {
+		lang = n.Lang
+	}
+
+	// ASCII is in every normal form already, and only Turkic languages fold its case by rules of their own, so most
+	// strings can skip the tables
+	if isASCII(str) && (n.CaseSensitive || !dotlessI(lang)) {
+		if n.isNormalASCII(str) {
+			return str
+		}
+
+		return n.collapse(str, !n.CaseSensitive)
+	}
+
+	if !n.CaseSensitive {
+		// a language which does not parse folds case like any other text
+		tag, _ := language.Parse(lang)
+		str = cases.Lower(tag).String(str)
+	}
+
+	// case is folded first, since lowering a decomposed letter can leave it to be composed
+	if n.Form == NormalFormNFKC {
+		str = norm.NFKC.String(str)
+	} else {
+		str = norm.NFC.String(str)
+	}
+
+	return n.collapse(str, false)
+}
+
+// Collapses whitespace into single spaces between words, strips punctuation if the normalizer does, and lowers the
+// case of ASCII letters if lower is true
+func (n Normalizer) collapse(str string, lower bool) string {
+	var out strings.Builder
+	out.Grow(len(str))
+
+	space := false
+
+	for _, r := range str {
+		if unicode.IsSpace(r) {
+			space = out.Len() > 0
+		} else if !n.StripPunctuation || !unicode.IsPunct(r) {
+			if space {
+				out.WriteByte(' ')
+				space = false
+			}
+
+			if lower && 'A' <= r && r <= 'Z' {
+				r += 'a' - 'A'
+			}
+
+			out.WriteRune(r)
+		}
+	}
+
+	return out.String()
+}
+
+// Returns whether an ASCII string is already in normal form, which is the case for most strings matched
+func (n Normalizer) isNormalASCII(str string) bool {
+	for i := 0; i < len(str); i++ {
+		c := str[i]
+
+		switch {
+		case c == ' ':
+			if i == 0 || i == len(str)-1 || str[i-1] == ' ' {
+				return false
+			}
+		case c < '!' || c > '~':
+			return false
+		case !n.CaseSensitive && 'A' <= c && c <= 'Z':
+			return false
+		case n.StripPunctuation && unicode.IsPunct(rune(c)):
+			return false
+		}
+	}
+
+	return true
+}
+
+func isASCII(str string) bool {
+	for i := 0; i < len(str); i++ {
+		if str[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns whether a language tag is of Turkish or Azerbaijani, which lower I to the dotless ı
+func dotlessI(lang string) bool {
+	if len(lang) < 2 || len(lang) > 2 && lang[2] != '-' && lang[2] != '_' {
+		return false
+	}
+
+	return strings.EqualFold(lang[:2], "tr") || strings.EqualFold(lang[:2], "az")
+}
+
+// Returns a string in the form which the grammar's tokens are matched in, as set by its Normalizer. Strings matched
+// against the grammar are normalized the same way, so only strings compared with the output of the grammar, such as
+// the text of a Sentence, need to be normalized by hand.
+func (g *Grammar) Normalize(str string) string {
+	return g.Normalizer.normalize(str, g.normalizeLang())
+}
+
+// Returns the language whose rules fold the case of the grammar's tokens. The strings matched against a grammar are
+// normalized by the grammar which is matching them, so a grammar imported by another folds case like the grammar
+// importing it.
+func (g *Grammar) normalizeLang() string {
+	if g.imported() {
+		return g.imports.top.normalizeLang()
+	}
+
+	return g.lang
+}
+
+// Returns a string to be matched in normal form. A prefix keeps the space at its end, if it has one, since it means
+// the last word is complete.
+func (g *Grammar) normalizeInput(str string, mode MatchMode) string {
+	out := g.Normalize(str)
+
+	if mode == ModePrefix && out != "" && strings.TrimRightFunc(str, unicode.IsSpace) != str {
+		out += " "
+	}
+
+	return out
+}
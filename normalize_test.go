@@ -0,0 +1,185 @@
+package srgs
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNormalizer(t *testing.T) {
+	tests := []struct {
+		normalizer Normalizer
+		lang       string
+		str, want  string
+	}{
+		{Normalizer{}, "", "new york", "new york"},
+		{Normalizer{}, "", "  New\tYork  City \r\n", "new york city"},
+		{Normalizer{}, "en-US", "Café CRÈME", "café crème"},
+		{Normalizer{}, "", "ﬁne ①", "ﬁne ①"},
+		{Normalizer{Form: NormalFormNFKC}, "", "ﬁne ① Ａ", "fine 1 a"},
+		{Normalizer{}, "", "İstanbul", "i̇stanbul"},
+		{Normalizer{}, "tr-TR", "İSTANBUL IRMAK", "istanbul ırmak"},
+		{Normalizer{}, "az", "IRMAK", "ırmak"},
+		{Normalizer{}, "trk", "IRMAK", "irmak"},
+		{Normalizer{Lang: "tr"}, "en-US", "IRMAK", "ırmak"},
+		{Normalizer{}, "not a language", "Word", "word"},
+		{Normalizer{CaseSensitive: true}, "tr", "Paris  İzmir", "Paris İzmir"},
+		{Normalizer{}, "", "rock'n'roll, please!", "rock'n'roll, please!"},
+		{Normalizer{StripPunctuation: true}, "", "Rock'n'roll, please!", "rocknroll please"},
+		{Normalizer{StripPunctuation: true}, "", "a - b ¿qué? «c»", "a b qué c"},
+		{Normalizer{StripPunctuation: true}, "", "$5 + 1", "$5 + 1"},
+		{Normalizer{StripPunctuation: true}, "", " ... ", ""},
+	}
+
+	for _, test := range tests {
+		got := test.normalizer.normalize(test.str, test.lang)
+		assert.Equal(t, test.want, got, "%+v %q", test.normalizer, test.str)
+
+		// normalizing twice changes nothing
+		assert.Equal(t, got, test.normalizer.normalize(got, test.lang), "%+v %q", test.normalizer, test.str)
+	}
+}
+
+var normalizeXml = `<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" xml:lang="tr-TR" root="main">
+	<rule id="main" scope="public">
+		<one-of>
+			<item>İSTANBUL	<tag>out = "ist";</tag></item>
+			<item>Irmak <tag>out = "irmak";</tag></item>
+			<item>cafe` + "́" + ` au
+				lait <tag>out = "cafe";</tag></item>
+			<item>newark <tag>out = "newark";</tag></item>
+		</one-of>
+	</rule>
+</grammar>
+`
+
+// Tokens and inputs are normalized the same way by every backend
+func TestNormalizer_Grammar(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, backend := range []Backend{BackendMatcher, BackendChart} {
+		for _, compile := range []bool{false, true} {
+			g := NewGrammar()
+			g.Backend = backend
+			if !assert.Nil(g.LoadXml(normalizeXml)) {
+				return
+			}
+
+			if compile {
+				assert.Nil(g.Compile())
+			}
+
+			for input, want := range map[string]string{
+				"istanbul": "ist", " İstanbul\t": "ist", "IRMAK": "irmak", "ırmak": "irmak", "CAFÉ AU LAİT": "cafe",
+				"café au lait": "cafe", "café  au lait": "cafe", "newark": "newark",
+			} {
+				assert.True(g.HasMatch(input), "%d %v: %q", backend, compile, input)
+
+				p := new(SISRProcessor)
+				if assert.Nil(g.GetMatch(input, p), "%d %v: %q", backend, compile, input) {
+					out, err := p.GetInstance()
+					assert.Nil(err)
+					assert.Equal(want, out, "%d %v: %q", backend, compile, input)
+				}
+			}
+
+			// in Turkish, the capital of ı is I, and the capital of i is İ
+			assert.False(g.HasMatch("café au LAIT"), "%d %v", backend, compile)
+			assert.False(g.HasMatch("İrmak"), "%d %v", backend, compile)
+
+			// a space at the end of a prefix still ends its last word, however it was typed
+			assert.True(g.HasPrefix("new"), "%d %v", backend, compile)
+			assert.False(g.HasPrefix("new "), "%d %v", backend, compile)
+			assert.True(g.HasPrefix("CAFÉ\t"), "%d %v", backend, compile)
+		}
+	}
+
+	g := NewGrammar()
+	if !assert.Nil(g.LoadXml(normalizeXml)) {
+		return
+	}
+
+	assert.Equal("café au lait", g.Normalize("CAFÉ au  LAİT"))
+	assert.Equal([]string{"au"}, words(g.Complete("Café ", 0)))
+	assert.True(g.Complete("Café au\tLait", 0).Complete)
+
+	results, err := g.GetNBest("İSTANBUL", 0, newSISRProcessor)
+	assert.Nil(err)
+	assert.Len(results, 1)
+
+	p := new(SimpleProcessor)
+	if assert.Nil(g.GetMatch("Café au lait", p)) {
+		assert.Equal("café au lait", p.GetInterpretation())
+	}
+}
+
+func TestNormalizer_Options(t *testing.T) {
+	assert := assert.New(t)
+
+	xml := `<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" xmlns:srgs="https://github.com/robcapo/srgs" version="1.0" xml:lang="en-US" root="main">
+	<rule id="main" scope="public">
+		Rock'n'roll, Paris! <ruleref special="GARBAGE" srgs:stop-words="Stop" /> Stop <item repeat="0-1">ﬁne</item>
+	</rule>
+</grammar>
+`
+
+	g := NewGrammar()
+	g.Normalizer = Normalizer{CaseSensitive: true, StripPunctuation: true, Form: NormalFormNFKC}
+	if !assert.Nil(g.LoadXml(xml)) {
+		return
+	}
+
+	assert.True(g.HasMatch("Rocknroll Paris Stop"))
+	assert.True(g.HasMatch("Rock'n'roll Paris… anything at all Stop fine"))
+	assert.False(g.HasMatch("rocknroll paris Stop"))
+	assert.False(g.HasMatch("rocknroll Paris Stop Stop"))
+	assert.True(g.HasMatch("Rocknroll Paris stop Stop ﬁne"))
+
+	// the default keeps punctuation and folds case
+	g = NewGrammar()
+	if !assert.Nil(g.LoadXml(xml)) {
+		return
+	}
+
+	assert.True(g.HasMatch("ROCK'N'ROLL, PARIS! STOP"))
+	assert.False(g.HasMatch("rocknroll paris stop"))
+	assert.False(g.HasMatch("rock'n'roll, paris! stop stop fine"))
+}
+
+// ABNF, built and imported grammars normalize their tokens like XML grammars
+func TestNormalizer_Sources(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGrammar()
+	if assert.Nil(g.LoadABNF("#ABNF 1.0 UTF-8;\nlanguage tr;\nroot $main;\n$main = IRMAK \"Yeni   Şehir\";")) {
+		assert.True(g.HasMatch("ırmak yeni şehir"))
+		assert.False(g.HasMatch("irmak yeni şehir"))
+	}
+
+	b := NewBuilder()
+	b.Normalizer = Normalizer{CaseSensitive: true}
+	g, err := b.Rule("main", ScopePublic, b.Token("New  York"), b.GarbageWith(GarbageOptions{StopWords: []string{"Now"}}),
+		b.Token("Now")).Build()
+
+	if assert.Nil(err) {
+		assert.True(g.HasMatch("New York Now"))
+		assert.True(g.HasMatch("New York now Now"))
+		assert.False(g.HasMatch("new york Now"))
+	}
+
+	// an imported grammar folds case like the grammar importing it, which normalizes the inputs
+	g = NewGrammar()
+	g.Resolver = MapResolver{"city.grxml": `<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" xml:lang="en-US" root="city">
+	<rule id="city" scope="public">IZMIR</rule>
+</grammar>`}
+
+	if assert.Nil(g.LoadXml(`<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" xml:lang="tr" root="main">
+	<rule id="main" scope="public"><ruleref uri="city.grxml" /></rule>
+</grammar>`)) {
+		assert.True(g.HasMatch("IZMIR"))
+		assert.True(g.HasMatch("ızmır"))
+	}
+}
@@ -1,11 +1,16 @@
 package srgs
 
 import (
-	"fmt"
-	"github.com/robertkrimen/otto"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"strings"
 )
 
+// Returned when the instance of a processor is requested before a match has been scanned into it
+var NoMatchFound = errors.New("no match has been scanned into the processor")
+
 type Processor interface {
 	AppendString(str string)
 	AppendTag(body string)
@@ -26,29 +31,171 @@ func (s *SimpleProcessor) SetRoot(root string)          { s.root = root }
 func (s *SimpleProcessor) GetInterpretation() string    { return s.output }
 func (s *SimpleProcessor) GetInstance() (string, error) { return s.script, nil }
 
+// An SISRProcessor gives the SISR instance of a match. Literal tags and tag scripts which only assign simple
+// expressions to the rule variable, such as out = "5" or out = rules.digit.out + rules.quartet.out, are evaluated in
+// Go. Any other tag script runs in a VM of the script engine, which is pooled and reused by every match, with the
+// scope of each rule kept in Go.
+//
+// The tag scripts stop with a LimitError when they pass the processor's Limits, or when the context given to
+// GetInstanceContext, GetValueContext or GetJSONContext is done. Script engines other than OttoEngine and GojaEngine
+// cannot be interrupted, so the context is only checked before their script runs.
 type SISRProcessor struct {
 	SimpleProcessor
+
+	// The limits on the tag scripts run for the instance
+	Limits ScriptLimits
+
+	// runs the tag scripts, or OttoEngine if nil
+	engine ScriptEngine
+
+	// the steps of the matches scanned into the processor
+	events []sisrEvent
+}
+
+// Creates an SISR processor which runs the tag scripts of a match with a script engine, such as GojaEngine
+func NewSISRProcessor(engine ScriptEngine) *SISRProcessor {
+	return &SISRProcessor{engine: engine}
 }
 
 func (s *SISRProcessor) AppendString(str string) {
 	s.SimpleProcessor.AppendString(str)
-	s.AppendTag(fmt.Sprintf("scopes[scopes.length-1]['raw'] = scopes[scopes.length-1]['raw'] ? scopes[scopes.length-1]['raw'] + ' %s' : '%s';", str, str))
+	s.trace(sisrEvent{kind: eventString, text: str})
+}
+
+func (s *SISRProcessor) AppendTag(body string) {
+	s.trace(sisrEvent{kind: eventScript, text: body})
 }
 
+func (s *SISRProcessor) trace(e sisrEvent) {
+	s.events = append(s.events, e)
+}
+
+// Returns the SISR instance of the match as a string, in the same form as JavaScript's String(out)
 func (s *SISRProcessor) GetInstance() (string, error) {
-	js := s.script
+	return s.GetInstanceContext(context.Background())
+}
+
+// Returns the SISR instance of the match as GetInstance does, stopping the tag scripts when the context is done
+func (s *SISRProcessor) GetInstanceContext(ctx context.Context) (string, error) {
+	e := s.evaluator(ctx)
+	defer e.close()
 
-	vm := otto.New()
-	_, err := vm.Run("var root;\n" + js)
+	out, err := e.evaluate(s.events)
+
+	if err == needsScript {
+		return s.run(ctx, "root.out")
+	}
 
 	if err != nil {
 		return "", err
 	}
 
-	output, err := vm.Run("root ? root.out : 'No Match Found'")
+	return e.toString(out)
+}
+
+// Returns the SISR instance of the match as a Go value, which is one of map[string]interface{}, []interface{},
+// float64, string, bool or nil. An instance which is undefined is nil.
+func (s *SISRProcessor) GetValue() (interface{}, error) {
+	return s.GetValueContext(context.Background())
+}
+
+// Returns the SISR instance of the match as GetValue does, stopping the tag scripts when the context is done
+func (s *SISRProcessor) GetValueContext(ctx context.Context) (interface{}, error) {
+	e := s.evaluator(ctx)
+	defer e.close()
+
+	out, err := e.evaluate(s.events)
+
+	if err == nil {
+		out, err = e.export(out)
+	}
+
+	if err != needsScript {
+		return out, err
+	}
+
+	data, err := s.run(ctx, "JSON.stringify(root.out === undefined ? null : root.out)")
+
 	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	err = json.Unmarshal([]byte(data), &value)
+
+	return value, err
+}
+
+func (s *SISRProcessor) evaluator(ctx context.Context) *sisrEvaluator {
+	engine := s.engine
+
+	if engine == nil {
+		engine = OttoEngine{}
+	}
+
+	return &sisrEvaluator{engine: engine, ctx: ctx, limits: s.Limits}
+}
+
+// Returns the SISR instance of the match as JSON, with the properties of objects in sorted order so that every script
+// engine gives the same JSON. An instance which is undefined is null.
+func (s *SISRProcessor) GetJSON() ([]byte, error) {
+	return s.GetJSONContext(context.Background())
+}
+
+// Returns the SISR instance of the match as GetJSON does, stopping the tag scripts when the context is done
+func (s *SISRProcessor) GetJSONContext(ctx context.Context) ([]byte, error) {
+	value, err := s.GetValueContext(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(value); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n")), nil
+}
+
+// Runs the script of the whole match with the script engine, and returns the string value of an expression of the
+// result. This is how the instance is found with script engines other than OttoEngine and GojaEngine.
+func (s *SISRProcessor) run(ctx context.Context, expression string) (string, error) {
+	if len(s.events) == 0 {
+		return "", NoMatchFound
+	}
+
+	script := new(SimpleProcessor)
+
+	for _, e := range s.events {
+		var err error
+
+		switch {
+		case e.kind == eventTag && e.tag.format != sisrLiterals:
+			err = s.Limits.checkSize(e.tag.text)
+		case e.kind == eventScript:
+			err = s.Limits.checkSize(e.text)
+		}
+
+		if err != nil {
+			return "", err
+		}
+
+		writeEvent(script, e)
+	}
+
+	engine := s.evaluator(ctx).engine
+
+	if engine, ok := engine.(vmEngine); ok {
+		return engine.runScript(ctx, s.Limits, "var root;\n"+script.script, expression)
+	}
+
+	if err := checkContext(ctx); err != nil {
 		return "", err
 	}
 
-	return output.String(), nil
+	return engine.Run("var root;\n"+script.script, expression)
 }
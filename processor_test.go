@@ -0,0 +1,118 @@
+package srgs
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var orderXml = `<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" xml:lang="en-US" root="order">
+	<rule id="order" scope="public">
+		<tag>out = {drinks: [], togo: false};</tag>
+		<item repeat="1-3"><ruleref uri="#drink" /> <tag>out.drinks.push(rules.drink.out);</tag></item>
+		<item repeat="0-1">to go <tag>out.togo = true; out.note = "&lt;to go&gt; &amp; hot";</tag></item>
+		<tag>out.count = out.drinks.length; out.size = null;</tag>
+	</rule>
+
+	<rule id="drink">
+		<one-of>
+			<item>latte <tag>out = {name: "latte", price: 4.5};</tag></item>
+			<item>tea <tag>out = {name: "tea", price: 3};</tag></item>
+		</one-of>
+	</rule>
+</grammar>
+`
+
+func TestSISRProcessor_GetValue(t *testing.T) {
+	g := NewGrammar()
+	if !assert.Nil(t, g.LoadXml(orderXml)) {
+		return
+	}
+
+	for name, engine := range scriptEngines {
+		assert := assert.New(t)
+
+		p := NewSISRProcessor(engine)
+		if !assert.Nil(g.GetMatch("latte tea to go", p), name) {
+			continue
+		}
+
+		value, err := p.GetValue()
+		assert.Nil(err, name)
+		assert.Equal(map[string]interface{}{
+			"drinks": []interface{}{
+				map[string]interface{}{"name": "latte", "price": 4.5},
+				map[string]interface{}{"name": "tea", "price": float64(3)},
+			},
+			"togo":  true,
+			"note":  "<to go> & hot",
+			"count": float64(2),
+			"size":  nil,
+		}, value, name)
+
+		data, err := p.GetJSON()
+		assert.Nil(err, name)
+		assert.Equal(`{"count":2,"drinks":[{"name":"latte","price":4.5},{"name":"tea","price":3}],"note":"<to go> & hot","size":null,"togo":true}`,
+			string(data), name)
+
+		out, err := p.GetInstance()
+		assert.Nil(err, name)
+		assert.Equal("[object Object]", out, name)
+	}
+}
+
+func TestSISRProcessor_Scalars(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGrammar()
+	if !assert.Nil(g.LoadXml(digitsXml)) {
+		return
+	}
+
+	p := new(SISRProcessor)
+	if assert.Nil(g.GetMatch("one two three four five", p)) {
+		value, err := p.GetValue()
+		assert.Nil(err)
+		assert.Equal("12345", value)
+	}
+
+	g = NewGrammar()
+	if !assert.Nil(g.LoadXml(animalXml)) {
+		return
+	}
+
+	// a rule without tags has an undefined instance
+	p = new(SISRProcessor)
+	if assert.Nil(g.GetMatch("i am an antler", p)) {
+		value, err := p.GetValue()
+		assert.Nil(err)
+		assert.Nil(value)
+
+		data, err := p.GetJSON()
+		assert.Nil(err)
+		assert.Equal("null", string(data))
+	}
+}
+
+func TestSISRProcessor_NoMatchFound(t *testing.T) {
+	assert := assert.New(t)
+
+	p := new(SISRProcessor)
+
+	_, err := p.GetInstance()
+	assert.Equal(NoMatchFound, err)
+
+	_, err = p.GetValue()
+	assert.Equal(NoMatchFound, err)
+
+	_, err = p.GetJSON()
+	assert.Equal(NoMatchFound, err)
+
+	g := NewGrammar()
+	g.LoadXml(digitsXml)
+
+	// a failed match scans nothing into the processor
+	assert.Equal(NoMatch, g.GetMatch("one two three four", p))
+	_, err = p.GetInstance()
+	assert.Equal(NoMatchFound, err)
+}
@@ -0,0 +1,210 @@
+package srgs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var CyclicImport = errors.New("cyclic grammar import")
+
+// A Resolver loads the grammar documents referenced by rulerefs which are not local to a grammar, such as
+// uri="common/digits.grxml#digit". Documents may be in either XML or ABNF form.
+type Resolver interface {
+	// Returns the contents of the document at uri. Relative uris have already been resolved against the base uri of
+	// the referencing grammar.
+	Resolve(uri string) (string, error)
+}
+
+// FileResolver resolves grammar documents from the filesystem. Relative uris are read relative to Dir.
+type FileResolver struct {
+	Dir string
+}
+
+// Implements Resolver Resolve method
+func (f *FileResolver) Resolve(uri string) (string, error) {
+	name := filepath.FromSlash(strings.TrimPrefix(uri, "file://"))
+
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(f.Dir, name)
+	}
+
+	data, err := os.ReadFile(name)
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// MapResolver resolves grammar documents from memory, keyed by uri.
+type MapResolver map[string]string
+
+// Implements Resolver Resolve method
+func (m MapResolver) Resolve(uri string) (string, error) {
+	doc, ok := m[uri]
+
+	if !ok {
+		return "", fmt.Errorf("grammar document %s: %w", uri, os.ErrNotExist)
+	}
+
+	return doc, nil
+}
+
+// Holds the documents imported while loading a grammar, so that each document is only loaded once
+type imports struct {
+	// the grammar being loaded, which all other documents were imported by
+	top *Grammar
+
+	resolver Resolver
+
+	grammars map[string]*Grammar
+
+	// the uris of the documents currently being loaded, in the order they were referenced
+	loading []string
+}
+
+func newImports(top *Grammar) *imports {
+	return &imports{
+		top:      top,
+		resolver: top.Resolver,
+		grammars: make(map[string]*Grammar),
+	}
+}
+
+// Returns the grammar of the document at uri, loading it if it has not been loaded yet
+func (i *imports) load(uri string) (*Grammar, error) {
+	if g, ok := i.grammars[uri]; ok {
+		return g, nil
+	}
+
+	for ind, loading := range i.loading {
+		if loading == uri {
+			return nil, fmt.Errorf("%w: %s -> %s", CyclicImport, strings.Join(i.loading[ind:], " -> "), uri)
+		}
+	}
+
+	doc, err := i.resolver.Resolve(uri)
+
+	if err != nil {
+		return nil, err
+	}
+
+	g := &Grammar{Resolver: i.resolver, Normalizer: i.top.Normalizer, uri: uri, imports: i}
+
+	i.loading = append(i.loading, uri)
+	err = g.loadDocument(doc)
+	i.loading = i.loading[:len(i.loading)-1]
+
+	// problems in the document already name its uri
+	if err != nil {
+		return nil, err
+	}
+
+	i.grammars[uri] = g
+
+	return g, nil
+}
+
+// Loads the grammar document at uri using the grammar's Resolver. Relative rulerefs in the document are resolved
+// against uri.
+func (g *Grammar) LoadUri(uri string) error {
+	if g.Resolver == nil {
+		return errors.New("cannot load grammar " + uri + " without a resolver")
+	}
+
+	doc, err := g.Resolver.Resolve(uri)
+
+	if err != nil {
+		return err
+	}
+
+	g.uri = uri
+	g.imports = newImports(g)
+	g.imports.loading = []string{uri}
+	defer g.clearImports()
+
+	return g.loadDocument(doc)
+}
+
+// Loads a document in either XML or ABNF form
+func (g *Grammar) loadDocument(doc string) error {
+	if strings.HasPrefix(strings.TrimSpace(strings.TrimPrefix(doc, "\uFEFF")), "#ABNF") {
+		return g.loadABNF(doc)
+	}
+
+	return g.loadXml(doc)
+}
+
+// Returns whether this grammar was loaded as a document referenced by another grammar
+func (g *Grammar) imported() bool {
+	return g.imports != nil && g.imports.top != g
+}
+
+func (g *Grammar) clearImports() {
+	g.imports = nil
+}
+
+// Returns a reference to a rule of another grammar document, such as "common/digits.grxml#digit". If the uri has no
+// fragment, the reference is to the root rule of the document.
+func (g *Grammar) externalRuleRef(uri string) (*RuleRef, error) {
+	if g.Resolver == nil {
+		return nil, errors.New("cannot understand ruleref uri " + uri + " because it is not local")
+	}
+
+	doc, id := uri, ""
+
+	if ind := strings.Index(uri, "#"); ind != -1 {
+		doc, id = uri[:ind], uri[ind+1:]
+	}
+
+	imported, err := g.imports.load(resolveUri(g.base, doc))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if id == "" {
+		if imported.Root == nil {
+			return nil, fmt.Errorf("%w: %s", RootNotFound, uri)
+		}
+
+		return &RuleRef{ruleId: imported.Root.ruleId, rule: imported.Root.rule, uri: uri, format: imported.sisrFormat()}, nil
+	}
+
+	rule, ok := imported.rules[id]
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", RuleNotFound, uri)
+	}
+
+	if imported.scopes[id] != ScopePublic {
+		return nil, fmt.Errorf("%w: %s", PrivateRule, uri)
+	}
+
+	return &RuleRef{ruleId: id, rule: rule, uri: uri, format: imported.sisrFormat()}, nil
+}
+
+// Resolves a uri referenced by a grammar against the grammar's base uri
+func resolveUri(base, uri string) string {
+	if base == "" || path.IsAbs(uri) || strings.Contains(uri, "://") {
+		return uri
+	}
+
+	if ind := strings.Index(base, "://"); ind != -1 {
+		host := strings.Index(base[ind+3:], "/")
+
+		if host == -1 {
+			return base + "/" + uri
+		}
+
+		prefix := base[:ind+3+host]
+		return prefix + path.Join(path.Dir(base[len(prefix):]), uri)
+	}
+
+	return path.Join(path.Dir(base), uri)
+}
@@ -0,0 +1,189 @@
+package srgs
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var commonDigitsXml = `<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" xml:lang="en-US" tag-format="swi-semantics/1.0">
+	<rule id="digit" scope="public">
+		<one-of>
+			<item>one <tag>out = "1";</tag></item>
+			<item>two <tag>out = "2";</tag></item>
+			<item><ruleref uri="#three" /> <tag>out = rules.three.out;</tag></item>
+		</one-of>
+	</rule>
+
+	<rule id="three">
+		three <tag>out = "3";</tag>
+	</rule>
+
+	<rule id="pair" scope="public">
+		<ruleref uri="#digit" /> <tag>out = rules.digit.out;</tag>
+		<ruleref uri="#digit" /> <tag>out = out + rules.digit.out;</tag>
+	</rule>
+</grammar>
+`
+
+var commonYesNoAbnf = `#ABNF 1.0 UTF-8;
+root $yesno;
+
+public $yesno = yes {out = true;} | no {out = false;};
+`
+
+var commonNumberXml = `<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" xml:lang="en-US" root="number">
+	<rule id="number">
+		<ruleref uri="digits.grxml#pair" /> <tag>out = rules.pair.out;</tag>
+	</rule>
+</grammar>
+`
+
+func newOrderXml(ref, rule string) string {
+	return `<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" xml:lang="en-US" root="order">
+	<rule id="order" scope="public">
+		i want <ruleref uri="` + ref + `" /> <tag>out = rules.` + rule + `.out;</tag>
+	</rule>
+</grammar>
+`
+}
+
+// counts how many times each document is resolved
+type countingResolver struct {
+	Resolver
+	counts map[string]int
+}
+
+func (c *countingResolver) Resolve(uri string) (string, error) {
+	c.counts[uri]++
+	return c.Resolver.Resolve(uri)
+}
+
+func TestResolver_ExternalRule(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGrammar()
+	g.Resolver = MapResolver{"common/digits.grxml": commonDigitsXml}
+
+	if !assert.Nil(g.LoadXml(newOrderXml("common/digits.grxml#digit", "digit"))) {
+		return
+	}
+
+	assert.True(g.HasMatch("i want two"))
+	assert.True(g.HasMatch("i want three"))
+	assert.True(g.HasPrefix("i want th"))
+	assert.False(g.HasMatch("i want four"))
+
+	p := new(SISRProcessor)
+	if !assert.Nil(g.GetMatch("i want three", p)) {
+		return
+	}
+
+	out, err := p.GetInstance()
+	assert.Nil(err)
+	assert.Equal("3", out)
+}
+
+func TestResolver_ExternalRoot(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGrammar()
+	g.Resolver = MapResolver{"common/yesno.gram": commonYesNoAbnf}
+
+	if !assert.Nil(g.LoadXml(newOrderXml("common/yesno.gram", "yesno"))) {
+		return
+	}
+
+	p := new(SISRProcessor)
+	if !assert.Nil(g.GetMatch("i want yes", p)) {
+		return
+	}
+
+	out, err := p.GetInstance()
+	assert.Nil(err)
+	assert.Equal("true", out)
+
+	// grammars without a root can only be referenced by rule
+	g.Resolver = MapResolver{"common/digits.grxml": commonDigitsXml}
+	assert.True(errors.Is(g.LoadXml(newOrderXml("common/digits.grxml", "order")), RootNotFound))
+}
+
+func TestResolver_RelativeUrisAndCaching(t *testing.T) {
+	assert := assert.New(t)
+
+	resolver := &countingResolver{
+		Resolver: MapResolver{
+			"grammars/order.grxml":         newOrderXml("common/number.grxml", "number"),
+			"grammars/common/number.grxml": commonNumberXml,
+			"grammars/common/digits.grxml": commonDigitsXml,
+		},
+		counts: make(map[string]int),
+	}
+
+	g := NewGrammar()
+	g.Resolver = resolver
+
+	if !assert.Nil(g.LoadUri("grammars/order.grxml")) {
+		return
+	}
+
+	p := new(SISRProcessor)
+	if !assert.Nil(g.GetMatch("i want one three", p)) {
+		return
+	}
+
+	out, err := p.GetInstance()
+	assert.Nil(err)
+	assert.Equal("13", out)
+
+	// the number grammar references digits twice, but it is only resolved once
+	assert.Equal(map[string]int{
+		"grammars/order.grxml":         1,
+		"grammars/common/number.grxml": 1,
+		"grammars/common/digits.grxml": 1,
+	}, resolver.counts)
+}
+
+func TestResolver_Errors(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGrammar()
+	assert.EqualError(g.LoadXml(newOrderXml("common/digits.grxml#digit", "digit")),
+		"line 4, column 10, rule order, grammar/rule/ruleref: cannot understand ruleref uri common/digits.grxml#digit because it is not local")
+
+	g.Resolver = MapResolver{"common/digits.grxml": commonDigitsXml}
+	assert.True(errors.Is(g.LoadXml(newOrderXml("common/digits.grxml#three", "three")), PrivateRule))
+	assert.True(errors.Is(g.LoadXml(newOrderXml("common/digits.grxml#four", "four")), RuleNotFound))
+	assert.True(errors.Is(g.LoadXml(newOrderXml("common/other.grxml#digit", "digit")), os.ErrNotExist))
+
+	g.Resolver = MapResolver{
+		"a.grxml": newOrderXml("b.grxml", "order"),
+		"b.grxml": newOrderXml("a.grxml", "order"),
+	}
+	err := g.LoadUri("a.grxml")
+	assert.True(errors.Is(err, CyclicImport))
+	assert.Contains(err.Error(), "a.grxml -> b.grxml -> a.grxml")
+}
+
+func TestFileResolver(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	assert.Nil(os.MkdirAll(filepath.Join(dir, "common"), 0755))
+	assert.Nil(os.WriteFile(filepath.Join(dir, "common", "digits.grxml"), []byte(commonDigitsXml), 0644))
+	assert.Nil(os.WriteFile(filepath.Join(dir, "order.grxml"), []byte(newOrderXml("common/digits.grxml#digit", "digit")), 0644))
+
+	g := NewGrammar()
+	g.Resolver = &FileResolver{Dir: dir}
+
+	if !assert.Nil(g.LoadUri("order.grxml")) {
+		return
+	}
+
+	assert.True(g.HasMatch("i want one"))
+}
@@ -1,48 +1,210 @@
 package srgs
 
 import (
-	"fmt"
+	"sort"
 	"strings"
 )
 
+// Garbage is the special rule GARBAGE, which matches words that the grammar does not care about (see
+// https://www.w3.org/TR/speech-grammar/#S2.2.3). By default it matches any number of words, trying the fewest first.
 type Garbage struct {
-	match     string
 	scanMatch bool
 
-	currentInd int
+	// the fewest and most words matched, where a maxWords of 0 has no bound
+	minWords, maxWords int
+
+	// the words which are never matched
+	stopWords map[string]bool
+
+	repeatMode RepeatMode
+}
+
+// GarbageOptions bound the words a GARBAGE rule matches. The zero value matches any number of words, fewest first.
+type GarbageOptions struct {
+	// The fewest and most words matched. A MaxWords of 0 has no bound.
+	MinWords, MaxWords int
+
+	// Words which are never matched, such as the words which should end the garbage
+	StopWords []string
+
+	// RepeatModeGreedy tries the most words first when a string can be matched in more than one way, and
+	// RepeatModeLazy and RepeatModeNormal the fewest
+	Mode RepeatMode
+
+	// Whether the words matched are added to the interpretation, as scan-match="true" does
+	ScanMatch bool
+}
+
+// Creates a GARBAGE rule which matches words within bounds. Its stop words are normalized by the zero Normalizer.
+func NewGarbage(opts GarbageOptions) *Garbage {
+	return newGarbage(opts, func(word string) string {
+		return Normalizer{}.normalize(word, "")
+	})
+}
+
+// Creates a GARBAGE rule whose stop words are put in the normal form of a grammar
+func newGarbage(opts GarbageOptions, normalize func(string) string) *Garbage {
+	g := &Garbage{scanMatch: opts.ScanMatch, minWords: opts.MinWords, maxWords: opts.MaxWords, repeatMode: opts.Mode}
+
+	for _, word := range opts.StopWords {
+		if g.stopWords == nil {
+			g.stopWords = make(map[string]bool)
+		}
+
+		g.stopWords[normalize(word)] = true
+	}
+
+	return g
+}
+
+// Returns the stop words of the garbage in alphabetical order
+func (g *Garbage) sortedStopWords() []string {
+	words := make([]string, 0, len(g.stopWords))
+
+	for word := range g.stopWords {
+		words = append(words, word)
+	}
+
+	sort.Strings(words)
+
+	return words
+}
+
+// A position the garbage can stop at in a string, after consuming a number of words
+type garbageStop struct {
+	offset, words int
+}
+
+// Returns the positions the garbage can stop at in a string, after consuming each number of words from none up to its
+// maximum or the first stop word. The minimum is not checked. In ModePrefix the last word of the string may be
+// incomplete, so it is never taken to be a stop word.
+func (g *Garbage) stops(str string, mode MatchMode) []garbageStop {
+	stops := []garbageStop{{0, 0}}
+
+	for offset, words := 0, 0; offset < len(str) && (g.maxWords <= 0 || words < g.maxWords); {
+		end, next := len(str), len(str)
+
+		if ind := strings.IndexByte(str[offset:], ' '); ind != -1 {
+			end, next = offset+ind, offset+ind+1
+		}
+
+		if g.stopWords[str[offset:end]] && (mode == ModeExact || end < len(str)) {
+			break
+		}
+
+		offset, words = next, words+1
+		stops = append(stops, garbageStop{offset, words})
+	}
+
+	return stops
+}
+
+// Implements Expansion NewMatcher method
+func (g *Garbage) NewMatcher() Matcher {
+	return &garbageMatcher{g: g}
 }
 
-func (g *Garbage) Match(str string, mode MatchMode) {
-	g.currentInd = -1
+type garbageMatcher struct {
+	g     *Garbage
+	match string
+
+	// the offsets of the string the garbage can stop at, in the order they are tried
+	ends []int
+
+	next int
+	end  int
+}
+
+func (g *garbageMatcher) Match(str string, mode MatchMode) {
 	g.match = str
+	g.ends = g.ends[:0]
+	g.next = 0
+
+	for _, stop := range g.g.stops(str, mode) {
+		// a prefix which ends before the minimum could be followed by more words
+		if stop.words >= g.g.minWords || mode == ModePrefix && stop.offset == len(str) {
+			g.ends = append(g.ends, stop.offset)
+		}
+	}
+
+	if g.g.repeatMode == RepeatModeGreedy {
+		for i, j := 0, len(g.ends)-1; i < j; i, j = i+1, j-1 {
+			g.ends[i], g.ends[j] = g.ends[j], g.ends[i]
+		}
+	}
 }
 
-func (g *Garbage) Next() (string, error) {
-	if g.currentInd == len(g.match) {
+func (g *garbageMatcher) Next() (string, error) {
+	if g.next == len(g.ends) {
 		return "", NoMatch
 	}
 
-	if g.currentInd != -1 {
-		ind := strings.Index(g.match[g.currentInd:], " ")
+	g.end = g.ends[g.next]
+	g.next++
 
-		if ind == -1 {
-			ind = len(g.match) - 1 - g.currentInd
-		}
+	return g.match[g.end:], nil
+}
 
-		g.currentInd += ind
+func (g *garbageMatcher) Score() float64 { return 1 }
+
+func (g *garbageMatcher) Scan(processor Processor) {
+	scanGarbage(processor, g.match[:g.end], g.g.scanMatch)
+}
+
+// Scans the words consumed by a GARBAGE rule, which are available to tags as GARBAGE, and are added to the
+// interpretation if the rule has scan-match
+func scanGarbage(processor Processor, text string, scanMatch bool) {
+	text = strings.TrimSpace(text)
+	scanEvent(processor, sisrEvent{kind: eventGarbage, text: text})
+
+	if scanMatch && text != "" {
+		processor.AppendString(text)
 	}
+}
+
+// Null is the special rule NULL, which is always matched and consumes nothing (see
+// https://www.w3.org/TR/speech-grammar/#S2.2.3)
+type Null struct{}
 
-	g.currentInd++
+// Implements Expansion NewMatcher method
+func (n *Null) NewMatcher() Matcher {
+	return new(nullMatcher)
+}
+
+type nullMatcher struct {
+	match  string
+	called bool
+}
 
-	return g.match[g.currentInd:], nil
+func (n *nullMatcher) Match(str string, mode MatchMode) {
+	n.match = str
+	n.called = false
 }
 
-func (g *Garbage) Copy(r RuleRefs) Expansion {
-	return &Garbage{match: g.match, currentInd: g.currentInd, scanMatch: g.scanMatch}
+func (n *nullMatcher) Next() (string, error) {
+	if n.called {
+		return "", NoMatch
+	}
+
+	n.called = true
+
+	return n.match, nil
 }
-func (g *Garbage) Scan(processor Processor) {
-	processor.AppendTag(fmt.Sprintf(`
-scopes[scopes.length-1]['GARBAGE'] = "%s";
-`, g.match[:g.currentInd]))
-	processor.AppendString(g.match[:g.currentInd])
+
+func (n *nullMatcher) Scan(processor Processor) {}
+func (n *nullMatcher) Score() float64           { return 1 }
+
+// Void is the special rule VOID, which can never be matched (see https://www.w3.org/TR/speech-grammar/#S2.2.3)
+type Void struct{}
+
+// Implements Expansion NewMatcher method
+func (v *Void) NewMatcher() Matcher {
+	return new(voidMatcher)
 }
+
+type voidMatcher struct{}
+
+func (v *voidMatcher) Match(str string, mode MatchMode) {}
+func (v *voidMatcher) Next() (string, error)            { return "", NoMatch }
+func (v *voidMatcher) Scan(processor Processor)         {}
+func (v *voidMatcher) Score() float64                   { return 0 }
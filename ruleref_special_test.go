@@ -1,13 +1,14 @@
 package srgs
 
 import (
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
 
 func TestGarbage(t *testing.T) {
 	assert := assert.New(t)
-	g := new(Garbage)
+	g := new(Garbage).NewMatcher()
 
 	g.Match("my name is rob", ModeExact)
 
@@ -37,3 +38,355 @@ func TestGarbage(t *testing.T) {
 	_, err = g.Next()
 	assert.Equal(NoMatch, err)
 }
+
+// The words matched by GARBAGE are only part of the interpretation with scan-match
+func TestGarbage_ScanMatch(t *testing.T) {
+	assert := assert.New(t)
+
+	for garbage, want := range map[*Garbage]string{new(Garbage): "", {scanMatch: true}: "my name"} {
+		m := garbage.NewMatcher()
+		m.Match("my name", ModeExact)
+
+		// the garbage matches every word
+		for str, err := m.Next(); str != ""; str, err = m.Next() {
+			assert.Nil(err)
+		}
+
+		p := new(SimpleProcessor)
+		m.Scan(p)
+		assert.Equal(want, p.GetInterpretation())
+	}
+}
+
+func TestNull(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, mode := range []MatchMode{ModePrefix, ModeExact} {
+		n := new(Null).NewMatcher()
+		n.Match("my name is rob", mode)
+
+		str, err := n.Next()
+		assert.Nil(err)
+		assert.Equal("my name is rob", str)
+
+		_, err = n.Next()
+		assert.Equal(NoMatch, err)
+
+		// the matcher starts again with each string it matches
+		n.Match("", mode)
+
+		str, err = n.Next()
+		assert.Nil(err)
+		assert.Empty(str)
+	}
+}
+
+func TestVoid(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, mode := range []MatchMode{ModePrefix, ModeExact} {
+		for _, str := range []string{"", "my name is rob"} {
+			v := new(Void).NewMatcher()
+			v.Match(str, mode)
+
+			_, err := v.Next()
+			assert.Equal(NoMatch, err)
+		}
+	}
+}
+
+func TestSpecialRules(t *testing.T) {
+	xml := `<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" xml:lang="en-US" root="example">
+	<rule id="example" scope="public">
+		<ruleref special="NULL" />
+		hello
+		<one-of>
+			<item>world <ruleref special="NULL" /><tag>out = "world";</tag></item>
+			<item>there <ruleref special="VOID" /></item>
+			<item><ruleref special="NULL" /><tag>out = "nobody";</tag></item>
+		</one-of>
+	</rule>
+</grammar>
+`
+
+	for _, backend := range []Backend{BackendMatcher, BackendChart} {
+		assert := assert.New(t)
+
+		g := NewGrammar()
+		g.Backend = backend
+		if !assert.Nil(g.LoadXml(xml)) {
+			return
+		}
+
+		assert.True(g.HasMatch("hello world"))
+		assert.True(g.HasMatch("hello"))
+		assert.False(g.HasMatch("hello there"))
+		assert.False(g.HasMatch(""))
+
+		assert.True(g.HasPrefix("hello"))
+		assert.True(g.HasPrefix("hello wor"))
+		assert.False(g.HasPrefix("hello there"))
+
+		for input, want := range map[string]string{"hello world": "world", "hello": "nobody"} {
+			p := new(SISRProcessor)
+			if assert.Nil(g.GetMatch(input, p), input) {
+				assert.Equal(input, p.GetInterpretation())
+
+				out, err := p.GetInstance()
+				assert.Nil(err)
+				assert.Equal(want, out)
+			}
+		}
+
+		assertRoundTrips(assert, g)
+	}
+
+	// a rule which is only VOID is never matched
+	g := NewGrammar()
+	if assert.Nil(t, g.LoadXml(`<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" root="example">
+	<rule id="example"><ruleref special="VOID" /></rule>
+</grammar>`)) {
+		assert.False(t, g.HasMatch(""))
+		assert.False(t, g.HasPrefix(""))
+	}
+}
+
+func TestSpecialRules_Unknown(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGrammar()
+	err := g.LoadXml(`<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" root="example">
+	<rule id="example">hello <ruleref special="NOTHING" /></rule>
+</grammar>`)
+
+	var problems GrammarErrors
+	if assert.True(errors.As(err, &problems)) && assert.Len(problems, 1) {
+		assert.True(errors.Is(problems[0], UnknownSpecialRule))
+		assert.Equal("grammar/rule/ruleref", problems[0].Path)
+		assert.Contains(err.Error(), "unknown special rule: NOTHING")
+	}
+}
+
+func TestGarbage_Bounded(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		opts GarbageOptions
+		str  string
+		mode MatchMode
+		want []string
+	}{
+		{GarbageOptions{MinWords: 1, MaxWords: 2}, "a b c", ModeExact, []string{"b c", "c"}},
+		{GarbageOptions{MinWords: 1, MaxWords: 2, Mode: RepeatModeGreedy}, "a b c", ModeExact, []string{"c", "b c"}},
+		{GarbageOptions{MinWords: 2}, "a b c", ModeExact, []string{"c", ""}},
+		{GarbageOptions{MinWords: 2}, "a", ModeExact, nil},
+		{GarbageOptions{StopWords: []string{"Please"}}, "a please b", ModeExact, []string{"a please b", "please b"}},
+		{GarbageOptions{StopWords: []string{"please"}}, "please", ModeExact, []string{"please"}},
+		{GarbageOptions{MaxWords: 1}, "", ModeExact, []string{""}},
+
+		// a prefix can end part way through the words of the garbage, or in a partial stop word
+		{GarbageOptions{MinWords: 2}, "a", ModePrefix, []string{""}},
+		{GarbageOptions{MinWords: 1, StopWords: []string{"please"}}, "a plea", ModePrefix, []string{"plea", ""}},
+		{GarbageOptions{StopWords: []string{"please"}}, "a please", ModePrefix, []string{"a please", "please", ""}},
+	}
+
+	for _, test := range tests {
+		g := NewGarbage(test.opts).NewMatcher()
+		g.Match(test.str, test.mode)
+
+		var got []string
+
+		for {
+			str, err := g.Next()
+			if err != nil {
+				assert.Equal(NoMatch, err)
+				break
+			}
+
+			got = append(got, str)
+		}
+
+		assert.Equal(test.want, got, "%+v: %q", test.opts, test.str)
+	}
+}
+
+var boundedGarbageXml = `<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" xmlns:srgs="https://github.com/robcapo/srgs" version="1.0"
+	root="order">
+	<rule id="order">
+		<ruleref special="GARBAGE" srgs:repeat="0-2" />
+		order <tag>out = {};</tag>
+		<ruleref special="GARBAGE" srgs:repeat="1-" srgs:stop-words="please" scan-match="true" />
+		please <tag>out.item = GARBAGE;</tag>
+		<ruleref special="GARBAGE" srgs:repeat="0-1" /> <tag>out.rest = GARBAGE;</tag>
+	</rule>
+</grammar>
+`
+
+// GARBAGE at the start, middle and end of a rule is matched the same way by every backend
+func TestGarbage_Positions(t *testing.T) {
+	matches := map[string]string{
+		"order a pizza please":              `{"item":"a pizza","rest":""}`,
+		"i want order a pizza please now":   `{"item":"a pizza","rest":"now"}`,
+		"order please please":               "",
+		"order please":                      "",
+		"order a please pizza please":       "",
+		"order a pizza please thanks a lot": "",
+		"one two three order pizza please":  "",
+	}
+
+	prefixes := map[string]bool{
+		"":                           true,
+		"i want":                     true,
+		"i want to order":            false,
+		"order":                      true,
+		"order a pizza ple":          true,
+		"order pizza please now":     true,
+		"order pizza please now ple": false,
+		"order please":               true,
+	}
+
+	for _, backend := range []Backend{BackendMatcher, BackendChart} {
+		assert := assert.New(t)
+
+		g := NewGrammar()
+		g.Backend = backend
+		if !assert.Nil(g.LoadXml(boundedGarbageXml)) {
+			return
+		}
+
+		compiled := NewGrammar()
+		compiled.LoadXml(boundedGarbageXml)
+		if !assert.Nil(compiled.Compile()) {
+			return
+		}
+
+		for input, want := range matches {
+			assert.Equal(want != "", g.HasMatch(input), "%s: HasMatch(%q)", backend, input)
+			assert.Equal(want != "", compiled.HasMatch(input), "compiled: HasMatch(%q)", input)
+
+			p := new(SISRProcessor)
+			if err := g.GetMatch(input, p); want == "" {
+				assert.NotNil(err, "%s: %q", backend, input)
+			} else if assert.Nil(err, "%s: %q", backend, input) {
+				out, err := p.GetJSON()
+				assert.Nil(err)
+				assert.Equal(want, string(out), "%s: %q", backend, input)
+			}
+		}
+
+		for input, want := range prefixes {
+			assert.Equal(want, g.HasPrefix(input), "%s: HasPrefix(%q)", backend, input)
+			assert.Equal(want, compiled.HasPrefix(input), "compiled: HasPrefix(%q)", input)
+		}
+
+		// only the GARBAGE with scan-match is in the interpretation
+		p := new(SISRProcessor)
+		if assert.Nil(g.GetMatch("i want order a pizza please now", p)) {
+			assert.Equal("order a pizza please", p.GetInterpretation())
+		}
+
+		assertRoundTrips(assert, g)
+	}
+
+	assert := assert.New(t)
+
+	g := NewGrammar()
+	if !assert.Nil(g.LoadXml(boundedGarbageXml)) {
+		return
+	}
+
+	// the GARBAGE in the middle generates the one word it needs
+	sentences, err := g.Enumerate(GenerateOptions{})
+	if assert.Nil(err) {
+		assert.Equal([]Sentence{{Text: "order something please"}}, sentences)
+	}
+
+	// the last GARBAGE is full once its word is complete, so no more words can follow
+	assert.Equal([]Suggestion{{Wildcard: true}, {Word: "please", Suffix: " please"}},
+		g.Complete("order a pizza", 0).Suggestions)
+	assert.Empty(g.Complete("order a pizza please now ", 0).Suggestions)
+	assert.Equal([]Suggestion{{Wildcard: true}}, g.Complete("order a pizza please n", 0).Suggestions)
+}
+
+// Lazy GARBAGE matches as few words as it can, and greedy GARBAGE as many
+func TestGarbage_RepeatMode(t *testing.T) {
+	tests := []struct {
+		mode  RepeatMode
+		input string
+		want  string
+	}{
+		{RepeatModeLazy, "one two three end", `{"a":"","b":"one two three"}`},
+		{RepeatModeNormal, "one two three end", `{"a":"","b":"one two three"}`},
+		{RepeatModeGreedy, "one two three end", `{"a":"one two","b":"three"}`},
+		{RepeatModeGreedy, "one end", `{"a":"one","b":""}`},
+		{RepeatModeGreedy, "end", `{"a":"","b":""}`},
+	}
+
+	for _, test := range tests {
+		xml := `<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" root="main">
+	<rule id="main">
+		<ruleref xmlns:x="https://github.com/robcapo/srgs" special="GARBAGE" x:repeat="0-2" x:repeat-mode="` +
+			string(test.mode) + `" />
+		<tag>out = {a: GARBAGE};</tag>
+		<ruleref special="GARBAGE" /> <tag>out.b = GARBAGE;</tag>
+		end
+	</rule>
+</grammar>`
+
+		for _, backend := range []Backend{BackendMatcher, BackendChart} {
+			g := NewGrammar()
+			g.Backend = backend
+			if !assert.Nil(t, g.LoadXml(xml), test.mode) {
+				continue
+			}
+
+			p := new(SISRProcessor)
+			if assert.Nil(t, g.GetMatch(test.input, p), "%s: %s: %q", backend, test.mode, test.input) {
+				out, err := p.GetJSON()
+				assert.Nil(t, err)
+				assert.Equal(t, test.want, string(out), "%s: %s: %q", backend, test.mode, test.input)
+			}
+		}
+	}
+}
+
+func TestGarbage_Attributes(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, attrs := range []string{
+		`srgs:repeat="3-1"`, `srgs:repeat="x"`, `srgs:repeat="0"`, `srgs:repeat=""`, `srgs:repeat="1-2-3"`,
+		`srgs:repeat="0-0"`, `srgs:repeat-mode="eager"`,
+	} {
+		g := NewGrammar()
+		err := g.LoadXml(`<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" xmlns:srgs="https://github.com/robcapo/srgs" version="1.0"
+	root="example">
+	<rule id="example">hello <ruleref special="GARBAGE" ` + attrs + ` /></rule>
+</grammar>`)
+
+		var problems GrammarErrors
+		if assert.True(errors.As(err, &problems), attrs) && assert.Len(problems, 1, attrs) {
+			assert.Equal("grammar/rule/ruleref", problems[0].Path)
+		}
+	}
+
+	// attributes outside the extension namespace are ignored
+	g := NewGrammar()
+	if assert.Nil(g.LoadXml(`<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" xmlns:other="https://example.com" version="1.0" root="example">
+	<rule id="example">hello <ruleref special="GARBAGE" other:repeat="1" repeat="1" /></rule>
+</grammar>`)) {
+		assert.True(g.HasMatch("hello"))
+	}
+
+	assert.Equal("$GARBAGE<1-3 greedy -and -or>", expansionText(NewGarbage(GarbageOptions{
+		MinWords: 1, MaxWords: 3, Mode: RepeatModeGreedy, StopWords: []string{"or", "AND"},
+	})))
+	assert.Equal("$GARBAGE<2->", expansionText(NewGarbage(GarbageOptions{MinWords: 2})))
+	assert.Equal("$GARBAGE", expansionText(new(Garbage)))
+}
@@ -1,7 +1,10 @@
 package srgs
 
-import (
-	"fmt"
+type RuleScope string
+
+const (
+	ScopePublic  RuleScope = "public"
+	ScopePrivate RuleScope = "private"
 )
 
 type Rules map[string]Expansion
@@ -10,31 +13,50 @@ type RuleRefs map[string][]*RuleRef
 type RuleRef struct {
 	rule   Expansion
 	ruleId string
+
+	// the uri the rule was referenced by, if it is a rule of another grammar document
+	uri string
+
+	// how the tags of the rule are interpreted, according to the tag-format of its grammar
+	format sisrFormat
 }
 
-func (r *RuleRef) Match(str string, mode MatchMode) {
-	r.rule.Match(str, mode)
+// Implements Expansion NewMatcher method
+func (r *RuleRef) NewMatcher() Matcher {
+	return &ruleRefMatcher{ref: r}
 }
-func (r *RuleRef) Next() (string, error) {
-	return r.rule.Next()
+
+type ruleRefMatcher struct {
+	ref *RuleRef
+
+	// The matcher for the referenced rule is only created once the reference is matched, so that rules which
+	// reference each other do not create matchers indefinitely
+	rule Matcher
 }
-func (r *RuleRef) Copy(rr RuleRefs) Expansion {
-	ref := new(RuleRef)
-	ref.ruleId = r.ruleId
-	if r.rule != nil {
-		ref.rule = r.rule.Copy(rr)
+
+func (r *ruleRefMatcher) Match(str string, mode MatchMode) {
+	if r.rule == nil {
+		r.rule = r.ref.rule.NewMatcher()
 	}
 
-	rr[ref.ruleId] = append(rr[ref.ruleId], ref)
+	r.rule.Match(str, mode)
+}
+func (r *ruleRefMatcher) Next() (string, error) {
+	return r.rule.Next()
+}
+func (r *ruleRefMatcher) Score() float64 {
+	return r.rule.Score()
+}
 
-	return ref
+func (r *ruleRefMatcher) Scan(p Processor) {
+	scanRuleRef(p, r.ref, r.rule)
 }
 
-func (r *RuleRef) Scan(p Processor) {
-	p.AppendTag("scopes.push({'rules':{}, 'out':undefined, 'raw':undefined});")
-	r.rule.Scan(p)
-	p.AppendTag(fmt.Sprintf(`var last = scopes.pop();
-scopes[scopes.length-1]['rules']['%s'] = {'out': last.out, 'raw': last.raw};
-scopes[scopes.length-1]['raw'] = scopes[scopes.length-1]['raw'] ? scopes[scopes.length-1]['raw'] + ' ' + last.raw : last.raw;
-`, r.ruleId))
+// Scans the path through a referenced rule in a new scope, then stores the rule's result in the enclosing scope. The
+// rule variable of an SISR 1.0 rule starts as an empty object, and if no tag of the rule changes it, it becomes the
+// text the rule matched.
+func scanRuleRef(p Processor, ref *RuleRef, rule matchPath) {
+	scanEvent(p, sisrEvent{kind: eventRuleStart, ref: ref})
+	rule.Scan(p)
+	scanEvent(p, sisrEvent{kind: eventRuleEnd, ref: ref})
 }
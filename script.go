@@ -0,0 +1,27 @@
+package srgs
+
+import "context"
+
+// A ScriptEngine runs the script built from the tags of a match, which gives the match's SISR instance
+type ScriptEngine interface {
+	// Runs a script in a new global environment, then evaluates an expression and returns its value as a string
+	Run(script, expression string) (string, error)
+}
+
+// OttoEngine runs scripts with otto, an ECMAScript 5 interpreter. It is the engine SISRProcessor uses by default. Like
+// GojaEngine, its global environment is locked down, so that scripts cannot make code from strings with eval or
+// Function, or change the built-in objects.
+type OttoEngine struct{}
+
+// Implements ScriptEngine Run method
+func (e OttoEngine) Run(script, expression string) (string, error) {
+	return e.runScript(context.Background(), ScriptLimits{}, script, expression)
+}
+
+// GojaEngine runs scripts with goja, which is faster than otto and supports much of ECMAScript 6
+type GojaEngine struct{}
+
+// Implements ScriptEngine Run method
+func (e GojaEngine) Run(script, expression string) (string, error) {
+	return e.runScript(context.Background(), ScriptLimits{}, script, expression)
+}
@@ -0,0 +1,184 @@
+package srgs
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+var scriptEngines = map[string]ScriptEngine{"otto": OttoEngine{}, "goja": GojaEngine{}}
+
+func TestScriptEngine_Run(t *testing.T) {
+	tests := []struct {
+		name       string
+		script     string
+		expression string
+		want       string
+		err        bool
+	}{
+		{"string", `var out = "a" + "b";`, "out", "ab", false},
+		{"number", "var out = 1 / 4;", "out", "0.25", false},
+		{"integer", "var out = 12345;", "out", "12345", false},
+		{"concatenation", `var out = "" + 1 + 2;`, "out", "12", false},
+		{"object", "var out = {a: 1};", "out", "[object Object]", false},
+		{"array", "var out = [1, 'two'];", "out", "1,two", false},
+		{"undefined", "var out;", "out", "undefined", false},
+		{"function", "function f(x) { return x * 2; } var out = f(21);", "out", "42", false},
+		{"syntax error", "var out = ;", "out", "", true},
+		{"thrown error", "throw new Error('bad');", "out", "", true},
+		{"reference error", "var out = missing.value;", "out", "", true},
+	}
+
+	for _, test := range tests {
+		for name, engine := range scriptEngines {
+			t.Run(name+"/"+test.name, func(t *testing.T) {
+				out, err := engine.Run(test.script, test.expression)
+
+				if test.err {
+					assert.NotNil(t, err)
+					return
+				}
+
+				assert.Nil(t, err)
+				assert.Equal(t, test.want, out)
+			})
+		}
+	}
+}
+
+// Every engine gives the same instance for each sentence of the grammars with tag scripts
+func TestScriptEngine_Conformance(t *testing.T) {
+	grammars := map[string]string{
+		"digits": digitsXml, "animal": animalXml, "city": cityXml, "garbage": garbageXml, "list": listXml,
+	}
+
+	for grammarName, xml := range grammars {
+		g := NewGrammar()
+		g.Backend = BackendChart
+		if !assert.Nil(t, g.LoadXml(xml), grammarName) {
+			continue
+		}
+
+		sentences, err := g.Enumerate(GenerateOptions{MaxWords: 6, MaxSentences: 40})
+		if !assert.Nil(t, err, grammarName) {
+			continue
+		}
+
+		for _, sentence := range sentences {
+			// otto is the default engine
+			want := new(SISRProcessor)
+			if !assert.Nil(t, g.GetMatch(sentence.Text, want), sentence.Text) {
+				continue
+			}
+
+			wantOut, wantErr := want.GetInstance()
+			wantJSON, _ := want.GetJSON()
+
+			for name, engine := range scriptEngines {
+				p := NewSISRProcessor(engine)
+				if !assert.Nil(t, g.GetMatch(sentence.Text, p), "%s: %q", name, sentence.Text) {
+					continue
+				}
+
+				out, err := p.GetInstance()
+
+				assert.Equal(t, wantOut, out, "%s: %s %q", name, grammarName, sentence.Text)
+				assert.Equal(t, wantErr == nil, err == nil, "%s: %s %q", name, grammarName, sentence.Text)
+
+				data, _ := p.GetJSON()
+				assert.Equal(t, string(wantJSON), string(data), "%s: %s %q", name, grammarName, sentence.Text)
+			}
+		}
+	}
+
+	for name, engine := range scriptEngines {
+		g := NewGrammar()
+		g.LoadXml(digitsXml)
+
+		for input, want := range map[string]string{
+			"one two three four five": "12345", "triple three four five": "33345", "double four five six seven": "44567",
+		} {
+			p := NewSISRProcessor(engine)
+
+			if assert.Nil(t, g.GetMatch(input, p), "%s: %q", name, input) {
+				out, err := p.GetInstance()
+				assert.Nil(t, err, "%s: %q", name, input)
+				assert.Equal(t, want, out, "%s: %q", name, input)
+			}
+		}
+
+		p := NewSISRProcessor(engine)
+		g.LoadXml(animalXml)
+
+		if assert.Nil(t, g.GetMatch("i am an aardvark", p)) {
+			out, err := p.GetInstance()
+			assert.Nil(t, err)
+			assert.Equal(t, "undefined", out, name)
+		}
+	}
+
+	// sparse arrays have holes which are read as undefined, as the script of the whole match reads them
+	for _, tag := range []string{`out = [,1]`, `out = [1,,2]; out.x = [,]`, `out = []; out[3] = 1`} {
+		g := tagGrammar(t, tag)
+
+		for name, engine := range scriptEngines {
+			p := NewSISRProcessor(engine)
+
+			if assert.Nil(t, g.GetMatch("word", p), "%s: %s", name, tag) {
+				assertMatchesScript(t, p, "%s: %s", name, tag)
+
+				data, err := p.GetJSON()
+				assert.Nil(t, err, "%s: %s", name, tag)
+				assert.Contains(t, string(data), "null", "%s: %s", name, tag)
+			}
+		}
+	}
+}
+
+// Runs the script of the whole match, as every match was run before tags were compiled
+func benchmarkScriptEngine(b *testing.B, engine ScriptEngine) {
+	g := NewGrammar()
+	g.LoadXml(digitsXml)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		p := NewSISRProcessor(engine)
+		g.GetMatch("one two three four five", p)
+		p.run(context.Background(), "root.out")
+	}
+}
+
+func BenchmarkOttoDigitsInstance(b *testing.B) {
+	benchmarkScriptEngine(b, OttoEngine{})
+}
+
+func BenchmarkGojaDigitsInstance(b *testing.B) {
+	benchmarkScriptEngine(b, GojaEngine{})
+}
+
+// The digits grammar with tags which the native evaluator leaves to the script engine, so every tag runs in a VM
+var digitsVMXml = strings.Replace(digitsXml, "<tag>", "<tag>void 0; ", -1)
+
+// Runs the compiled tags of each match in pooled VMs
+func benchmarkCompiledTags(b *testing.B, engine ScriptEngine) {
+	g := NewGrammar()
+	g.LoadXml(digitsVMXml)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		p := NewSISRProcessor(engine)
+		g.GetMatch("one two three four five", p)
+		p.GetInstance()
+	}
+}
+
+func BenchmarkOttoDigitsCompiled(b *testing.B) {
+	benchmarkCompiledTags(b, OttoEngine{})
+}
+
+func BenchmarkGojaDigitsCompiled(b *testing.B) {
+	benchmarkCompiledTags(b, GojaEngine{})
+}
@@ -3,45 +3,54 @@ package srgs
 // Sequence is any sequence of legal expansions (see https://www.w3.org/TR/speech-grammar/#S2.3)
 type Sequence struct {
 	exps []Expansion
-
-	str  string
-	mode MatchMode
-
-	nextInd int
 }
 
-// Implements Expansion Copy method
-func (s *Sequence) Copy(r RuleRefs) Expansion {
-	out := &Sequence{
-		exps:    make([]Expansion, len(s.exps)),
-		str:     s.str,
-		mode:    s.mode,
-		nextInd: s.nextInd,
+// Implements Expansion NewMatcher method
+func (s *Sequence) NewMatcher() Matcher {
+	out := &sequenceMatcher{
+		exps: make([]Matcher, len(s.exps)),
 	}
 
 	for ind, e := range s.exps {
-		out.exps[ind] = e.Copy(r)
+		out.exps[ind] = e.NewMatcher()
 	}
 
 	return out
 }
 
-// Implements Expansion Match method
-func (s *Sequence) Match(str string, mode MatchMode) {
+type sequenceMatcher struct {
+	exps []Matcher
+
+	str  string
+	mode MatchMode
+
+	nextInd int
+}
+
+// Implements Matcher Match method
+func (s *sequenceMatcher) Match(str string, mode MatchMode) {
 	s.str = str
 	s.mode = mode
 
 	s.nextInd = 0
 
-	s.exps[0].Match(str, mode)
+	if len(s.exps) > 0 {
+		s.exps[0].Match(str, mode)
+	}
 }
 
-// Implements Expansion Next method
-func (s *Sequence) Next() (string, error) {
+// Implements Matcher Next method
+func (s *sequenceMatcher) Next() (string, error) {
 	if s.nextInd < 0 {
 		return "", NoMatch
 	}
 
+	// an empty sequence, such as an empty rule, matches like NULL
+	if len(s.exps) == 0 {
+		s.nextInd = -1
+		return s.str, nil
+	}
+
 	var str string
 	var err error
 
@@ -62,8 +71,19 @@ func (s *Sequence) Next() (string, error) {
 	return str, err
 }
 
-// Implements Expansion Scan method
-func (s *Sequence) Scan(p Processor) {
+// Implements Matcher Score method
+func (s *sequenceMatcher) Score() float64 {
+	score := 1.0
+
+	for _, exp := range s.exps {
+		score *= exp.Score()
+	}
+
+	return score
+}
+
+// Implements Matcher Scan method
+func (s *sequenceMatcher) Scan(p Processor) {
 	for _, exp := range s.exps {
 		exp.Scan(p)
 	}
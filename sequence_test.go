@@ -7,11 +7,10 @@ import (
 
 func TestSequence_MatchSimplePrefixMode(t *testing.T) {
 	assert := assert.New(t)
-	seq := new(Sequence)
-	seq.exps = []Expansion{
+	seq := (&Sequence{exps: []Expansion{
 		NewToken("my"),
 		NewToken("name is"),
-	}
+	}}).NewMatcher()
 
 	seq.Match("my name", ModePrefix)
 	str, err := seq.Next()
@@ -45,12 +44,11 @@ func TestSequence_MatchSimplePrefixMode(t *testing.T) {
 func TestSequenceWithGarbage(t *testing.T) {
 	assert := assert.New(t)
 
-	seq := new(Sequence)
-	seq.exps = []Expansion{
+	seq := (&Sequence{exps: []Expansion{
 		new(Garbage),
 		NewToken("ten"),
 		new(Garbage),
-	}
+	}}).NewMatcher()
 
 	seq.Match("i am ten years old", ModeExact)
 
@@ -72,3 +70,59 @@ func TestSequenceWithGarbage(t *testing.T) {
 	_, err = seq.Next()
 	assert.Equal(NoMatch, err)
 }
+
+// An empty sequence matches without consuming anything, like NULL
+func TestSequence_Empty(t *testing.T) {
+	assert := assert.New(t)
+
+	seq := new(Sequence).NewMatcher()
+	seq.Match("my name", ModeExact)
+	str, err := seq.Next()
+	assert.Nil(err)
+	assert.Equal("my name", str)
+	_, err = seq.Next()
+	assert.Equal(NoMatch, err)
+
+	b := NewBuilder()
+	g, err := b.Rule("x", ScopePublic).Build()
+	if assert.Nil(err) {
+		assert.True(g.HasMatch(""))
+		assert.False(g.HasMatch("x"))
+	}
+
+	xml := `<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" xml:lang="en-US" root="main">
+	<rule id="main">a <ruleref uri="#e" /> b <tag>out = "matched";</tag></rule>
+	<rule id="e"></rule>
+</grammar>`
+
+	for _, backend := range []Backend{BackendMatcher, BackendChart} {
+		for _, compile := range []bool{false, true} {
+			g := NewGrammar()
+			g.Backend = backend
+			if !assert.Nil(g.LoadXml(xml)) {
+				return
+			}
+
+			if compile {
+				assert.Nil(g.Compile())
+			}
+
+			assert.True(g.HasMatch("a b"), "%d %v", backend, compile)
+			assert.True(g.HasPrefix("a"), "%d %v", backend, compile)
+			assert.False(g.HasMatch("a"), "%d %v", backend, compile)
+
+			p := new(SISRProcessor)
+			if assert.Nil(g.GetMatch("a b", p), "%d %v", backend, compile) {
+				out, _ := p.GetInstance()
+				assert.Equal("matched", out)
+			}
+
+			results, err := g.GetNBest("a b", 0, newSISRProcessor)
+			assert.Nil(err)
+			assert.Len(results, 1)
+
+			assert.Equal([]string{"b"}, words(g.Complete("a ", 0)))
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package srgs
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// How the tags of a grammar are interpreted, according to its tag-format
+type sisrFormat int
+
+const (
+	// Tags are scripts which see the rules matched as rules.X.out and rules.X.raw. This is how tags of any tag format
+	// other than those of SISR 1.0 are interpreted.
+	sisrScript sisrFormat = iota
+
+	// Tags are SISR 1.0 scripts (tag-format="semantics/1.0")
+	sisrSemantics
+
+	// Tags are SISR 1.0 string literals (tag-format="semantics/1.0-literals")
+	sisrLiterals
+)
+
+// Returns how the tags of a grammar with a tag-format are interpreted
+func parseSISRFormat(tagFormat string) sisrFormat {
+	switch strings.TrimSpace(tagFormat) {
+	case "semantics/1.0":
+		return sisrSemantics
+	case "semantics/1.0-literals":
+		return sisrLiterals
+	}
+
+	return sisrScript
+}
+
+// Returns how the tags of this grammar are interpreted
+func (g *Grammar) sisrFormat() sisrFormat {
+	return parseSISRFormat(g.tagFormat)
+}
+
+// Returns a tag of this grammar
+func (g *Grammar) newTag(text string) *Tag {
+	return newTag(text, g.sisrFormat())
+}
+
+// A step of scanning a match into a processor. The steps of a match give the structure of its SISR instance, which
+// SISRProcessor evaluates in Go where it can, and other processors are given as the script of the match's tags.
+type sisrEvent struct {
+	kind sisrEventKind
+
+	// the rule referenced, for eventMatchEnd, eventRuleStart and eventRuleEnd
+	ref *RuleRef
+
+	// the tag, for eventTag
+	tag *Tag
+
+	// the words matched, for eventString and eventGarbage, or the script of eventScript
+	text string
+}
+
+type sisrEventKind int
+
+const (
+	eventMatchStart sisrEventKind = iota
+	eventMatchEnd
+	eventRuleStart
+	eventRuleEnd
+	eventTag
+	eventGarbage
+	eventString
+	eventScript
+)
+
+// A processor which is given the steps of a match as it is scanned, instead of the script of its tags
+type sisrTracer interface {
+	trace(e sisrEvent)
+}
+
+// Scans a step of a match into a processor, as script unless the processor traces the steps of matches
+func scanEvent(p Processor, e sisrEvent) {
+	if t, ok := p.(sisrTracer); ok {
+		t.trace(e)
+	} else {
+		writeEvent(p, e)
+	}
+}
+
+// Appends the script of a step of a match to a processor. The script of a whole match leaves the rule matched in
+// root, where root.out is the SISR instance.
+func writeEvent(p Processor, e sisrEvent) {
+	switch e.kind {
+	case eventMatchStart:
+		p.AppendTag(sisrPreamble)
+	case eventMatchEnd:
+		p.AppendTag("root = scopes[0]['rules'][" + jsString(e.ref.ruleId) + "];")
+	case eventRuleStart:
+		p.AppendTag("scopes.push(newScope());")
+
+		if e.ref.format != sisrScript {
+			p.AppendTag("scopes[scopes.length-1]['out'] = scopes[scopes.length-1]['init'] = {};")
+		}
+	case eventRuleEnd:
+		p.AppendTag("var last = scopes.pop();")
+
+		if e.ref.format != sisrScript {
+			p.AppendTag(`if (last.out === last.init && Object.keys(last.out).length === 0) {
+	last.out = last.raw === undefined ? '' : last.raw;
+}`)
+		}
+
+		p.AppendTag(fmt.Sprintf(`var scope = scopes[scopes.length-1];
+scope['rules'][%[1]s] = {'out': last.out, 'raw': last.raw};
+scope['vars'][%[1]s] = last.out;
+scope['meta'][%[1]s] = {'text': last.raw === undefined ? '' : last.raw, 'score': 1};
+scope['latest'] = %[1]s;
+if (last.raw !== undefined) {
+	scope['raw'] = scope['raw'] ? scope['raw'] + ' ' + last.raw : last.raw;
+}
+`, jsString(e.ref.ruleId)))
+	case eventTag:
+		writeTag(p, e.tag)
+	case eventGarbage:
+		p.AppendTag("\nscopes[scopes.length-1]['GARBAGE'] = " + jsString(e.text) + ";\n")
+	case eventString:
+		str := jsString(" " + e.text)
+		p.AppendTag("scopes[scopes.length-1]['raw'] = scopes[scopes.length-1]['raw'] ? scopes[scopes.length-1]['raw'] + " +
+			str + " : " + jsString(e.text) + ";")
+	case eventScript:
+		p.AppendTag(e.text)
+	}
+}
+
+// Appends the script of a tag. Scripts run with the rules, meta, out, raw and GARBAGE variables of the current scope,
+// where rules holds the SISR 1.0 rule variables of the rules matched in SISR 1.0 grammars. Literals are assigned to
+// the rule variable.
+func writeTag(p Processor, tag *Tag) {
+	if tag.format == sisrLiterals {
+		p.AppendTag("scopes[scopes.length-1]['out'] = " + jsString(strings.TrimSpace(tag.text)) + ";")
+		return
+	}
+
+	text, rules := tag.text, "rules"
+
+	if tag.format == sisrSemantics {
+		text, rules = expandShorthands(text), "vars"
+	}
+
+	p.AppendTag(`
+(function () {
+	var rules = scopes[scopes.length-1]['` + rules + `'];
+	var meta = scopes[scopes.length-1]['meta'];
+	var out = scopes[scopes.length-1]['out'];
+	var raw = scopes[scopes.length-1]['raw'];
+	var GARBAGE = scopes[scopes.length-1]['GARBAGE'];
+`)
+	p.AppendTag(text)
+
+	p.AppendTag(`
+	scopes[scopes.length-1]['out'] = out;
+})();`)
+}
+
+// Creates the scope of each rule matched, which holds its rule variable (out) and the text it matched (raw). The
+// variables of the rules it references are kept both as rules.X.out for scripts, and as SISR 1.0 rule variables with
+// their meta data.
+const sisrPreamble = `function newScope() {
+	var scope = {'rules': {}, 'vars': {}, 'meta': {}, 'out': undefined, 'raw': undefined, 'latest': undefined};
+	Object.defineProperty(scope.vars, 'latest', {value: function () { return scope.vars[scope.latest]; }});
+	Object.defineProperty(scope.meta, 'latest', {value: function () { return scope.meta[scope.latest]; }});
+	Object.defineProperty(scope.meta, 'current', {value: function () {
+		return {'text': scope.raw === undefined ? '' : scope.raw, 'score': 1};
+	}});
+	return scope;
+}
+var scopes = [newScope()];
+`
+
+// Returns a string as a JavaScript string literal
+func jsString(str string) string {
+	quoted, _ := json.Marshal(str)
+
+	return string(quoted)
+}
+
+// Expands the shorthands of SISR 1.0 scripts: $ for out, $$ for rules.latest() and $X for rules.X. Strings and
+// comments are left as they are.
+func expandShorthands(script string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(script); {
+		c := script[i]
+
+		switch {
+		case c == '"' || c == '\'':
+			end := i + 1
+
+			for end < len(script) && script[end] != c {
+				if script[end] == '\\' {
+					end++
+				}
+
+				end++
+			}
+
+			if end = end + 1; end > len(script) {
+				end = len(script)
+			}
+
+			b.WriteString(script[i:end])
+			i = end
+		case strings.HasPrefix(script[i:], "//"):
+			end := strings.IndexByte(script[i:], '\n')
+
+			if end == -1 {
+				end = len(script) - i
+			}
+
+			b.WriteString(script[i : i+end])
+			i += end
+		case strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+
+			if end == -1 {
+				end = len(script) - i
+			} else {
+				end += 4
+			}
+
+			b.WriteString(script[i : i+end])
+			i += end
+		case c == '$' && strings.HasPrefix(script[i:], "$$"):
+			b.WriteString("rules.latest()")
+			i += 2
+		case c == '$' && i+1 < len(script) && isIdentifierStart(script[i+1]):
+			end := i + 1
+
+			for end < len(script) && isIdentifierPart(script[end]) {
+				end++
+			}
+
+			b.WriteString("rules." + script[i+1:end])
+			i = end
+		case c == '$':
+			b.WriteString("out")
+			i++
+		case isIdentifierPart(c):
+			// a $ within an identifier, such as a$b, is part of the identifier
+			end := i
+
+			for end < len(script) && isIdentifierPart(script[end]) {
+				end++
+			}
+
+			b.WriteString(script[i:end])
+			i = end
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+
+	return b.String()
+}
+
+func isIdentifierStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isIdentifierPart(c byte) bool {
+	return isIdentifierStart(c) || c == '$' || c >= '0' && c <= '9' || c >= 0x80
+}
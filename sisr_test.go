@@ -0,0 +1,175 @@
+package srgs
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// Asserts the SISR instance of each input, as JSON, with every script engine and with the native evaluator
+func assertInstances(t *testing.T, g *Grammar, want map[string]string) {
+	for name, engine := range scriptEngines {
+		for input, json := range want {
+			p := NewSISRProcessor(engine)
+
+			if !assert.Nil(t, g.GetMatch(input, p), "%s: %q", name, input) {
+				continue
+			}
+
+			out, err := p.GetJSON()
+			assert.Nil(t, err, "%s: %q", name, input)
+			assert.Equal(t, json, string(out), "%s: %q", name, input)
+
+			assertMatchesScript(t, p, "%s: %q", name, input)
+		}
+	}
+}
+
+func TestSISR_RuleVariables(t *testing.T) {
+	// from the drink order examples of SISR 1.0
+	xml := `<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" xml:lang="en-US" root="order" tag-format="semantics/1.0">
+	<rule id="order" scope="public">
+		i would like a
+		<ruleref uri="#size" />
+		<ruleref uri="#drink" />
+		<tag>out.drink = {type: rules.drink, size: rules.size};</tag>
+		<item repeat="0-1">please</item>
+	</rule>
+
+	<rule id="size">
+		<one-of>
+			<item>small <tag>out = "S";</tag></item>
+			<item>medium</item>
+			<item>large <tag>out = "L";</tag></item>
+		</one-of>
+	</rule>
+
+	<rule id="drink">
+		<one-of>
+			<item>coke</item>
+			<item>pepsi</item>
+			<item>coca cola <tag>out = "coke";</tag></item>
+		</one-of>
+	</rule>
+</grammar>
+`
+
+	g := NewGrammar()
+	if !assert.Nil(t, g.LoadXml(xml)) {
+		return
+	}
+
+	// a rule whose tags never change its rule variable has the text it matched
+	assertInstances(t, g, map[string]string{
+		"i would like a small coca cola please": `{"drink":{"size":"S","type":"coke"}}`,
+		"i would like a medium pepsi":           `{"drink":{"size":"medium","type":"pepsi"}}`,
+	})
+
+	g = NewGrammar()
+	if assert.Nil(t, g.LoadXml(strings.Replace(xml, `root="order"`, `root="drink"`, 1))) {
+		assertInstances(t, g, map[string]string{"coke": `"coke"`, "coca cola": `"coke"`})
+	}
+}
+
+func TestSISR_Meta(t *testing.T) {
+	abnf := `#ABNF 1.0 UTF-8;
+language en-US;
+root $flight;
+tag-format <semantics/1.0>;
+
+public $flight = from $city {out.from = rules.latest(); out.fromText = meta.latest().text;}
+	to $city {out.to = rules.city; out.toText = meta.city.text; out.score = meta.city.score;}
+	{out.all = meta.current().text;};
+
+$city = new york {out = "NYC";} | boston {out = "BOS";} | san francisco;
+`
+
+	g := NewGrammar()
+	if !assert.Nil(t, g.LoadABNF(abnf)) {
+		return
+	}
+
+	assertInstances(t, g, map[string]string{
+		"from new york to san francisco": `{"all":"from new york to san francisco","from":"NYC","fromText":"new york",` +
+			`"score":1,"to":"san francisco","toText":"san francisco"}`,
+	})
+}
+
+func TestSISR_Shorthands(t *testing.T) {
+	abnf := `#ABNF 1.0 UTF-8;
+language en-US;
+root $pizza;
+tag-format <semantics/1.0>;
+
+public $pizza = a $size pizza with $topping {$.size = $size; $.toppings = [$$];}
+	[and $topping {$.toppings.push($$); $.note = "costs $5, or '$$'";}];
+
+$size = small | large {$ = "big";};
+$topping = cheese | ham;
+`
+
+	g := NewGrammar()
+	if !assert.Nil(t, g.LoadABNF(abnf)) {
+		return
+	}
+
+	assertInstances(t, g, map[string]string{
+		"a small pizza with ham":            `{"size":"small","toppings":["ham"]}`,
+		"a large pizza with ham and cheese": `{"note":"costs $5, or '$$'","size":"big","toppings":["ham","cheese"]}`,
+	})
+}
+
+func TestSISR_Literals(t *testing.T) {
+	xml := `<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" xml:lang="en-US" root="city" tag-format="semantics/1.0-literals">
+	<rule id="city" scope="public">
+		<one-of>
+			<item>boston <tag>BOS</tag></item>
+			<item>new york <tag> New York "City" </tag></item>
+			<item>portland</item>
+		</one-of>
+	</rule>
+</grammar>
+`
+
+	g := NewGrammar()
+	if !assert.Nil(t, g.LoadXml(xml)) {
+		return
+	}
+
+	assertInstances(t, g, map[string]string{
+		"boston":   `"BOS"`,
+		"new york": `"New York \"City\""`,
+		"portland": `"portland"`,
+	})
+}
+
+func TestSISR_OtherFormats(t *testing.T) {
+	assert := assert.New(t)
+
+	// grammars without an SISR 1.0 tag-format keep rules.X.out, and rule variables start undefined
+	g := NewGrammar()
+	if !assert.Nil(g.LoadXml(digitsXml)) {
+		return
+	}
+
+	assertInstances(t, g, map[string]string{"one two three four five": `"12345"`})
+
+	g = NewGrammar()
+	if !assert.Nil(g.LoadXml(animalXml)) {
+		return
+	}
+
+	assertInstances(t, g, map[string]string{"i am an antler": `null`})
+}
+
+func TestExpandShorthands(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("out = rules.city;", expandShorthands("$ = $city;"))
+	assert.Equal("out.x = rules.latest().y + rules.a_1;", expandShorthands("$.x = $$.y + $a_1;"))
+	assert.Equal(`a$b = "$x" + '$$' + rules.c; // $d`, expandShorthands(`a$b = "$x" + '$$' + $c; // $d`))
+	assert.Equal(`out = "\"$"; /* $ */ out += rules.d`, expandShorthands(`$ = "\"$"; /* $ */ $ += $d`))
+	assert.Equal(`out = "unterminated \`, expandShorthands(`$ = "unterminated \`))
+}
@@ -0,0 +1,1267 @@
+package srgs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// The native evaluator gives the SISR instance of a match in Go, without a script engine, for literal tags and tag
+// scripts which the evaluator understands. Those scripts assign expressions to the rule variable or its properties,
+// such as out.size = rules.size.out, where an expression is made up of literals, property accesses, the + operator,
+// ||, && and ?:. Anything else, such as a function call, is left to the script engine. So is a script which would
+// throw an error, such as one which reads a property of undefined, so that its error is the engine's.
+
+// The values of the native evaluator are jsUndefined, nil for null, bool, float64, string, *jsObject and *jsArray
+type jsUndefined struct{}
+
+var undefined = jsUndefined{}
+
+type jsObject struct {
+	props map[string]interface{}
+
+	// the functions of the object, if it is the rules or meta variable of an SISR 1.0 scope
+	functions []string
+}
+
+func newJsObject() *jsObject {
+	return &jsObject{props: make(map[string]interface{})}
+}
+
+// Returns a property of the object, or false if it is one the evaluator does not know, such as toString
+func (o *jsObject) get(name string) (interface{}, bool) {
+	if value, ok := o.props[name]; ok {
+		return value, true
+	}
+
+	if isPrototypeProperty(name) || o.hasFunction(name) {
+		return nil, false
+	}
+
+	return undefined, true
+}
+
+// Sets a property of the object, or returns false if it is one the evaluator does not know
+func (o *jsObject) set(name string, value interface{}) bool {
+	if isPrototypeProperty(name) {
+		return false
+	}
+
+	// the functions of a scope's rules and meta variables cannot be replaced
+	if !o.hasFunction(name) {
+		o.props[name] = value
+	}
+
+	return true
+}
+
+func (o *jsObject) hasFunction(name string) bool {
+	for _, f := range o.functions {
+		if f == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns whether a property is one which every object inherits, such as toString or __proto__
+func isPrototypeProperty(name string) bool {
+	switch name {
+	case "constructor", "hasOwnProperty", "isPrototypeOf", "propertyIsEnumerable", "toLocaleString", "toString",
+		"valueOf":
+		return true
+	}
+
+	return strings.HasPrefix(name, "__")
+}
+
+type jsArray struct {
+	elems []interface{}
+}
+
+// The scope of a rule being matched, as created by newScope in sisrPreamble
+type nativeScope struct {
+	rules, vars, meta *jsObject
+
+	// the rule referenced most recently, or undefined
+	latest interface{}
+
+	// the rule variable, and the initial rule variable of an SISR 1.0 rule
+	out  interface{}
+	init *jsObject
+
+	// the text matched so far, and by the last GARBAGE rule, or undefined
+	raw, garbage interface{}
+}
+
+func newNativeScope() *nativeScope {
+	s := &nativeScope{
+		rules:   newJsObject(),
+		vars:    newJsObject(),
+		meta:    newJsObject(),
+		latest:  undefined,
+		out:     undefined,
+		raw:     undefined,
+		garbage: undefined,
+	}
+
+	s.vars.functions = []string{"latest"}
+	s.meta.functions = []string{"latest", "current"}
+
+	return s
+}
+
+// Evaluates the steps of a match, as the script from writeEvent would, keeping the scope of each rule in Go. Tags
+// are evaluated natively where they can be, and otherwise run with a VM of the script engine, which is acquired the
+// first time a tag needs it. The tags stop with a LimitError when the context is done or they pass the limits.
+type sisrEvaluator struct {
+	engine ScriptEngine
+	vm     tagVM
+	ctx    context.Context
+	limits ScriptLimits
+}
+
+// Returned by the evaluator when the match needs the script of the whole match to be run, because the script engine
+// has no VMs or a script was appended to the processor
+var needsScript = errors.New("match needs a script engine")
+
+// Releases the VM of the evaluator, if it acquired one
+func (e *sisrEvaluator) close() {
+	if e.vm != nil {
+		e.vm.finish()
+		e.engine.(vmEngine).release(e.vm)
+		e.vm = nil
+	}
+}
+
+// Returns the VM of the evaluator, acquiring one if it has none
+func (e *sisrEvaluator) acquire() (tagVM, error) {
+	if e.vm == nil {
+		engine, ok := e.engine.(vmEngine)
+
+		if !ok {
+			return nil, needsScript
+		}
+
+		e.vm = engine.acquire()
+		e.vm.start(e.ctx, e.limits)
+	}
+
+	return e.vm, nil
+}
+
+// Returns the SISR instance of a match
+func (e *sisrEvaluator) evaluate(events []sisrEvent) (interface{}, error) {
+	var scopes []*nativeScope
+	var root *jsObject
+
+	if err := checkContext(e.ctx); err != nil {
+		return nil, err
+	}
+
+	for _, ev := range events {
+		if ev.kind == eventMatchStart {
+			scopes = []*nativeScope{newNativeScope()}
+			continue
+		}
+
+		if len(scopes) == 0 {
+			return nil, needsScript
+		}
+
+		scope := scopes[len(scopes)-1]
+
+		switch ev.kind {
+		case eventMatchEnd:
+			rule, _ := scopes[0].rules.props[ev.ref.ruleId].(*jsObject)
+
+			if rule == nil {
+				return nil, needsScript
+			}
+
+			root = rule
+		case eventRuleStart:
+			scope = newNativeScope()
+
+			if ev.ref.format != sisrScript {
+				scope.init = newJsObject()
+				scope.out = scope.init
+			}
+
+			scopes = append(scopes, scope)
+		case eventRuleEnd:
+			if len(scopes) < 2 {
+				return nil, needsScript
+			}
+
+			scopes = scopes[:len(scopes)-1]
+			scopes[len(scopes)-1].store(ev.ref, scope)
+		case eventTag:
+			if ev.tag.format != sisrLiterals {
+				if err := e.limits.checkSize(ev.tag.text); err != nil {
+					return nil, err
+				}
+			}
+
+			if scope.run(ev.tag) {
+				continue
+			}
+
+			if err := checkContext(e.ctx); err != nil {
+				return nil, err
+			}
+
+			vm, err := e.acquire()
+
+			if err != nil {
+				return nil, err
+			}
+
+			if err := vm.runTag(ev.tag, scope); err != nil {
+				return nil, err
+			}
+		case eventGarbage:
+			scope.garbage = ev.text
+		case eventString:
+			scope.appendRaw(ev.text)
+		default:
+			return nil, needsScript
+		}
+	}
+
+	if root == nil {
+		return nil, needsScript
+	}
+
+	return root.props["out"], nil
+}
+
+// Returns String(value), with the evaluator's VM if it has one, since the value may hold values of the VM
+func (e *sisrEvaluator) toString(value interface{}) (string, error) {
+	if e.vm != nil {
+		return e.vm.toString(value)
+	}
+
+	return jsToString(value), nil
+}
+
+// Returns a value as JSON.parse(JSON.stringify(value)) would, with the evaluator's VM if it has one
+func (e *sisrEvaluator) export(value interface{}) (interface{}, error) {
+	if e.vm == nil {
+		if exported, ok := jsExport(value, nil); ok {
+			return exported, nil
+		}
+
+		// an object which contains itself is left to the script engine, which throws an error
+		if _, err := e.acquire(); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := e.vm.toJSON(value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var exported interface{}
+	err = json.Unmarshal([]byte(data), &exported)
+
+	return exported, err
+}
+
+// Evaluates a match natively, and returns false if any of its tags needs the script engine
+func evaluateNative(events []sisrEvent) (interface{}, bool) {
+	out, err := (&sisrEvaluator{ctx: context.Background()}).evaluate(events)
+	return out, err == nil
+}
+
+// Stores the result of a rule in the scope of the rule which referenced it
+func (s *nativeScope) store(ref *RuleRef, last *nativeScope) {
+	if ref.format != sisrScript && last.out == interface{}(last.init) && len(last.init.props) == 0 {
+		last.out = last.text()
+	}
+
+	rule := newJsObject()
+	rule.props["out"] = last.out
+	rule.props["raw"] = last.raw
+
+	meta := newJsObject()
+	meta.props["text"] = last.text()
+	meta.props["score"] = float64(1)
+
+	s.rules.set(ref.ruleId, rule)
+	s.vars.set(ref.ruleId, last.out)
+	s.meta.set(ref.ruleId, meta)
+	s.latest = ref.ruleId
+
+	if raw, ok := last.raw.(string); ok {
+		s.appendRaw(raw)
+	}
+}
+
+// Returns the text matched in the scope so far
+func (s *nativeScope) text() string {
+	raw, _ := s.raw.(string)
+	return raw
+}
+
+func (s *nativeScope) appendRaw(str string) {
+	if raw := s.text(); raw != "" {
+		s.raw = raw + " " + str
+	} else {
+		s.raw = str
+	}
+}
+
+// Runs a tag in the scope natively, or returns false if it needs the script engine. A tag which needs the script
+// engine leaves the scope as it was.
+func (s *nativeScope) run(tag *Tag) bool {
+	if tag.format == sisrLiterals {
+		s.out = strings.TrimSpace(tag.text)
+		return true
+	}
+
+	if tag.native == nil {
+		return false
+	}
+
+	var undo []nativeUndo
+
+	for _, a := range tag.native.assignments {
+		if !a.run(s, tag.format, &undo) {
+			for i := len(undo) - 1; i >= 0; i-- {
+				undo[i].restore(s)
+			}
+
+			return false
+		}
+	}
+
+	return true
+}
+
+// The value of the rule variable, or of a property of an object, before an assignment
+type nativeUndo struct {
+	// the object whose property was assigned, or nil for the rule variable
+	object *jsObject
+	name   string
+
+	value   interface{}
+	existed bool
+}
+
+func (u nativeUndo) restore(s *nativeScope) {
+	switch {
+	case u.object == nil:
+		s.out = u.value
+	case u.existed:
+		u.object.props[u.name] = u.value
+	default:
+		delete(u.object.props, u.name)
+	}
+}
+
+// A tag script parsed for the native evaluator
+type nativeScript struct {
+	assignments []nativeAssignment
+}
+
+// An assignment to the rule variable or one of its properties, such as out.size = rules.size.out
+type nativeAssignment struct {
+	// the properties of out assigned to, if any
+	path []string
+
+	// whether the value is added with +=
+	add bool
+
+	value nativeExpr
+}
+
+func (a nativeAssignment) run(s *nativeScope, format sisrFormat, undo *[]nativeUndo) bool {
+	value, ok := a.value.eval(s, format)
+
+	if !ok {
+		return false
+	}
+
+	if len(a.path) == 0 {
+		if a.add {
+			if value, ok = jsAdd(s.out, value); !ok {
+				return false
+			}
+		}
+
+		*undo = append(*undo, nativeUndo{value: s.out})
+		s.out = value
+
+		return true
+	}
+
+	target := s.out
+
+	for _, name := range a.path[:len(a.path)-1] {
+		o, isObject := target.(*jsObject)
+
+		if !isObject {
+			return false
+		}
+
+		if target, ok = o.get(name); !ok {
+			return false
+		}
+	}
+
+	o, isObject := target.(*jsObject)
+	name := a.path[len(a.path)-1]
+
+	if !isObject {
+		return false
+	}
+
+	if a.add {
+		current, ok := o.get(name)
+
+		if !ok {
+			return false
+		}
+
+		if value, ok = jsAdd(current, value); !ok {
+			return false
+		}
+	}
+
+	previous, existed := o.props[name]
+	*undo = append(*undo, nativeUndo{object: o, name: name, value: previous, existed: existed})
+
+	return o.set(name, value)
+}
+
+// An expression of a tag script, which returns false if it needs the script engine
+type nativeExpr interface {
+	eval(s *nativeScope, format sisrFormat) (interface{}, bool)
+}
+
+type nativeLiteral struct {
+	value interface{}
+}
+
+func (e nativeLiteral) eval(*nativeScope, sisrFormat) (interface{}, bool) {
+	return e.value, true
+}
+
+// One of the variables of a tag script: rules, meta, out, raw or GARBAGE
+type nativeVariable struct {
+	name string
+}
+
+func (e nativeVariable) eval(s *nativeScope, format sisrFormat) (interface{}, bool) {
+	switch e.name {
+	case "rules":
+		if format == sisrSemantics {
+			return s.vars, true
+		}
+
+		return s.rules, true
+	case "meta":
+		return s.meta, true
+	case "out":
+		return s.out, true
+	case "raw":
+		return s.raw, true
+	case "GARBAGE":
+		return s.garbage, true
+	}
+
+	return nil, false
+}
+
+type nativeProperty struct {
+	object nativeExpr
+	name   string
+}
+
+func (e nativeProperty) eval(s *nativeScope, format sisrFormat) (interface{}, bool) {
+	object, ok := e.object.eval(s, format)
+
+	if !ok {
+		return nil, false
+	}
+
+	if o, isObject := object.(*jsObject); isObject {
+		return o.get(e.name)
+	}
+
+	return nil, false
+}
+
+// A call of one of the functions of SISR 1.0: rules.latest(), meta.latest() or meta.current()
+type nativeCall struct {
+	variable, name string
+}
+
+func (e nativeCall) eval(s *nativeScope, format sisrFormat) (interface{}, bool) {
+	switch {
+	case e.variable == "rules" && e.name == "latest" && format == sisrSemantics:
+		return s.latestOf(s.vars), true
+	case e.variable == "meta" && e.name == "latest":
+		return s.latestOf(s.meta), true
+	case e.variable == "meta" && e.name == "current":
+		meta := newJsObject()
+		meta.props["text"] = s.text()
+		meta.props["score"] = float64(1)
+
+		return meta, true
+	}
+
+	return nil, false
+}
+
+func (s *nativeScope) latestOf(o *jsObject) interface{} {
+	if id, ok := s.latest.(string); ok {
+		if value, ok := o.props[id]; ok {
+			return value
+		}
+	}
+
+	return undefined
+}
+
+// The binary operators +, || and &&
+type nativeBinary struct {
+	op          string
+	left, right nativeExpr
+}
+
+func (e nativeBinary) eval(s *nativeScope, format sisrFormat) (interface{}, bool) {
+	left, ok := e.left.eval(s, format)
+
+	if !ok {
+		return nil, false
+	}
+
+	switch {
+	case e.op == "||" && jsTruthy(left), e.op == "&&" && !jsTruthy(left):
+		return left, true
+	}
+
+	right, ok := e.right.eval(s, format)
+
+	if !ok || e.op != "+" {
+		return right, ok
+	}
+
+	return jsAdd(left, right)
+}
+
+type nativeConditional struct {
+	test, then, otherwise nativeExpr
+}
+
+func (e nativeConditional) eval(s *nativeScope, format sisrFormat) (interface{}, bool) {
+	test, ok := e.test.eval(s, format)
+
+	if !ok {
+		return nil, false
+	}
+
+	if jsTruthy(test) {
+		return e.then.eval(s, format)
+	}
+
+	return e.otherwise.eval(s, format)
+}
+
+type nativeObjectLiteral struct {
+	names  []string
+	values []nativeExpr
+}
+
+func (e nativeObjectLiteral) eval(s *nativeScope, format sisrFormat) (interface{}, bool) {
+	o := newJsObject()
+
+	for i, name := range e.names {
+		value, ok := e.values[i].eval(s, format)
+
+		if !ok || !o.set(name, value) {
+			return nil, false
+		}
+	}
+
+	return o, true
+}
+
+type nativeArrayLiteral struct {
+	elems []nativeExpr
+}
+
+func (e nativeArrayLiteral) eval(s *nativeScope, format sisrFormat) (interface{}, bool) {
+	a := &jsArray{elems: make([]interface{}, len(e.elems))}
+
+	for i, elem := range e.elems {
+		value, ok := elem.eval(s, format)
+
+		if !ok {
+			return nil, false
+		}
+
+		a.elems[i] = value
+	}
+
+	return a, true
+}
+
+// Returns JavaScript's a + b, or false for objects, whose + the evaluator leaves to the script engine
+func jsAdd(a, b interface{}) (interface{}, bool) {
+	if !jsPrimitive(a) || !jsPrimitive(b) {
+		return nil, false
+	}
+
+	_, aString := a.(string)
+	_, bString := b.(string)
+
+	if aString || bString {
+		return jsToString(a) + jsToString(b), true
+	}
+
+	return jsToNumber(a) + jsToNumber(b), true
+}
+
+func jsPrimitive(value interface{}) bool {
+	switch value.(type) {
+	case *jsObject, *jsArray, jsForeign:
+		return false
+	}
+
+	return true
+}
+
+func jsTruthy(value interface{}) bool {
+	switch v := value.(type) {
+	case jsUndefined, nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0 && !math.IsNaN(v)
+	case string:
+		return v != ""
+	}
+
+	return true
+}
+
+func jsToNumber(value interface{}) float64 {
+	switch v := value.(type) {
+	case nil:
+		return 0
+	case bool:
+		if v {
+			return 1
+		}
+
+		return 0
+	case float64:
+		return v
+	}
+
+	return math.NaN()
+}
+
+// Returns JavaScript's String(value)
+func jsToString(value interface{}) string {
+	switch v := value.(type) {
+	case jsUndefined:
+		return "undefined"
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return jsNumberToString(v)
+	case string:
+		return v
+	case *jsArray:
+		elems := make([]string, len(v.elems))
+
+		for i, elem := range v.elems {
+			if elem != nil && elem != interface{}(undefined) {
+				elems[i] = jsToString(elem)
+			}
+		}
+
+		return strings.Join(elems, ",")
+	}
+
+	return "[object Object]"
+}
+
+// Returns JavaScript's String of a number, which is in exponential form below 1e-6 and from 1e21
+func jsNumberToString(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	case f == 0:
+		return "0"
+	}
+
+	if abs := math.Abs(f); abs >= 1e-6 && abs < 1e21 {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	// Go writes exponents with at least two digits, such as 1e-07
+	str := strconv.FormatFloat(f, 'e', -1, 64)
+	i := strings.IndexByte(str, 'e') + 2
+
+	return str[:i] + strings.TrimLeft(str[i:], "0")
+}
+
+// Returns a value as JSON.parse(JSON.stringify(value)) would in Go, or false for objects which contain themselves
+func jsExport(value interface{}, parents []*jsObject) (interface{}, bool) {
+	switch v := value.(type) {
+	case jsUndefined:
+		return nil, true
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, true
+		}
+
+		// JSON has no negative zero
+		return v + 0, true
+	case *jsArray:
+		elems := make([]interface{}, len(v.elems))
+
+		for i, elem := range v.elems {
+			var ok bool
+
+			if elems[i], ok = jsExport(elem, parents); !ok {
+				return nil, false
+			}
+		}
+
+		return elems, true
+	case *jsObject:
+		for _, parent := range parents {
+			if parent == v {
+				return nil, false
+			}
+		}
+
+		props := make(map[string]interface{}, len(v.props))
+
+		for name, prop := range v.props {
+			if prop == interface{}(undefined) {
+				continue
+			}
+
+			value, ok := jsExport(prop, append(parents, v))
+
+			if !ok {
+				return nil, false
+			}
+
+			props[name] = value
+		}
+
+		return props, true
+	}
+
+	return value, true
+}
+
+// Parses a tag script for the native evaluator, or returns nil if it needs a script engine. The shorthands of SISR 1.0
+// scripts are expanded first.
+func parseNativeScript(text string, format sisrFormat) *nativeScript {
+	if format == sisrLiterals {
+		return nil
+	}
+
+	if format == sisrSemantics {
+		text = expandShorthands(text)
+	}
+
+	tokens, ok := lexNativeScript(text)
+
+	if !ok {
+		return nil
+	}
+
+	p := &nativeParser{tokens: tokens}
+	script := new(nativeScript)
+
+	for !p.at(tokenEnd, "") {
+		if p.accept(tokenPunct, ";") {
+			continue
+		}
+
+		a, ok := p.assignment()
+
+		if !ok {
+			return nil
+		}
+
+		script.assignments = append(script.assignments, a)
+
+		// statements end with a semicolon or a line break
+		if !p.at(tokenPunct, ";") && !p.at(tokenEnd, "") && !p.peek().newline {
+			return nil
+		}
+	}
+
+	return script
+}
+
+type nativeTokenKind int
+
+const (
+	tokenEnd nativeTokenKind = iota
+	tokenName
+	tokenString
+	tokenNumber
+	tokenPunct
+)
+
+type nativeToken struct {
+	kind  nativeTokenKind
+	text  string
+	value interface{}
+
+	// whether a line break comes before the token
+	newline bool
+}
+
+// Splits a tag script into tokens, or returns false if it has any the evaluator does not understand, such as comments
+// or operators other than those of nativeBinary
+func lexNativeScript(text string) ([]nativeToken, bool) {
+	var tokens []nativeToken
+	newline := false
+
+	for i := 0; i < len(text); {
+		c := text[i]
+
+		switch {
+		case c == '\n' || c == '\r':
+			newline = true
+			i++
+			continue
+		case c == ' ' || c == '\t' || c == '\f' || c == '\v':
+			i++
+			continue
+		case isIdentifierStart(c) || c == '$':
+			end := i
+
+			for end < len(text) && isIdentifierPart(text[end]) && text[end] < 0x80 {
+				end++
+			}
+
+			if end < len(text) && text[end] >= 0x80 {
+				return nil, false
+			}
+
+			tokens = append(tokens, nativeToken{kind: tokenName, text: text[i:end]})
+			i = end
+		case c >= '0' && c <= '9':
+			end := i
+
+			for end < len(text) && (text[end] >= '0' && text[end] <= '9' || text[end] == '.') {
+				end++
+			}
+
+			if end < len(text) && (text[end] == 'e' || text[end] == 'E') {
+				end++
+
+				if end < len(text) && (text[end] == '+' || text[end] == '-') {
+					end++
+				}
+
+				for end < len(text) && text[end] >= '0' && text[end] <= '9' {
+					end++
+				}
+			}
+
+			// octal and hexadecimal numbers, and numbers followed by a name, are left to the script engine
+			if c == '0' && end > i+1 && text[i+1] != '.' || end < len(text) && isIdentifierPart(text[end]) {
+				return nil, false
+			}
+
+			f, err := strconv.ParseFloat(text[i:end], 64)
+
+			if err != nil {
+				return nil, false
+			}
+
+			tokens = append(tokens, nativeToken{kind: tokenNumber, text: text[i:end], value: f})
+			i = end
+		case c == '"' || c == '\'':
+			str, end, ok := lexNativeString(text, i)
+
+			if !ok {
+				return nil, false
+			}
+
+			tokens = append(tokens, nativeToken{kind: tokenString, text: text[i:end], value: str})
+			i = end
+		default:
+			punct := ""
+
+			for _, p := range []string{"+=", "||", "&&", "(", ")", "[", "]", "{", "}", ".", ",", ":", ";", "?", "=", "+"} {
+				if strings.HasPrefix(text[i:], p) {
+					punct = p
+					break
+				}
+			}
+
+			if punct == "" || strings.HasPrefix(text[i:], "==") || strings.HasPrefix(text[i:], "++") {
+				return nil, false
+			}
+
+			tokens = append(tokens, nativeToken{kind: tokenPunct, text: punct})
+			i += len(punct)
+		}
+
+		tokens[len(tokens)-1].newline = newline
+		newline = false
+	}
+
+	return append(tokens, nativeToken{kind: tokenEnd, newline: newline}), true
+}
+
+// Reads the string literal which starts at text[start], and returns its value and where it ends
+func lexNativeString(text string, start int) (string, int, bool) {
+	var b strings.Builder
+	quote := text[start]
+
+	for i := start + 1; i < len(text); {
+		c := text[i]
+
+		switch {
+		case c == quote:
+			return b.String(), i + 1, true
+		case c == '\n' || c == '\r':
+			return "", 0, false
+		case c != '\\':
+			b.WriteByte(c)
+			i++
+			continue
+		}
+
+		if i+1 >= len(text) {
+			return "", 0, false
+		}
+
+		escape := text[i+1]
+		i += 2
+
+		switch escape {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'v':
+			b.WriteByte('\v')
+		case 'x', 'u':
+			n := 2
+			if escape == 'u' {
+				n = 4
+			}
+
+			if i+n > len(text) {
+				return "", 0, false
+			}
+
+			r, err := strconv.ParseUint(text[i:i+n], 16, 32)
+
+			// surrogate pairs are left to the script engine
+			if err != nil || r >= 0xd800 && r < 0xe000 {
+				return "", 0, false
+			}
+
+			b.WriteRune(rune(r))
+			i += n
+		default:
+			// octal escapes and line continuations are left to the script engine
+			if escape >= '0' && escape <= '9' || escape == '\n' || escape == '\r' || escape >= 0x80 {
+				return "", 0, false
+			}
+
+			b.WriteByte(escape)
+		}
+	}
+
+	return "", 0, false
+}
+
+type nativeParser struct {
+	tokens []nativeToken
+	pos    int
+}
+
+func (p *nativeParser) peek() nativeToken {
+	return p.tokens[p.pos]
+}
+
+func (p *nativeParser) at(kind nativeTokenKind, text string) bool {
+	t := p.peek()
+	return t.kind == kind && (text == "" || t.text == text)
+}
+
+func (p *nativeParser) accept(kind nativeTokenKind, text string) bool {
+	if p.at(kind, text) {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+// Parses an assignment, such as out.size += "large"
+func (p *nativeParser) assignment() (nativeAssignment, bool) {
+	var a nativeAssignment
+
+	if !p.accept(tokenName, "out") {
+		return a, false
+	}
+
+	for {
+		name, ok, more := p.propertyName()
+
+		if !ok {
+			return a, false
+		}
+
+		if !more {
+			break
+		}
+
+		a.path = append(a.path, name)
+	}
+
+	switch {
+	case p.accept(tokenPunct, "="):
+	case p.accept(tokenPunct, "+="):
+		a.add = true
+	default:
+		return a, false
+	}
+
+	value, ok := p.conditional()
+	a.value = value
+
+	return a, ok
+}
+
+// Parses the name of a property access, such as .name or ['name']. more is false if there is no property access.
+func (p *nativeParser) propertyName() (name string, ok, more bool) {
+	switch {
+	case p.accept(tokenPunct, "."):
+		t := p.peek()
+
+		if !p.accept(tokenName, "") {
+			return "", false, false
+		}
+
+		return t.text, true, true
+	case p.accept(tokenPunct, "["):
+		t := p.peek()
+
+		if !p.accept(tokenString, "") || !p.accept(tokenPunct, "]") {
+			return "", false, false
+		}
+
+		return t.value.(string), true, true
+	}
+
+	return "", true, false
+}
+
+func (p *nativeParser) conditional() (nativeExpr, bool) {
+	test, ok := p.binary(0)
+
+	if !ok || !p.accept(tokenPunct, "?") {
+		return test, ok
+	}
+
+	then, ok := p.conditional()
+
+	if !ok || !p.accept(tokenPunct, ":") {
+		return nil, false
+	}
+
+	otherwise, ok := p.conditional()
+
+	return nativeConditional{test, then, otherwise}, ok
+}
+
+// The binary operators from the lowest precedence to the highest
+var nativeOperators = []string{"||", "&&", "+"}
+
+func (p *nativeParser) binary(level int) (nativeExpr, bool) {
+	if level == len(nativeOperators) {
+		return p.member()
+	}
+
+	left, ok := p.binary(level + 1)
+
+	for ok && p.accept(tokenPunct, nativeOperators[level]) {
+		var right nativeExpr
+
+		if right, ok = p.binary(level + 1); ok {
+			left = nativeBinary{nativeOperators[level], left, right}
+		}
+	}
+
+	return left, ok
+}
+
+// Parses a primary expression followed by any property accesses, such as rules.size.out
+func (p *nativeParser) member() (nativeExpr, bool) {
+	t := p.peek()
+
+	if t.kind == tokenName && (t.text == "rules" || t.text == "meta") && p.pos+3 < len(p.tokens) &&
+		p.tokens[p.pos+1].text == "." && p.tokens[p.pos+2].kind == tokenName && p.tokens[p.pos+3].text == "(" {
+		// a call of one of the functions of SISR 1.0, such as meta.current()
+		call := nativeCall{variable: t.text, name: p.tokens[p.pos+2].text}
+		p.pos += 4
+
+		if !p.accept(tokenPunct, ")") {
+			return nil, false
+		}
+
+		return p.properties(call)
+	}
+
+	exp, ok := p.primary()
+
+	if !ok {
+		return nil, false
+	}
+
+	return p.properties(exp)
+}
+
+func (p *nativeParser) properties(exp nativeExpr) (nativeExpr, bool) {
+	for {
+		name, ok, more := p.propertyName()
+
+		if !ok {
+			return nil, false
+		}
+
+		if !more {
+			break
+		}
+
+		exp = nativeProperty{exp, name}
+	}
+
+	// calls of any other function are left to the script engine
+	if p.at(tokenPunct, "(") {
+		return nil, false
+	}
+
+	return exp, true
+}
+
+func (p *nativeParser) primary() (nativeExpr, bool) {
+	t := p.peek()
+	p.pos++
+
+	switch t.kind {
+	case tokenString, tokenNumber:
+		return nativeLiteral{t.value}, true
+	case tokenName:
+		switch t.text {
+		case "rules", "meta", "out", "raw", "GARBAGE":
+			return nativeVariable{t.text}, true
+		case "true", "false":
+			return nativeLiteral{t.text == "true"}, true
+		case "null":
+			return nativeLiteral{nil}, true
+		case "undefined":
+			return nativeLiteral{undefined}, true
+		}
+	case tokenPunct:
+		switch t.text {
+		case "(":
+			exp, ok := p.conditional()
+			return exp, ok && p.accept(tokenPunct, ")")
+		case "{":
+			return p.objectLiteral()
+		case "[":
+			return p.arrayLiteral()
+		}
+	}
+
+	return nil, false
+}
+
+// Parses an object literal after its {, such as {name: "latte", 'price': 4.5}
+func (p *nativeParser) objectLiteral() (nativeExpr, bool) {
+	var o nativeObjectLiteral
+
+	for !p.accept(tokenPunct, "}") {
+		if len(o.names) > 0 && !p.accept(tokenPunct, ",") {
+			return nil, false
+		}
+
+		t := p.peek()
+
+		switch {
+		case p.accept(tokenName, ""):
+			o.names = append(o.names, t.text)
+		case p.accept(tokenString, ""):
+			o.names = append(o.names, t.value.(string))
+		default:
+			return nil, false
+		}
+
+		if !p.accept(tokenPunct, ":") {
+			return nil, false
+		}
+
+		value, ok := p.conditional()
+
+		if !ok {
+			return nil, false
+		}
+
+		o.values = append(o.values, value)
+	}
+
+	return o, true
+}
+
+// Parses an array literal after its [, such as ["a", rules.b.out]
+func (p *nativeParser) arrayLiteral() (nativeExpr, bool) {
+	var a nativeArrayLiteral
+
+	for !p.accept(tokenPunct, "]") {
+		if len(a.elems) > 0 && !p.accept(tokenPunct, ",") {
+			return nil, false
+		}
+
+		elem, ok := p.conditional()
+
+		if !ok {
+			return nil, false
+		}
+
+		a.elems = append(a.elems, elem)
+	}
+
+	return a, true
+}
@@ -0,0 +1,199 @@
+package srgs
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+// Asserts that the instance of a match, evaluated in Go or with compiled tags, is the one running the script of the
+// whole match gives
+func assertMatchesScript(t *testing.T, p *SISRProcessor, msgAndArgs ...interface{}) {
+	wantOut, wantErr := p.run(context.Background(), "root.out")
+	out, err := p.GetInstance()
+
+	assert.Equal(t, wantErr == nil, err == nil, msgAndArgs...)
+	assert.Equal(t, wantOut, out, msgAndArgs...)
+
+	var wantValue interface{}
+	data, wantErr := p.run(context.Background(), "JSON.stringify(root.out === undefined ? null : root.out)")
+
+	if wantErr == nil {
+		wantErr = json.Unmarshal([]byte(data), &wantValue)
+	}
+
+	value, err := p.GetValue()
+
+	assert.Equal(t, wantErr == nil, err == nil, msgAndArgs...)
+	assert.Equal(t, wantValue, value, msgAndArgs...)
+}
+
+func TestEvaluateNative(t *testing.T) {
+	const (
+		script    = ""
+		semantics = "semantics/1.0"
+		literals  = "semantics/1.0-literals"
+	)
+
+	tests := []struct {
+		format string
+		tag    string
+		native bool
+	}{
+		{script, `out = "5"`, true},
+		{script, `out = 5;`, true},
+		{script, `out = 0.1 + 0.2`, true},
+		{script, `out = "" + 1e21 + " " + 1.5e-7 + " " + 123e-20 + " " + 0.000001 + " " + -2.50`, false},
+		{script, `out = "" + 1e21 + " " + 1.5e-7 + " " + 123e-20 + " " + 0.000001`, true},
+		{script, `out = "" + true + null + undefined`, true},
+		{script, `out = 1 + true + null`, true},
+		{script, `out = 1 + undefined`, true},
+		{script, `out = rules.word.raw + "!"`, true},
+		{script, `out = rules.word.out`, true},
+		{script, `out = raw`, true},
+		{script, "out = meta.current().text\nout += meta.current().score", true},
+		{script, `out = meta.word.text + meta.word.score`, true},
+		{script, `out = rules.missing || "none"`, true},
+		{script, `out = raw && "matched"`, true},
+		{script, `out = rules.word.out ? "yes" : rules.word.raw ? "no" : "never"`, true},
+		{script, `out = (rules.missing || {}).x`, true},
+		{script, `out = {a: 1, 'b c': [1, "x", null, undefined], d: {e: raw}}; out.f = out.d.e; out.d['g'] = 2`, true},
+		{script, "out = \"a\"; out += \"b\"\nout += 1;;", true},
+		{script, `out = {n: 1}; out.n += 2; out.s = out.n + "!"`, true},
+		{script, `out = [1, [2, 3], {}, null]`, true},
+		{script, `out = {}; out.x = undefined`, true},
+		{script, `out = 'it\'s é \x41\n"\q'`, true},
+		{script, `out = {}; out.self = out`, true},
+		{script, `out = {toString: 1}`, false},
+		{script, `out = rules.word.out.length`, false},
+		{script, `out = rules.word.toString`, false},
+		{script, `var x = 1; out = x`, false},
+		{script, `out = [1].concat([2])`, false},
+		{script, `out = {} + "x"`, false},
+		{script, `out = 1 - 1`, false},
+		{script, `out = rules.latest()`, false},
+		{script, `out = 010`, false},
+		{script, `out = 0x10`, false},
+		{script, `out == 1`, false},
+		{script, "// a comment\nout = 1", false},
+		{script, "out = 1 out = 2", false},
+		{script, `out = rules.word.out; out.x = 1`, false},
+		{semantics, `$ = $word + "!"`, true},
+		{semantics, `$.a = $$; $.b = meta.latest().text; $.c = meta.current()`, true},
+		{semantics, `out = typeof rules.latest`, false},
+		{semantics, ``, true},
+		{semantics, `$.x = undefined`, true},
+		{semantics, `$.latest = 1; out.current = 2`, true},
+		{semantics, `$.words = [$word]; $.words.push("dog")`, false},
+		{literals, ` The "Cat" `, true},
+		{literals, ``, true},
+	}
+
+	for _, test := range tests {
+		xml := `<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" root="main" tag-format="` + test.format + `">
+	<rule id="main" scope="public">
+		the <ruleref uri="#word" /> sat <tag>` + strings.NewReplacer("&", "&amp;", "<", "&lt;").Replace(test.tag) + `</tag>
+	</rule>
+
+	<rule id="word">cat</rule>
+</grammar>`
+
+		g := NewGrammar()
+		if !assert.Nil(t, g.LoadXml(xml), test.tag) {
+			continue
+		}
+
+		for name, engine := range scriptEngines {
+			p := NewSISRProcessor(engine)
+			if !assert.Nil(t, g.GetMatch("the cat sat", p), test.tag) {
+				continue
+			}
+
+			_, native := evaluateNative(p.events)
+			assert.Equal(t, test.native, native, "%s: %s", test.format, test.tag)
+
+			assertMatchesScript(t, p, "%s: %s: %s", name, test.format, test.tag)
+		}
+	}
+}
+
+// Every sentence of the grammars with tags has the same instance as running the script of the whole match gives
+func TestEvaluateNative_Grammars(t *testing.T) {
+	grammars := map[string]string{
+		"digits": digitsXml, "animal": animalXml, "city": cityXml, "garbage": garbageXml, "list": listXml,
+		"order": orderXml,
+	}
+
+	for grammarName, xml := range grammars {
+		g := NewGrammar()
+		g.Backend = BackendChart
+		if !assert.Nil(t, g.LoadXml(xml), grammarName) {
+			continue
+		}
+
+		sentences, err := g.Enumerate(GenerateOptions{MaxWords: 6, MaxSentences: 40})
+		if !assert.Nil(t, err, grammarName) {
+			continue
+		}
+
+		for _, sentence := range sentences {
+			p := new(SISRProcessor)
+			if assert.Nil(t, g.GetMatch(sentence.Text, p), sentence.Text) {
+				assertMatchesScript(t, p, "%s: %q", grammarName, sentence.Text)
+			}
+		}
+	}
+}
+
+func TestParseNativeScript(t *testing.T) {
+	assert := assert.New(t)
+
+	s := parseNativeScript(`out.a['b'] += rules.x.out || "y"`, sisrScript)
+	if assert.NotNil(s) && assert.Len(s.assignments, 1) {
+		a := s.assignments[0]
+		assert.Equal([]string{"a", "b"}, a.path)
+		assert.True(a.add)
+		assert.Equal(nativeBinary{"||",
+			nativeProperty{nativeProperty{nativeVariable{"rules"}, "x"}, "out"}, nativeLiteral{"y"}}, a.value)
+	}
+
+	// the shorthands of SISR 1.0 scripts are expanded
+	s = parseNativeScript(`$ = $$`, sisrSemantics)
+	if assert.NotNil(s) && assert.Len(s.assignments, 1) {
+		assert.Equal(nativeCall{"rules", "latest"}, s.assignments[0].value)
+	}
+
+	assert.NotNil(parseNativeScript(" \n ; ", sisrScript))
+	assert.Nil(parseNativeScript(`out = "unterminated`, sisrScript))
+	assert.Nil(parseNativeScript(`rules.x = 1`, sisrScript))
+	assert.Nil(parseNativeScript(`out = rules.x(1)`, sisrScript))
+	assert.Nil(parseNativeScript(`out = {a: 1,}`, sisrScript))
+	assert.Nil(parseNativeScript(`out = café`, sisrScript))
+}
+
+func TestJsNumberToString(t *testing.T) {
+	assert := assert.New(t)
+
+	for f, want := range map[float64]string{
+		0: "0", 1: "1", -2.5: "-2.5", 1e20: "100000000000000000000", 1e21: "1e+21", 0.000001: "0.000001",
+		1.5e-7: "1.5e-7", 123e-20: "1.23e-18", 1.7976931348623157e308: "1.7976931348623157e+308",
+	} {
+		assert.Equal(want, jsNumberToString(f))
+	}
+}
+
+func BenchmarkNativeDigitsInstance(b *testing.B) {
+	g := NewGrammar()
+	g.LoadXml(digitsXml)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		p := new(SISRProcessor)
+		g.GetMatch("one two three four five", p)
+		p.GetInstance()
+	}
+}
@@ -3,20 +3,44 @@ package srgs
 type Tag struct {
 	text string
 
-	match  string
-	called bool
+	// how the tag is interpreted, according to the tag-format of its grammar
+	format sisrFormat
+
+	// the tag parsed for the native evaluator, or nil if it needs a script engine
+	native *nativeScript
+
+	// the tag compiled for the script engines with VMs
+	otto, goja compiledTag
 }
 
 func NewTag(str string) *Tag {
-	return &Tag{text: str}
+	return newTag(str, sisrScript)
 }
 
-func (t *Tag) Match(str string, mode MatchMode) {
+// Returns a tag, parsed for the native evaluator. Scripts which need a script engine are compiled for the engine which
+// runs them, when they first run.
+func newTag(text string, format sisrFormat) *Tag {
+	return &Tag{text: text, format: format, native: parseNativeScript(text, format)}
+}
+
+// Implements Expansion NewMatcher method
+func (t *Tag) NewMatcher() Matcher {
+	return &tagMatcher{tag: t}
+}
+
+type tagMatcher struct {
+	tag *Tag
+
+	match  string
+	called bool
+}
+
+func (t *tagMatcher) Match(str string, mode MatchMode) {
 	t.match = str
 	t.called = false
 }
 
-func (t *Tag) Next() (string, error) {
+func (t *tagMatcher) Next() (string, error) {
 	if t.called == true {
 		return "", NoMatch
 	}
@@ -26,24 +50,13 @@ func (t *Tag) Next() (string, error) {
 	return t.match, nil
 }
 
-func (t *Tag) Scan(p Processor) {
-	p.AppendTag(`
-(function () {
-	var rules = scopes[scopes.length-1]['rules'];
-	var out = scopes[scopes.length-1]['out'];
-	var raw = scopes[scopes.length-1]['raw'];
-	var GARBAGE = scopes[scopes.length-1]['GARBAGE'];
-`)
-	p.AppendTag(t.text)
-
-	p.AppendTag(`
-	scopes[scopes.length-1]['out'] = out;
-})();`)
-}
-func (t *Tag) Copy(r RuleRefs) Expansion {
-	return &Tag{
-		text:   t.text,
-		match:  t.match,
-		called: t.called,
-	}
+func (t *tagMatcher) Score() float64 { return 1 }
+
+func (t *tagMatcher) Scan(p Processor) {
+	scanTag(p, t.tag)
+}
+
+// Scans the body of a tag, which is evaluated in the scope of the rule it is in
+func scanTag(p Processor, tag *Tag) {
+	scanEvent(p, sisrEvent{kind: eventTag, tag: tag})
 }
@@ -0,0 +1,867 @@
+package srgs
+
+import (
+	"context"
+	"errors"
+	"github.com/dop251/goja"
+	"github.com/robertkrimen/otto"
+	"strconv"
+	"sync"
+)
+
+// A script engine whose VMs run the compiled tags of a match one at a time, while the scope of each rule is kept in
+// Go. VMs are pooled, and reused by every match.
+type vmEngine interface {
+	ScriptEngine
+
+	// Compiles a tag for the engine, if it has not been already
+	compile(tag *Tag) (interface{}, error)
+
+	// Returns a VM from the pool, which is returned to it by release
+	acquire() tagVM
+	release(vm tagVM)
+
+	// Runs a script as Run does, in a new VM which stops when a limit is hit or the context is done
+	runScript(ctx context.Context, limits ScriptLimits, script, expression string) (string, error)
+}
+
+// A VM which runs compiled tags
+type tagVM interface {
+	// Limits the tags run until finish is called, which must be before the VM is released
+	start(ctx context.Context, limits ScriptLimits)
+	finish()
+
+	// Runs a tag in a scope, and updates the scope with the values it changed
+	runTag(tag *Tag, s *nativeScope) error
+
+	// Returns String(value) and JSON.stringify(value), where undefined is null
+	toString(value interface{}) (string, error)
+	toJSON(value interface{}) (string, error)
+}
+
+// The most functions of tags which a VM keeps. VMs are pooled and shared by every grammar, so the functions of the tags
+// of grammars which are no longer used would otherwise be kept for as long as the VM is.
+const maxVMTags = 1024
+
+// A tag compiled for one script engine, once
+type compiledTag struct {
+	once    sync.Once
+	program interface{}
+	err     error
+}
+
+func (c *compiledTag) get(compile func() (interface{}, error)) (interface{}, error) {
+	c.once.Do(func() { c.program, c.err = compile() })
+	return c.program, c.err
+}
+
+// The name of the object which the function of a compiled tag stores its rule variable in, unless the tag returns early
+const tagResult = "__sisrResult"
+
+// Returns a tag script as a function, which is called with the variables of its scope and counts its steps
+func tagFunction(tag *Tag) (string, error) {
+	text := tag.text
+
+	if tag.format == sisrSemantics {
+		text = expandShorthands(text)
+	}
+
+	return countSteps("(function (rules, meta, out, raw, GARBAGE, " + tagResult + ") {\n" +
+		text + "\n;" + tagResult + ".out = out;\n})")
+}
+
+// Helpers for the VMs which run compiled tags, kept out of the global scope. reset deletes the global variables which
+// tags have created, so that they are not seen by the next match run in the VM.
+//
+// The runtime also locks down the global environment: code cannot be made from strings, since its steps would not be
+// counted, and the built-in objects, their prototypes and the global variables which hold them cannot be changed.
+const tagRuntime = `(function (global) {
+	var noEval = function () { throw new EvalError('code cannot be made from strings'); };
+	noEval.prototype = Function.prototype;
+
+	var generators = ['(function* () {})', '(async function () {})', '(async function* () {})'].map(function (source) {
+		try { return Object.getPrototypeOf(global.eval(source)); } catch (e) { return null; }
+	});
+
+	[Function.prototype].concat(generators).forEach(function (proto) {
+		if (proto) Object.defineProperty(proto, 'constructor', {value: noEval});
+	});
+
+	global.eval = global.Function = noEval;
+
+	// assigning a property which Object.prototype has, such as toString, gives an object its own property as it did
+	// before the prototype was frozen
+	Object.getOwnPropertyNames(Object.prototype).forEach(function (name) {
+		var desc = Object.getOwnPropertyDescriptor(Object.prototype, name);
+		if (!('value' in desc) || !desc.configurable) return;
+
+		Object.defineProperty(Object.prototype, name, {
+			get: function () { return desc.value; },
+			set: function (value) {
+				if (this === Object.prototype) return;
+				Object.defineProperty(this, name, {value: value, writable: true, enumerable: true, configurable: true});
+			},
+			enumerable: desc.enumerable
+		});
+	});
+
+	Object.getOwnPropertyNames(global).forEach(function (name) {
+		var desc = Object.getOwnPropertyDescriptor(global, name);
+		if (!('value' in desc)) return;
+
+		var value = desc.value;
+		if (value !== global && value !== null && (typeof value === 'object' || typeof value === 'function')) {
+			Object.freeze(value);
+			if (value.prototype) Object.freeze(value.prototype);
+		}
+
+		Object.defineProperty(global, name, {writable: false, configurable: false});
+	});
+
+	var globals = {};
+	Object.getOwnPropertyNames(global).forEach(function (name) { globals[name] = true; });
+
+	return {
+		object: function () { return {}; },
+		array: function () { return []; },
+		kind: function (value) {
+			var proto = Object.getPrototypeOf(value);
+			if (proto === Object.prototype) return 'object';
+			if (proto === Array.prototype && Array.isArray(value)) return 'array';
+			return '';
+		},
+		functions: function (vars, meta, latest, raw) {
+			if (vars) {
+				Object.defineProperty(vars, 'latest', {value: function () { return vars[latest]; }});
+			}
+			Object.defineProperty(meta, 'latest', {value: function () { return meta[latest]; }});
+			Object.defineProperty(meta, 'current', {value: function () {
+				return {'text': raw === undefined ? '' : raw, 'score': 1};
+			}});
+		},
+		string: function (value) { return String(value); },
+		json: function (value) { return JSON.stringify(value === undefined ? null : value); },
+		reset: function () {
+			Object.getOwnPropertyNames(global).forEach(function (name) {
+				if (!globals[name]) delete global[name];
+			});
+		}
+	};
+})(this)`
+
+// A value of a VM which is not plain data, such as a function or a Date. It is only meaningful to the VM it came from.
+type jsForeign struct {
+	value interface{}
+}
+
+// Returns the rules variable of a tag, and the SISR 1.0 rule variables if they are what rules is
+func scopeArgs(tag *Tag, s *nativeScope) (rules, vars *jsObject) {
+	if tag.format == sisrSemantics {
+		return s.vars, s.vars
+	}
+
+	return s.rules, nil
+}
+
+var (
+	ottoCompiler     *otto.Otto
+	ottoCompilerOnce sync.Once
+	ottoRuntime      *otto.Script
+	ottoVMs          = sync.Pool{New: func() interface{} { return newOttoVM() }}
+)
+
+// Implements vmEngine compile method
+func (OttoEngine) compile(tag *Tag) (interface{}, error) {
+	return tag.otto.get(func() (interface{}, error) {
+		ottoCompilerOnce.Do(initOttoCompiler)
+		script, err := tagFunction(tag)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return ottoCompiler.Compile("", script)
+	})
+}
+
+func initOttoCompiler() {
+	ottoCompiler = otto.New()
+
+	var err error
+
+	if ottoRuntime, err = ottoCompiler.Compile("", tagRuntime); err != nil {
+		panic(err)
+	}
+}
+
+// Implements vmEngine acquire method
+func (OttoEngine) acquire() tagVM {
+	return ottoVMs.Get().(*ottoVM)
+}
+
+// Implements vmEngine release method
+func (OttoEngine) release(vm tagVM) {
+	v := vm.(*ottoVM)
+	v.helpers["reset"].Call(otto.UndefinedValue())
+	ottoVMs.Put(v)
+}
+
+type ottoVM struct {
+	vm      *otto.Otto
+	helpers map[string]otto.Value
+	limiter scriptLimiter
+
+	// the functions of the tags which have run in the VM, up to maxVMTags of them
+	tags map[*Tag]otto.Value
+}
+
+func newOttoVM() *ottoVM {
+	ottoCompilerOnce.Do(initOttoCompiler)
+
+	v := &ottoVM{vm: otto.New(), helpers: make(map[string]otto.Value), tags: make(map[*Tag]otto.Value)}
+	v.vm.Interrupt = make(chan func(), 1)
+
+	// a Go function which panics stops the script, unless the script catches the panic as an exception. Once a limit
+	// is hit every step panics, so a script which catches it does not run for long.
+	v.vm.Set(stepFunction, func(otto.FunctionCall) otto.Value {
+		if err := v.limiter.step(); err != nil {
+			panic(err)
+		}
+
+		return otto.UndefinedValue()
+	})
+
+	runtime, err := v.vm.Run(ottoRuntime)
+
+	if err != nil {
+		panic(err)
+	}
+
+	for _, name := range runtime.Object().Keys() {
+		v.helpers[name], _ = runtime.Object().Get(name)
+	}
+
+	return v
+}
+
+// Implements vmEngine runScript method
+func (OttoEngine) runScript(ctx context.Context, limits ScriptLimits, script, expression string) (_ string, err error) {
+	v := newOttoVM()
+	v.start(ctx, limits)
+	defer v.finish()
+	defer recoverOttoLimit(&err)
+
+	counted, err := countSteps(script)
+
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := v.vm.Run(counted); err != nil {
+		return "", v.check(err)
+	}
+
+	// the value is converted in the VM, so that values which cannot be strings throw errors as they do in goja
+	value, err := v.vm.Run("String(" + expression + ")")
+
+	if err = v.check(err); err != nil {
+		return "", err
+	}
+
+	return value.String(), nil
+}
+
+// Implements tagVM start method. The steps check the context, and the VM's interrupt channel is a backstop for
+// scripts which are between steps. otto lets scripts catch a panic of the interrupt as an exception, so the interrupt
+// queues itself again each time it runs, and every statement after it panics too.
+func (v *ottoVM) start(ctx context.Context, limits ScriptLimits) {
+	v.limiter.start(ctx, limits, func(err error) {
+		var interrupt func()
+		interrupt = func() {
+			select {
+			case v.vm.Interrupt <- interrupt:
+			default:
+			}
+
+			panic(err)
+		}
+
+		select {
+		case v.vm.Interrupt <- interrupt:
+		default:
+		}
+	})
+}
+
+// Implements tagVM finish method
+func (v *ottoVM) finish() {
+	v.limiter.finish()
+
+	// the VM is reused, so an interrupt which is still queued must not stop the next match
+	select {
+	case <-v.vm.Interrupt:
+	default:
+	}
+}
+
+// Returns the error of the limit which stopped a script, which the script may have caught, or otherwise the error of
+// the script
+func (v *ottoVM) check(err error) error {
+	if limit := v.limiter.err(); limit != nil {
+		return limit
+	}
+
+	return err
+}
+
+// Returns the error of a limit which stopped a script, which otto gives as a panic
+func recoverOttoLimit(err *error) {
+	if caught := recover(); caught != nil {
+		limit, ok := caught.(*LimitError)
+
+		if !ok {
+			panic(caught)
+		}
+
+		*err = limit
+	}
+}
+
+// Implements tagVM runTag method
+func (v *ottoVM) runTag(tag *Tag, s *nativeScope) (err error) {
+	defer recoverOttoLimit(&err)
+
+	fn, ok := v.tags[tag]
+
+	if !ok {
+		program, err := OttoEngine{}.compile(tag)
+
+		if err != nil {
+			return err
+		}
+
+		if fn, err = v.vm.Run(program); err != nil {
+			return err
+		}
+
+		if len(v.tags) >= maxVMTags {
+			v.tags = make(map[*Tag]otto.Value)
+		}
+
+		v.tags[tag] = fn
+	}
+
+	c := newOttoConverter(v)
+	rules, vars := scopeArgs(tag, s)
+
+	args := make([]interface{}, 6)
+	args[0] = c.toJS(rules)
+	args[1] = c.toJS(s.meta)
+	args[2] = c.toJS(s.out)
+	args[3] = c.toJS(s.raw)
+	args[4] = c.toJS(s.garbage)
+	args[5], _ = v.helpers["object"].Call(otto.UndefinedValue())
+
+	varsArg := otto.UndefinedValue()
+	if vars != nil {
+		varsArg = args[0].(otto.Value)
+	}
+
+	_, err = v.helpers["functions"].Call(otto.UndefinedValue(), varsArg, args[1], c.toJS(s.latest), args[3])
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := fn.Call(otto.UndefinedValue(), args...); v.check(err) != nil {
+		return v.check(err)
+	}
+
+	// objects the tag changed are updated in place, so that other references to them see the changes
+	for i := 0; i < 3; i++ {
+		c.fromJS(args[i].(otto.Value))
+	}
+
+	if result := args[5].(otto.Value).Object(); len(result.Keys()) > 0 {
+		out, _ := result.Get("out")
+		s.out = c.fromJS(out)
+	}
+
+	return nil
+}
+
+// Implements tagVM toString method
+func (v *ottoVM) toString(value interface{}) (string, error) {
+	return v.call("string", value)
+}
+
+// Implements tagVM toJSON method
+func (v *ottoVM) toJSON(value interface{}) (string, error) {
+	return v.call("json", value)
+}
+
+func (v *ottoVM) call(helper string, value interface{}) (_ string, err error) {
+	defer recoverOttoLimit(&err)
+
+	result, err := v.helpers[helper].Call(otto.UndefinedValue(), newOttoConverter(v).toJS(value))
+
+	if err = v.check(err); err != nil {
+		return "", err
+	}
+
+	return result.String(), nil
+}
+
+// Converts values between Go and an otto VM, keeping the identity of objects
+type ottoConverter struct {
+	v *ottoVM
+
+	// the VM objects of Go objects, and the Go objects of VM objects
+	toVM   map[interface{}]otto.Value
+	fromVM map[otto.Value]interface{}
+
+	// the VM objects which have been converted back to Go
+	updated map[otto.Value]bool
+}
+
+func newOttoConverter(v *ottoVM) *ottoConverter {
+	return &ottoConverter{
+		v:       v,
+		toVM:    make(map[interface{}]otto.Value),
+		fromVM:  make(map[otto.Value]interface{}),
+		updated: make(map[otto.Value]bool),
+	}
+}
+
+func (c *ottoConverter) toJS(value interface{}) otto.Value {
+	switch v := value.(type) {
+	case jsUndefined:
+		return otto.UndefinedValue()
+	case nil:
+		return otto.NullValue()
+	case jsForeign:
+		return v.value.(otto.Value)
+	case *jsObject, *jsArray:
+		if js, ok := c.toVM[value]; ok {
+			return js
+		}
+	default:
+		js, _ := c.v.vm.ToValue(value)
+		return js
+	}
+
+	helper := "object"
+	if _, ok := value.(*jsArray); ok {
+		helper = "array"
+	}
+
+	js, _ := c.v.helpers[helper].Call(otto.UndefinedValue())
+	c.toVM[value], c.fromVM[js] = js, value
+
+	switch v := value.(type) {
+	case *jsObject:
+		for name, prop := range v.props {
+			js.Object().Set(name, c.toJS(prop))
+		}
+	case *jsArray:
+		for i, elem := range v.elems {
+			js.Object().Set(strconv.Itoa(i), c.toJS(elem))
+		}
+	}
+
+	return js
+}
+
+func (c *ottoConverter) fromJS(js otto.Value) interface{} {
+	switch {
+	case js.IsUndefined():
+		return undefined
+	case js.IsNull():
+		return nil
+	case js.IsBoolean():
+		b, _ := js.ToBoolean()
+		return b
+	case js.IsNumber():
+		f, _ := js.ToFloat()
+		return f
+	case js.IsString():
+		return js.String()
+	case !js.IsObject():
+		return jsForeign{js}
+	}
+
+	value, ok := c.fromVM[js]
+
+	if ok && c.updated[js] {
+		return value
+	}
+
+	if !ok {
+		kind, _ := c.v.helpers["kind"].Call(otto.UndefinedValue(), js)
+
+		switch kind.String() {
+		case "object":
+			value = newJsObject()
+		case "array":
+			value = new(jsArray)
+		default:
+			return jsForeign{js}
+		}
+
+		c.fromVM[js] = value
+	}
+
+	c.updated[js] = true
+	o := js.Object()
+
+	switch v := value.(type) {
+	case *jsObject:
+		props := make(map[string]interface{})
+
+		for _, name := range o.Keys() {
+			prop, _ := o.Get(name)
+			props[name] = c.fromJS(prop)
+		}
+
+		v.props = props
+	case *jsArray:
+		length, _ := o.Get("length")
+		n, _ := length.ToInteger()
+		elems := make([]interface{}, n)
+
+		for i := range elems {
+			elem, _ := o.Get(strconv.Itoa(i))
+			elems[i] = c.fromJS(elem)
+		}
+
+		v.elems = elems
+	}
+
+	return value
+}
+
+var (
+	gojaRuntime = goja.MustCompile("", tagRuntime, false)
+	gojaVMs     = sync.Pool{New: func() interface{} { return newGojaVM() }}
+)
+
+// Implements vmEngine compile method
+func (GojaEngine) compile(tag *Tag) (interface{}, error) {
+	return tag.goja.get(func() (interface{}, error) {
+		script, err := tagFunction(tag)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return goja.Compile("", script, false)
+	})
+}
+
+// Implements vmEngine acquire method
+func (GojaEngine) acquire() tagVM {
+	return gojaVMs.Get().(*gojaVM)
+}
+
+// Implements vmEngine release method
+func (GojaEngine) release(vm tagVM) {
+	v := vm.(*gojaVM)
+	v.helpers["reset"](goja.Undefined())
+	gojaVMs.Put(v)
+}
+
+type gojaVM struct {
+	vm      *goja.Runtime
+	helpers map[string]goja.Callable
+	limiter scriptLimiter
+
+	// the functions of the tags which have run in the VM, up to maxVMTags of them
+	tags map[*Tag]goja.Callable
+}
+
+func newGojaVM() *gojaVM {
+	v := &gojaVM{vm: goja.New(), helpers: make(map[string]goja.Callable), tags: make(map[*Tag]goja.Callable)}
+
+	v.vm.Set(stepFunction, func(goja.FunctionCall) goja.Value {
+		if err := v.limiter.step(); err != nil {
+			v.vm.Interrupt(err)
+		}
+
+		return goja.Undefined()
+	})
+
+	runtime, err := v.vm.RunProgram(gojaRuntime)
+
+	if err != nil {
+		panic(err)
+	}
+
+	o := runtime.ToObject(v.vm)
+
+	for _, name := range o.Keys() {
+		v.helpers[name], _ = goja.AssertFunction(o.Get(name))
+	}
+
+	return v
+}
+
+// Implements vmEngine runScript method
+func (GojaEngine) runScript(ctx context.Context, limits ScriptLimits, script, expression string) (string, error) {
+	v := newGojaVM()
+	v.start(ctx, limits)
+	defer v.finish()
+
+	counted, err := countSteps(script)
+
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := v.vm.RunString(counted); err != nil {
+		return "", gojaError(err)
+	}
+
+	// converting the value in the VM throws for values which cannot be strings, where value.String() would panic
+	value, err := v.vm.RunString("String(" + expression + ")")
+
+	if err != nil {
+		return "", gojaError(err)
+	}
+
+	return value.String(), nil
+}
+
+// Implements tagVM start method
+func (v *gojaVM) start(ctx context.Context, limits ScriptLimits) {
+	v.limiter.start(ctx, limits, func(err error) { v.vm.Interrupt(err) })
+}
+
+// Implements tagVM finish method
+func (v *gojaVM) finish() {
+	v.limiter.finish()
+	v.vm.ClearInterrupt()
+}
+
+// Returns the error of a limit which interrupted a script, or otherwise the error as it is. An interrupted VM stays
+// interrupted until it is finished, so every script it runs after the limit is hit gives the same error.
+func gojaError(err error) error {
+	var interrupted *goja.InterruptedError
+
+	if errors.As(err, &interrupted) {
+		if limit, ok := interrupted.Value().(*LimitError); ok {
+			return limit
+		}
+	}
+
+	return err
+}
+
+// Implements tagVM runTag method
+func (v *gojaVM) runTag(tag *Tag, s *nativeScope) error {
+	fn, ok := v.tags[tag]
+
+	if !ok {
+		program, err := GojaEngine{}.compile(tag)
+
+		if err != nil {
+			return err
+		}
+
+		value, err := v.vm.RunProgram(program.(*goja.Program))
+
+		if err != nil {
+			return gojaError(err)
+		}
+
+		fn, _ = goja.AssertFunction(value)
+
+		if len(v.tags) >= maxVMTags {
+			v.tags = make(map[*Tag]goja.Callable)
+		}
+
+		v.tags[tag] = fn
+	}
+
+	c := newGojaConverter(v)
+	rules, vars := scopeArgs(tag, s)
+	result := v.vm.NewObject()
+
+	args := []goja.Value{c.toJS(rules), c.toJS(s.meta), c.toJS(s.out), c.toJS(s.raw), c.toJS(s.garbage), result}
+
+	varsArg := goja.Undefined()
+	if vars != nil {
+		varsArg = args[0]
+	}
+
+	if _, err := v.helpers["functions"](goja.Undefined(), varsArg, args[1], c.toJS(s.latest), args[3]); err != nil {
+		return gojaError(err)
+	}
+
+	if _, err := fn(goja.Undefined(), args...); err != nil {
+		return gojaError(err)
+	}
+
+	// objects the tag changed are updated in place, so that other references to them see the changes
+	for _, arg := range args[:3] {
+		c.fromJS(arg)
+	}
+
+	if out := result.Get("out"); out != nil {
+		s.out = c.fromJS(out)
+	}
+
+	// the values are incomplete if the VM was interrupted while they were converted
+	return v.limiter.err()
+}
+
+// Implements tagVM toString method
+func (v *gojaVM) toString(value interface{}) (string, error) {
+	return v.call("string", value)
+}
+
+// Implements tagVM toJSON method
+func (v *gojaVM) toJSON(value interface{}) (string, error) {
+	return v.call("json", value)
+}
+
+func (v *gojaVM) call(helper string, value interface{}) (string, error) {
+	result, err := v.helpers[helper](goja.Undefined(), newGojaConverter(v).toJS(value))
+
+	if err != nil {
+		return "", gojaError(err)
+	}
+
+	return result.String(), nil
+}
+
+// Converts values between Go and a goja VM, keeping the identity of objects
+type gojaConverter struct {
+	v *gojaVM
+
+	// the VM objects of Go objects, and the Go objects of VM objects
+	toVM   map[interface{}]*goja.Object
+	fromVM map[*goja.Object]interface{}
+
+	// the VM objects which have been converted back to Go
+	updated map[*goja.Object]bool
+}
+
+func newGojaConverter(v *gojaVM) *gojaConverter {
+	return &gojaConverter{
+		v:       v,
+		toVM:    make(map[interface{}]*goja.Object),
+		fromVM:  make(map[*goja.Object]interface{}),
+		updated: make(map[*goja.Object]bool),
+	}
+}
+
+func (c *gojaConverter) toJS(value interface{}) goja.Value {
+	switch v := value.(type) {
+	case jsUndefined:
+		return goja.Undefined()
+	case nil:
+		return goja.Null()
+	case jsForeign:
+		return v.value.(goja.Value)
+	case *jsObject, *jsArray:
+		if js, ok := c.toVM[value]; ok {
+			return js
+		}
+	default:
+		return c.v.vm.ToValue(value)
+	}
+
+	var js *goja.Object
+
+	switch v := value.(type) {
+	case *jsObject:
+		js = c.v.vm.NewObject()
+		c.toVM[value], c.fromVM[js] = js, value
+
+		for name, prop := range v.props {
+			js.Set(name, c.toJS(prop))
+		}
+	case *jsArray:
+		js = c.v.vm.NewArray()
+		c.toVM[value], c.fromVM[js] = js, value
+
+		for i, elem := range v.elems {
+			js.Set(strconv.Itoa(i), c.toJS(elem))
+		}
+	}
+
+	return js
+}
+
+func (c *gojaConverter) fromJS(js goja.Value) interface{} {
+	switch {
+	case js == nil:
+		// the holes of a sparse array have no value at all
+		return undefined
+	case goja.IsUndefined(js):
+		return undefined
+	case goja.IsNull(js):
+		return nil
+	}
+
+	o, isObject := js.(*goja.Object)
+
+	if !isObject {
+		switch v := js.Export().(type) {
+		case bool, float64, string:
+			return v
+		case int64:
+			return float64(v)
+		}
+
+		return jsForeign{js}
+	}
+
+	value, ok := c.fromVM[o]
+
+	if ok && c.updated[o] {
+		return value
+	}
+
+	if !ok {
+		kind, err := c.v.helpers["kind"](goja.Undefined(), o)
+
+		if err != nil {
+			return jsForeign{js}
+		}
+
+		switch kind.String() {
+		case "object":
+			value = newJsObject()
+		case "array":
+			value = new(jsArray)
+		default:
+			return jsForeign{js}
+		}
+
+		c.fromVM[o] = value
+	}
+
+	c.updated[o] = true
+
+	switch v := value.(type) {
+	case *jsObject:
+		props := make(map[string]interface{})
+
+		for _, name := range o.Keys() {
+			props[name] = c.fromJS(o.Get(name))
+		}
+
+		v.props = props
+	case *jsArray:
+		elems := make([]interface{}, o.Get("length").ToInteger())
+
+		for i := range elems {
+			elems[i] = c.fromJS(o.Get(strconv.Itoa(i)))
+		}
+
+		v.elems = elems
+	}
+
+	return value
+}
@@ -0,0 +1,199 @@
+package srgs
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func TestTagVM_CompiledLazily(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGrammar()
+	if !assert.Nil(g.LoadXml(orderXml)) {
+		return
+	}
+
+	var scripts, native int
+
+	for _, tag := range findTags(g.rules["order"]) {
+		if tag.native == nil {
+			scripts++
+		} else {
+			native++
+		}
+
+		assert.Nil(tag.otto.program, tag.text)
+		assert.Nil(tag.goja.program, tag.text)
+	}
+
+	assert.Equal(1, scripts)
+	assert.Equal(3, native)
+
+	// a tag is only compiled for the engine which runs it, including a tag evaluated in Go which turns out to need
+	// the script engine
+	p := NewSISRProcessor(GojaEngine{})
+	if assert.Nil(g.GetMatch("latte to go", p)) {
+		_, err := p.GetInstance()
+		assert.Nil(err)
+
+		tags := findTags(g.rules["order"])
+		assert.Equal("out.count = out.drinks.length; out.size = null;", tags[3].text)
+		assert.NotNil(tags[3].goja.program)
+		assert.Nil(tags[3].otto.program)
+	}
+
+	// a script which does not compile fails the match which runs it
+	g = NewGrammar()
+	if assert.Nil(g.LoadXml(`<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" root="main">
+	<rule id="main">word <tag>out = ;</tag></rule>
+</grammar>`)) {
+		for name, engine := range scriptEngines {
+			p := NewSISRProcessor(engine)
+			if assert.Nil(g.GetMatch("word", p), name) {
+				_, err := p.GetInstance()
+				assert.NotNil(err, name)
+			}
+		}
+	}
+}
+
+func findTags(exp Expansion) []*Tag {
+	switch e := exp.(type) {
+	case *Tag:
+		return []*Tag{e}
+	case *Sequence:
+		var tags []*Tag
+
+		for _, child := range e.exps {
+			tags = append(tags, findTags(child)...)
+		}
+
+		return tags
+	case *Alternative:
+		var tags []*Tag
+
+		for _, child := range e.items {
+			tags = append(tags, findTags(child)...)
+		}
+
+		return tags
+	case *Item:
+		return findTags(e.child)
+	}
+
+	return nil
+}
+
+// Objects keep their identity as they pass between tags evaluated in Go and tags run in a VM
+func TestTagVM_Identity(t *testing.T) {
+	xml := `<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" root="main" tag-format="semantics/1.0">
+	<rule id="main" scope="public">
+		the <ruleref uri="#word" />
+		<tag>out = {a: {n: 1}, word: $word}; out.b = out.a;</tag>
+		<tag>out.a.n = 2; out.list = [out.a]; out.list.push(1); $word.seen = true;</tag>
+		<tag>out.m = out.b.n; out.c = $word;</tag>
+		<tag>out.same = out.list[0] === out.b &amp;&amp; out.word === out.c &amp;&amp; out.c.seen;</tag>
+	</rule>
+
+	<rule id="word">cat <tag>out.name = "cat"</tag></rule>
+</grammar>`
+
+	g := NewGrammar()
+	if !assert.Nil(t, g.LoadXml(xml)) {
+		return
+	}
+
+	assertInstances(t, g, map[string]string{
+		"the cat": `{"a":{"n":2},"b":{"n":2},"c":{"name":"cat","seen":true},"list":[{"n":2},1],"m":2,"same":true,` +
+			`"word":{"name":"cat","seen":true}}`,
+	})
+}
+
+func TestTagVM_Values(t *testing.T) {
+	xml := `<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" root="main">
+	<rule id="main" scope="public">
+		<ruleref uri="#word" />
+		<tag>out = {when: new Date(0), f: function () { return rules.word.out; }, word: rules.word};</tag>
+		<tag>out.g = out.f() + "!"; out.raw = raw; out.meta = meta.current();</tag>
+		<tag>out.early = true; return; out.never = true;</tag>
+	</rule>
+
+	<rule id="word"><one-of><item>cat <tag>out = "C";</tag></item><item>dog</item></one-of></rule>
+</grammar>`
+
+	g := NewGrammar()
+	if !assert.Nil(t, g.LoadXml(xml)) {
+		return
+	}
+
+	assertInstances(t, g, map[string]string{
+		"cat": `{"early":true,"g":"C!","meta":{"score":1,"text":"cat"},"raw":"cat",` +
+			`"when":"1970-01-01T00:00:00.000Z","word":{"out":"C","raw":"cat"}}`,
+		"dog": `{"early":true,"g":"undefined!","meta":{"score":1,"text":"dog"},"raw":"dog",` +
+			`"when":"1970-01-01T00:00:00.000Z","word":{"raw":"dog"}}`,
+	})
+}
+
+// The global variables a tag creates are not seen by the next match, though the match runs in the same VM
+func TestTagVM_Globals(t *testing.T) {
+	xml := `<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" root="main">
+	<rule id="main">word <tag>count = (typeof count === "undefined" ? 0 : count) + 1; out = count;</tag></rule>
+</grammar>`
+
+	g := NewGrammar()
+	if !assert.Nil(t, g.LoadXml(xml)) {
+		return
+	}
+
+	for name, engine := range scriptEngines {
+		for i := 0; i < 3; i++ {
+			p := NewSISRProcessor(engine)
+			if assert.Nil(t, g.GetMatch("word", p), name) {
+				out, err := p.GetInstance()
+				assert.Nil(t, err, name)
+				assert.Equal(t, "1", out, name)
+			}
+		}
+	}
+}
+
+// A tag evaluated in Go which turns out to need the script engine runs in a VM from the scope it started with
+func TestTagVM_NativeFallback(t *testing.T) {
+	xml := `<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" root="main">
+	<rule id="main">
+		word <tag>out = {s: "ab"};</tag>
+		<tag>out.n = 1; out.s += "c"; out = out.s + "!"; out = out.length;</tag>
+	</rule>
+</grammar>`
+
+	g := NewGrammar()
+	if !assert.Nil(t, g.LoadXml(xml)) {
+		return
+	}
+
+	assertInstances(t, g, map[string]string{"word": `4`})
+}
+
+// A VM keeps the functions of a bounded number of tags, since it outlives the grammars whose tags it runs
+func TestTagVM_Bounded(t *testing.T) {
+	assert := assert.New(t)
+
+	otto, goja := newOttoVM(), newGojaVM()
+
+	for i := 0; i < maxVMTags+10; i++ {
+		tag := newTag("out = "+strconv.Itoa(i)+";", sisrScript)
+
+		assert.Nil(otto.runTag(tag, newNativeScope()))
+		assert.Nil(goja.runTag(tag, newNativeScope()))
+	}
+
+	assert.LessOrEqual(len(otto.tags), maxVMTags)
+	assert.LessOrEqual(len(goja.tags), maxVMTags)
+	assert.NotEmpty(goja.tags)
+}
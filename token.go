@@ -2,33 +2,33 @@ package srgs
 
 type Token struct {
 	token string
+}
 
-	str  string
-	mode MatchMode
-
-	called bool
+func NewToken(str string) *Token {
+	return &Token{token: str}
 }
 
-func (t *Token) Copy(r RuleRefs) Expansion {
-	return &Token{
-		token:  t.token,
-		str:    t.str,
-		mode:   t.mode,
-		called: t.called,
-	}
+// Implements Expansion NewMatcher method
+func (t *Token) NewMatcher() Matcher {
+	return &tokenMatcher{token: t.token}
 }
 
-func NewToken(str string) *Token {
-	return &Token{token: str}
+type tokenMatcher struct {
+	token string
+
+	str  string
+	mode MatchMode
+
+	called bool
 }
 
-func (t *Token) Match(str string, mode MatchMode) {
+func (t *tokenMatcher) Match(str string, mode MatchMode) {
 	t.str = str
 	t.mode = mode
 	t.called = false
 }
 
-func (t *Token) Next() (string, error) {
+func (t *tokenMatcher) Next() (string, error) {
 	if t.called {
 		return "", NoMatch
 	}
@@ -77,7 +77,9 @@ func (t *Token) Next() (string, error) {
 	return "", NoMatch
 }
 
-func (t *Token) Scan(p Processor) {
+func (t *tokenMatcher) Scan(p Processor) {
 	p.AppendString(t.token)
 
 }
+
+func (t *tokenMatcher) Score() float64 { return 1 }
@@ -8,7 +8,7 @@ import (
 func TestToken_MatchPrefixMode(t *testing.T) {
 	assert := assert.New(t)
 
-	tok := Token{token: "my name is"}
+	tok := NewToken("my name is").NewMatcher()
 
 	// Test covered utterance
 	tok.Match("my name is rob", ModePrefix)
@@ -38,7 +38,7 @@ func TestToken_MatchPrefixMode(t *testing.T) {
 func TestToken_MatchExactMode(t *testing.T) {
 	assert := assert.New(t)
 
-	tok := Token{token: "my name is"}
+	tok := NewToken("my name is").NewMatcher()
 
 	// Test covered utterance
 	tok.Match("my name is rob", ModeExact)
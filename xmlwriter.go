@@ -0,0 +1,285 @@
+package srgs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Returns the grammar as a normalized SRGS XML document, which loads back into an equivalent grammar. Rules are
+// written in the order they were defined, and adjacent tokens are joined into one. Rules of other grammar documents
+// are referenced by the uri they were loaded from, so the document needs the same Resolver to be loaded.
+func (g *Grammar) WriteXml() string {
+	w := new(xmlWriter)
+
+	w.line(`<?xml version="1.0" encoding="UTF-8" ?>`)
+
+	attrs := []string{`xmlns="http://www.w3.org/2001/06/grammar"`, `version="1.0"`}
+
+	if g.lang != "" {
+		attrs = append(attrs, xmlAttr("xml:lang", g.lang))
+	}
+
+	if g.base != "" && g.base != g.uri {
+		attrs = append(attrs, xmlAttr("xml:base", g.base))
+	}
+
+	if g.Root != nil {
+		attrs = append(attrs, xmlAttr("root", g.Root.ruleId))
+	}
+
+	if g.mode != "" {
+		attrs = append(attrs, xmlAttr("mode", g.mode))
+	}
+
+	if g.tagFormat != "" {
+		attrs = append(attrs, xmlAttr("tag-format", g.tagFormat))
+	}
+
+	w.open("grammar", attrs...)
+
+	for i, id := range g.ruleOrder {
+		if i > 0 {
+			w.b.WriteString("\n")
+		}
+
+		attrs := []string{xmlAttr("id", id)}
+
+		if g.scopes[id] == ScopePublic {
+			attrs = append(attrs, xmlAttr("scope", string(ScopePublic)))
+		}
+
+		w.element("rule", g.rules[id], attrs...)
+	}
+
+	w.close("grammar")
+
+	return w.b.String()
+}
+
+// Writes an indented XML document, one element per line
+type xmlWriter struct {
+	b     strings.Builder
+	depth int
+}
+
+func (w *xmlWriter) line(str string) {
+	w.b.WriteString(strings.Repeat("\t", w.depth))
+	w.b.WriteString(str)
+	w.b.WriteString("\n")
+}
+
+func (w *xmlWriter) open(tag string, attrs ...string) {
+	w.line("<" + strings.Join(append([]string{tag}, attrs...), " ") + ">")
+	w.depth++
+}
+
+func (w *xmlWriter) close(tag string) {
+	w.depth--
+	w.line("</" + tag + ">")
+}
+
+// Writes an element containing an expansion. Elements which only contain text are written on a single line.
+func (w *xmlWriter) element(tag string, exp Expansion, attrs ...string) {
+	start := strings.Join(append([]string{tag}, attrs...), " ")
+	exps := flattenSequence(exp)
+
+	if len(exps) == 0 {
+		w.line("<" + start + " />")
+		return
+	}
+
+	if text, ok := tokenText(exps); ok {
+		w.line("<" + start + ">" + xmlEscaper.Replace(text) + "</" + tag + ">")
+		return
+	}
+
+	w.open(start)
+	w.expansions(exps)
+	w.close(tag)
+}
+
+// Writes a sequence of expansions, joining adjacent tokens into one line of text
+func (w *xmlWriter) expansions(exps []Expansion) {
+	for i := 0; i < len(exps); i++ {
+		var words []string
+
+		for ; i < len(exps); i++ {
+			t, ok := exps[i].(*Token)
+
+			if !ok {
+				break
+			}
+
+			if t.token != "" {
+				words = append(words, t.token)
+			}
+		}
+
+		if len(words) > 0 {
+			w.line(xmlEscaper.Replace(strings.Join(words, " ")))
+		}
+
+		if i < len(exps) {
+			w.expansion(exps[i])
+		}
+	}
+}
+
+func (w *xmlWriter) expansion(exp Expansion) {
+	switch e := exp.(type) {
+	case *Token, *Sequence:
+		w.expansions(flattenSequence(e))
+	case *Alternative:
+		w.open("one-of")
+
+		for _, item := range e.items {
+			if it, ok := item.(*Item); ok {
+				w.item(it, true)
+			} else {
+				w.element("item", item)
+			}
+		}
+
+		w.close("one-of")
+	case *Item:
+		w.item(e, false)
+	case *RuleRef:
+		uri := e.uri
+		if uri == "" {
+			uri = "#" + e.ruleId
+		}
+
+		w.line("<ruleref " + xmlAttr("uri", uri) + " />")
+	case *Tag:
+		w.line("<tag>" + xmlEscaper.Replace(e.text) + "</tag>")
+	case *Garbage:
+		w.line("<ruleref " + strings.Join(garbageAttrs(e), " ") + " />")
+	case *Null:
+		w.line(`<ruleref special="NULL" />`)
+	case *Void:
+		w.line(`<ruleref special="VOID" />`)
+	default:
+		panic(fmt.Sprintf("cannot write expansion of type %T", exp))
+	}
+}
+
+// Writes an item, along with its weight if it is an alternative of a one-of
+func (w *xmlWriter) item(it *Item, alternative bool) {
+	var attrs []string
+
+	if it.repeatMin != 1 || it.repeatMax != 1 {
+		attrs = append(attrs, xmlAttr("repeat", formatRepeat(it.repeatMin, it.repeatMax)))
+	}
+
+	if it.repeatMode != "" && it.repeatMode != RepeatModeNormal {
+		attrs = append(attrs, xmlAttr("repeat-mode", string(it.repeatMode)))
+	}
+
+	if it.repeatProb >= 0 {
+		attrs = append(attrs, xmlAttr("repeat-prob", strconv.FormatFloat(it.repeatProb, 'g', -1, 64)))
+	}
+
+	if alternative && it.weight != 1 {
+		attrs = append(attrs, xmlAttr("weight", strconv.FormatFloat(it.weight, 'g', -1, 64)))
+	}
+
+	w.element("item", it.child, attrs...)
+}
+
+// Returns the attributes of a GARBAGE ruleref. Its bounds are written in the ExtensionNamespace, which is declared on
+// the ruleref so that it can be copied into another document.
+func garbageAttrs(g *Garbage) []string {
+	attrs := []string{`special="GARBAGE"`}
+
+	if g.scanMatch {
+		attrs = append(attrs, `scan-match="true"`)
+	}
+
+	var ext []string
+
+	if g.minWords != 0 || g.maxWords != 0 {
+		ext = append(ext, xmlAttr("srgs:repeat", formatGarbageRepeat(g.minWords, g.maxWords)))
+	}
+
+	if g.repeatMode != "" && g.repeatMode != RepeatModeNormal {
+		ext = append(ext, xmlAttr("srgs:repeat-mode", string(g.repeatMode)))
+	}
+
+	if len(g.stopWords) > 0 {
+		ext = append(ext, xmlAttr("srgs:stop-words", strings.Join(g.sortedStopWords(), " ")))
+	}
+
+	if len(ext) == 0 {
+		return attrs
+	}
+
+	return append(append(attrs, xmlAttr("xmlns:srgs", ExtensionNamespace)), ext...)
+}
+
+// Formats the repeat of GARBAGE, such as "2", "1-3" or "1-", where a max of 0 has no bound
+func formatGarbageRepeat(min, max int) string {
+	if max == 0 {
+		max = RepeatUnbounded
+	}
+
+	return formatRepeat(min, max)
+}
+
+// Formats the repeat attribute of an item, such as "3", "0-1" or "1-"
+func formatRepeat(min, max int) string {
+	if max == RepeatUnbounded {
+		return strconv.Itoa(min) + "-"
+	}
+
+	if min == max {
+		return strconv.Itoa(min)
+	}
+
+	return strconv.Itoa(min) + "-" + strconv.Itoa(max)
+}
+
+// Returns the expansions of a sequence with any nested sequences flattened into it
+func flattenSequence(exp Expansion) []Expansion {
+	s, ok := exp.(*Sequence)
+
+	if !ok {
+		return []Expansion{exp}
+	}
+
+	var out []Expansion
+
+	for _, child := range s.exps {
+		out = append(out, flattenSequence(child)...)
+	}
+
+	return out
+}
+
+// Returns the text of a sequence of expansions, if they are all tokens
+func tokenText(exps []Expansion) (string, bool) {
+	var words []string
+
+	for _, exp := range exps {
+		t, ok := exp.(*Token)
+
+		if !ok {
+			return "", false
+		}
+
+		if t.token != "" {
+			words = append(words, t.token)
+		}
+	}
+
+	return strings.Join(words, " "), len(words) > 0
+}
+
+var (
+	xmlEscaper     = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	xmlAttrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+)
+
+func xmlAttr(name, value string) string {
+	return name + `="` + xmlAttrEscaper.Replace(value) + `"`
+}
@@ -0,0 +1,152 @@
+package srgs
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Asserts that a grammar written with WriteXml loads back into a grammar which matches every sentence of the original
+// the same way, and that writing it again gives the same document
+func assertRoundTrips(assert *assert.Assertions, g *Grammar) {
+	written := g.WriteXml()
+
+	loaded := NewGrammar()
+	loaded.Resolver = g.Resolver
+	if !assert.Nil(loaded.LoadXml(written), written) {
+		return
+	}
+
+	assert.Equal(written, loaded.WriteXml())
+	assert.Equal(g.PublicRules(), loaded.PublicRules())
+
+	sentences, err := g.Enumerate(GenerateOptions{MaxWords: 8, MaxSentences: 50})
+	if !assert.Nil(err) {
+		return
+	}
+
+	g.Backend, loaded.Backend = BackendChart, BackendChart
+
+	for _, sentence := range sentences {
+		for _, input := range []string{sentence.Text + " extra", sentence.Text[:len(sentence.Text)/2]} {
+			assert.Equal(g.HasPrefix(input), loaded.HasPrefix(input), "HasPrefix(%q)", input)
+			assert.Equal(g.HasMatch(input), loaded.HasMatch(input), "HasMatch(%q)", input)
+		}
+
+		want, got := new(SISRProcessor), new(SISRProcessor)
+		assert.Nil(loaded.GetMatch(sentence.Text, got), sentence.Text)
+		g.GetMatch(sentence.Text, want)
+		assert.Equal(want.GetInterpretation(), got.GetInterpretation(), sentence.Text)
+
+		wantInst, wantErr := want.GetInstance()
+		gotInst, gotErr := got.GetInstance()
+		assert.Equal(wantInst, gotInst, sentence.Text)
+		assert.Equal(wantErr, gotErr, sentence.Text)
+	}
+}
+
+func TestWriteXml(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGrammar()
+	if !assert.Nil(g.LoadXml(cityXml)) {
+		return
+	}
+
+	assert.Equal(`<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" xml:lang="en-US" root="trip">
+	<rule id="trip" scope="public">
+		<item repeat="0-1" repeat-prob="0.8">please</item>
+		to
+		<ruleref uri="#city" />
+		<tag>out = rules.city.out;</tag>
+	</rule>
+
+	<rule id="city">
+		<one-of>
+			<item weight="3">
+				portland
+				<tag>out = "PDX";</tag>
+			</item>
+			<item>
+				portland
+				<tag>out = "PWM";</tag>
+			</item>
+			<item weight="4">
+				boston
+				<tag>out = "BOS";</tag>
+			</item>
+		</one-of>
+	</rule>
+</grammar>
+`, g.WriteXml())
+
+	abnf := `#ABNF 1.0 UTF-8;
+language en-US;
+mode voice;
+root $start;
+tag-format <semantics/1.0>;
+
+public $start = ("a & b" | c <2-4> {out = "<c>";} | $GARBAGE) $end;
+private $end = /2/ end | /0.5/ (stop <0-1 /0.25/> now);
+`
+
+	g = NewGrammar()
+	if !assert.Nil(g.LoadABNF(abnf)) {
+		return
+	}
+
+	assert.Equal(`<?xml version="1.0" encoding="UTF-8" ?>
+<grammar xmlns="http://www.w3.org/2001/06/grammar" version="1.0" xml:lang="en-US" root="start" mode="voice" tag-format="semantics/1.0">
+	<rule id="start" scope="public">
+		<one-of>
+			<item>a &amp; b</item>
+			<item>
+				<item repeat="2-4">c</item>
+				<tag>out = "&lt;c&gt;";</tag>
+			</item>
+			<item>
+				<ruleref special="GARBAGE" />
+			</item>
+		</one-of>
+		<ruleref uri="#end" />
+	</rule>
+
+	<rule id="end">
+		<one-of>
+			<item weight="2">end</item>
+			<item weight="0.5">
+				<item repeat="0-1" repeat-prob="0.25">stop</item>
+				now
+			</item>
+		</one-of>
+	</rule>
+</grammar>
+`, g.WriteXml())
+
+	assertRoundTrips(assert, g)
+}
+
+func TestWriteXml_RoundTrip(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, xml := range []string{digitsXml, animalXml, nameXml, cityXml, garbageXml, listXml} {
+		g := NewGrammar()
+		if assert.Nil(g.LoadXml(xml)) {
+			assertRoundTrips(assert, g)
+		}
+	}
+
+	for _, abnf := range []string{digitsAbnf, animalAbnf, nameAbnf} {
+		g := NewGrammar()
+		if assert.Nil(g.LoadABNF(abnf)) {
+			assertRoundTrips(assert, g)
+		}
+	}
+
+	g := NewGrammar()
+	g.Resolver = MapResolver{"common/digits.grxml": commonDigitsXml}
+	if assert.Nil(g.LoadXml(newOrderXml("common/digits.grxml#digit", "digit"))) {
+		assert.Contains(g.WriteXml(), `<ruleref uri="common/digits.grxml#digit" />`)
+		assertRoundTrips(assert, g)
+	}
+}